@@ -1,5 +1,5 @@
 import React, { useEffect, useState } from 'react';
-import {deleteUser, deposit, getLige, getPonude, loginUser, passwordReset, registerUser} from './apiService';
+import {completeCheckout, deleteUser, deposit, FAKE_PAYMENTS, getLige, getOddsChanges, getPonude, loginUser, passwordReset, quoteUplata, registerUser, setSessionToken} from './apiService';
 import { format } from 'date-fns';
 import './HomePage.css';
 
@@ -15,6 +15,8 @@ const HomePage = () => {
     const [showPasswordResetPopup, setShowPasswordResetPopup] = useState(false);
     const [selectedCells, setSelectedCells] = useState([]);
     const [uplata,setUplata] = useState(0);
+    const [quote, setQuote] = useState(null);
+    const [oddsChanges, setOddsChanges] = useState([]);
 
     useEffect(() => {
         const fetchData = async () => {
@@ -32,6 +34,52 @@ const HomePage = () => {
         fetchData();
     }, []);
 
+    useEffect(() => {
+        let stopped = false;
+        const follow = async () => {
+            let cursor;
+            while (!stopped) {
+                try {
+                    const feed = await getOddsChanges(cursor);
+                    if (cursor !== undefined && feed.changes.length > 0) {
+                        setOddsChanges(feed.changes);
+                    }
+                    cursor = feed.cursor;
+                } catch (err) {
+                    await new Promise((resolve) => setTimeout(resolve, 5000));
+                }
+            }
+        };
+        follow();
+        return () => { stopped = true; };
+    }, []);
+
+    useEffect(() => {
+        const ponudaIDs = new Set(oddsChanges.map((change) => change.ponuda_id));
+        const latest = new Map(oddsChanges.map((change) => [`${change.ponuda_id}:${change.naziv}`, change]));
+        setPonude((current) => current.map((ponuda) => !ponudaIDs.has(ponuda.id) ? ponuda : {
+            ...ponuda,
+            tecajevi: ponuda.tecajevi.map((tecaj) => {
+                const change = latest.get(`${ponuda.id}:${tecaj.naziv}`);
+                return change ? { ...tecaj, tecaj: change.tecaj, suspended: change.suspended } : tecaj;
+            }),
+        }));
+        if (selectedCells.some((selectedItem) => ponudaIDs.has(selectedItem.ponuda.id))) {
+            setSelectedCells((cells) => [...cells]);
+        }
+    }, [oddsChanges]);
+
+    useEffect(() => {
+        if (uplata <= 0 || selectedCells.length === 0) {
+            setQuote(null);
+            return;
+        }
+        const odigraniPar = selectedCells.map((selectedItem) => ({ ponuda: selectedItem.ponuda.id, naziv: selectedItem.column }));
+        quoteUplata({ amount: uplata, odigrani_par: odigraniPar, player_id: AccountID || undefined })
+            .then(setQuote)
+            .catch(() => setQuote(null));
+    }, [selectedCells, uplata, AccountID]);
+
     const handleLogin = async () => {
         const username = document.querySelector('.login-input[type="username"]').value;
         const password = document.querySelector('.login-input[type="password"]').value;
@@ -41,6 +89,7 @@ const HomePage = () => {
             if (!response.error) {
                 const AccountID = response.id;
                 const AccountBalance = response.account_balance;
+                setSessionToken(response.session_token);
                 setUsername(username);
                 setID(AccountID);
                 setFunds(AccountBalance);
@@ -55,6 +104,7 @@ const HomePage = () => {
         }
     };
     const handleLogout = () => {
+        setSessionToken('');
         setUsername('');
         setID(0);
         setFunds(0);
@@ -110,15 +160,15 @@ const HomePage = () => {
     }
     const handlePasswordReset = async () => {
         const username = document.querySelector('.register-input[type="text"]').value;
-        const password = document.querySelector('.register-input[type="password"]').value;
-        const confirmPassword = document.querySelector('.register-input[type="password"]:nth-of-type(2)').value;
+        const [currentPassword, newPassword, confirmPassword] = Array.from(
+            document.querySelectorAll('.register-input[type="password"]'), (input) => input.value);
 
-        if (password !== confirmPassword) {
+        if (newPassword !== confirmPassword) {
             alert('Passwords do not match');
             return;
         }
         try {
-            const response = await passwordReset(username, password);
+            const response = await passwordReset(username, currentPassword, newPassword);
             if (!response.error) {
                 alert('Password reset successful');
             } else {
@@ -134,8 +184,14 @@ const HomePage = () => {
         const amount = prompt("Enter the amount to add:");
         if (amount) {
             try {
-                const response = await deposit(AccountID, parseFloat(amount));
-                if (!response.error) {
+                const intent = await deposit(AccountID, parseFloat(amount));
+                if (!FAKE_PAYMENTS) {
+                    // Funds are credited once the provider confirms the payment.
+                    window.location.assign(intent.checkout_url);
+                    return;
+                }
+                const response = await completeCheckout(intent.checkout_url);
+                if (response.status === 'succeeded') {
                     setFunds(funds + parseFloat(amount));
                     alert('Funds added successfully');
                 } else {
@@ -184,12 +240,22 @@ const HomePage = () => {
             alert("Please select valid matches and enter a positive bet amount.");
             return;
         }
+        if (quote && !quote.placeable) {
+            alert("This ticket can't be placed as it is, please review the selections and the amount.");
+            return;
+        }
         alert(`Processing payment of €${uplata.toFixed(2)} with odds ${calculateTecaj()}.`);
     };
 
     const calculateTecaj = () => {
         if (selectedCells.length === 0) return "Odaberite parove";
-        return selectedCells.reduce((acc, selectedItem) => acc * parseFloat(selectedItem.cell), 1).toFixed(4);
+        if (quote) return quote.total_odds.toFixed(2);
+        return selectedCells.reduce((acc, selectedItem) => acc * parseFloat(selectedItem.cell), 1).toFixed(2);
+    };
+
+    const calculateIsplata = () => {
+        if (quote) return quote.net_payout.toFixed(2);
+        return ((calculateTecaj() * uplata) || 0).toFixed(2);
     };
 
     const getPonudeForLiga = (liga) => {
@@ -241,6 +307,7 @@ const HomePage = () => {
                     <div className="popup-inner">
                         <h2>Password Reset</h2>
                         <input type="text" placeholder="Username" className="register-input"/>
+                        <input type="password" placeholder="Current Password" className="register-input"/>
                         <input type="password" placeholder="New Password" className="register-input"/>
                         <input type="password" placeholder="Confirm Password" className="register-input"/>
                         <button className="register-button" onClick={handlePasswordReset}>Reset</button>
@@ -315,7 +382,7 @@ const HomePage = () => {
 
                 </div>
                 <div className="isplata">
-                    <strong>Isplata:</strong> {((calculateTecaj() * uplata) || 0).toFixed(2)} €
+                    <strong>Isplata:</strong> {calculateIsplata()} €
                 </div>
                 <button className="pay-button" onClick={() => handlePay()}>Pripremi Uplatu</button>
             </div>
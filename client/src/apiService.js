@@ -1,5 +1,16 @@
 // apiService.js
-const BASE_URL = 'http://localhost:8080/api'; // Update to match your server
+const SERVER_URL = 'http://localhost:8080'; // Update to match your server
+const BASE_URL = `${SERVER_URL}/api`;
+// Set REACT_APP_FAKE_PAYMENTS=true when the server runs with -fake-payments to confirm deposits
+// without a payment provider.
+export const FAKE_PAYMENTS = process.env.REACT_APP_FAKE_PAYMENTS === 'true';
+
+// Requests on a player's account need the session token returned by loginUser.
+let sessionToken = '';
+export const setSessionToken = (token) => {
+    sessionToken = token || '';
+};
+const authHeaders = (headers) => (sessionToken ? { ...headers, Authorization: `Bearer ${sessionToken}` } : headers);
 
 export const getLige = async () => {
     const response = await fetch(`${BASE_URL}/lige`);
@@ -31,11 +42,11 @@ export const registerUser = async (username, password) => {
     return response.json();
 }
 
-export const passwordReset = async (username, password) => {
+export const passwordReset = async (username, password, newPassword) => {
     const response = await fetch(`${BASE_URL}/players`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ username, password }),
+        body: JSON.stringify({ username, password, new_password: newPassword }),
     });
 
     if (!response.ok) {
@@ -71,11 +82,12 @@ export const loginUser = async (username, password) => {
     return response.json();
 };
 
-export const deposit = async (id, amount) => {
+// Pass the same idempotencyKey when retrying a request so the server doesn't apply it twice.
+export const deposit = async (id, amount, idempotencyKey = crypto.randomUUID()) => {
     const response = await fetch(`${BASE_URL}/deposit/${id}`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ amount }),
+        headers: authHeaders({ 'Content-Type': 'application/json', 'Idempotency-Key': idempotencyKey }),
+        body: JSON.stringify(FAKE_PAYMENTS ? { amount, provider: 'fake' } : { amount }),
     });
     if (!response.ok) {
         throw new Error(`Error making deposit for player ${id}: ${response.statusText}`);
@@ -83,12 +95,45 @@ export const deposit = async (id, amount) => {
     return response.json();
 };
 
-export const uplata = async (id, data) => {
-    const response = await fetch(`${BASE_URL}/uplata/${id}`, {
+// completeCheckout pays a deposit through the fake provider's checkout. Only available with FAKE_PAYMENTS.
+export const completeCheckout = async (checkoutUrl) => {
+    const response = await fetch(`${SERVER_URL}${checkoutUrl}`, {
+        method: 'POST',
+    });
+    if (!response.ok) {
+        throw new Error(`Error completing payment: ${response.statusText}`);
+    }
+    return response.json();
+};
+
+export const quoteUplata = async (data) => {
+    const response = await fetch(`${BASE_URL}/uplata/quote`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(data),
     });
+    if (!response.ok) {
+        throw new Error(`Error pricing uplata: ${response.statusText}`);
+    }
+    return response.json();
+};
+
+// Waits for odds changes after the since cursor. Without since it returns the current cursor.
+export const getOddsChanges = async (since) => {
+    const query = since === undefined ? '' : `?since=${since}`;
+    const response = await fetch(`${BASE_URL}/tecajevi/changes${query}`);
+    if (!response.ok) {
+        throw new Error(`Error fetching odds changes: ${response.statusText}`);
+    }
+    return response.json();
+};
+
+export const uplata = async (id, data, idempotencyKey = crypto.randomUUID()) => {
+    const response = await fetch(`${BASE_URL}/uplata/${id}`, {
+        method: 'POST',
+        headers: authHeaders({ 'Content-Type': 'application/json', 'Idempotency-Key': idempotencyKey }),
+        body: JSON.stringify(data),
+    });
     if (!response.ok) {
         throw new Error(`Error creating uplata for player ${id}: ${response.statusText}`);
     }
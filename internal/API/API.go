@@ -6,30 +6,96 @@ import (
 	"errors"
 	"fmt"
 	"github.com/MKolega/Praksa/internal/client"
+	"github.com/MKolega/Praksa/internal/fiscal"
+	"github.com/MKolega/Praksa/internal/password"
+	"github.com/MKolega/Praksa/internal/payment"
 	"github.com/MKolega/Praksa/internal/shared"
 	"github.com/gorilla/mux"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type APIServer struct {
-	listenAddr string
-	store      shared.Storage
+	listenAddr             string
+	store                  shared.Storage
+	loginPolicy            LoginPolicy
+	sessionPolicy          SessionPolicy
+	kycThresholds          KYCThresholds
+	slipPolicy             SlipPolicy
+	fiscalRules            fiscal.Rules
+	paymentProviders       map[string]shared.PaymentProvider
+	defaultPaymentProvider string
+	oddsChanges            *oddsNotifier
 }
 
 type APIError struct {
-	Error string `json:"error"`
+	Error      string                  `json:"error"`
+	Violations []shared.LimitViolation `json:"violations,omitempty"`
+	Selections []shared.SelectionError `json:"selection_errors,omitempty"`
 }
 
-func NewApiServer(listenAddr string, store shared.Storage) *APIServer {
-	return &APIServer{
-		listenAddr: listenAddr,
-		store:      store,
+// Option configures an APIServer.
+type Option func(*APIServer)
+
+// WithFakePayments registers the fake payment provider and its checkout route, which confirms
+// any deposit without charging anything. It is meant for development and tests only and is
+// never the default provider, so deposits have to ask for it by name.
+func WithFakePayments() Option {
+	return func(s *APIServer) {
+		fake := newFakePaymentProvider()
+		s.paymentProviders[fake.Name()] = fake
+	}
+}
+
+// WithKYCThresholds replaces the amounts unverified players may deposit, withdraw and stake.
+func WithKYCThresholds(thresholds KYCThresholds) Option {
+	return func(s *APIServer) {
+		s.kycThresholds = thresholds
+	}
+}
+
+// WithSlipPolicy replaces the default slip policy, which allows one selection per event.
+func WithSlipPolicy(policy SlipPolicy) Option {
+	return func(s *APIServer) {
+		s.slipPolicy = policy
+	}
+}
+
+// WithFiscalRules replaces the Croatian fiscal rules applied to tickets by default.
+func WithFiscalRules(rules fiscal.Rules) Option {
+	return func(s *APIServer) {
+		s.fiscalRules = rules
 	}
 }
 
+func NewApiServer(listenAddr string, store shared.Storage, opts ...Option) *APIServer {
+	server := &APIServer{
+		listenAddr:       listenAddr,
+		store:            store,
+		loginPolicy:      DefaultLoginPolicy,
+		sessionPolicy:    DefaultSessionPolicy,
+		kycThresholds:    DefaultKYCThresholds,
+		slipPolicy:       DefaultSlipPolicy,
+		fiscalRules:      fiscal.Croatia,
+		paymentProviders: map[string]shared.PaymentProvider{},
+		oddsChanges:      newOddsNotifier(),
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	return server
+}
+
 func (s *APIServer) Run() {
+	if err := s.fiscalRules.Validate(); err != nil {
+		log.Fatal("invalid fiscal rules: ", err)
+	}
+	if len(s.paymentProviders) == 0 {
+		log.Print("no payment provider is registered, deposits are disabled")
+	}
 
 	ligeURL := "https://minus5-dev-test.s3.eu-central-1.amazonaws.com/lige.json"
 	err := s.FetchAndInsertLigeDataToDB(ligeURL)
@@ -41,17 +107,75 @@ func (s *APIServer) Run() {
 	if err != nil {
 		log.Fatal("failed to insert ponude data: ", err)
 	}
+	s.logRazradaMismatches()
 
 	router := mux.NewRouter()
 	router.Use(enableCors)
+	router.Use(s.trackSession)
 	router.HandleFunc("/api/lige", makeHTTPHandlefunc(s.HandleGetLige))
 	router.HandleFunc("/api/players", makeHTTPHandlefunc(s.handlePlayer))
-	router.HandleFunc("/api/players/{id:[0-9]+}", makeHTTPHandlefunc(s.handleGetPlayerByID))
+	router.HandleFunc("/api/players/{id:[0-9]+}", s.requirePlayerSession(makeHTTPHandlefunc(s.handlePlayerByID)))
 	router.HandleFunc("/api/login", makeHTTPHandlefunc(s.handleLogin))
 	router.HandleFunc("/api/ponude", makeHTTPHandlefunc(s.handlePonude))
-	router.HandleFunc("/api/ponude/{id:[0-9]+}", makeHTTPHandlefunc(s.handleGetPonuda))
-	router.HandleFunc("/api/deposit/{id:[0-9]+}", makeHTTPHandlefunc(s.handleDeposit)).Methods("POST")
-	router.HandleFunc("/api/uplata/{id:[0-9]+}", makeHTTPHandlefunc(s.handleUplata)).Methods("POST")
+	router.HandleFunc("/api/ponude/{id:[0-9]+}", makeHTTPHandlefunc(s.handlePonuda)).Methods("GET", "PUT", "PATCH", "DELETE")
+	router.HandleFunc("/api/ponude/{id:[0-9]+}/withdraw", makeHTTPHandlefunc(s.handleWithdrawPonuda)).Methods("POST")
+	router.HandleFunc("/api/ponude/{id:[0-9]+}/tecajevi/history", makeHTTPHandlefunc(s.handleGetTecajHistory)).Methods("GET")
+	router.HandleFunc("/api/tecajevi/changes", makeHTTPHandlefunc(s.handleGetTecajChanges)).Methods("GET")
+	router.HandleFunc("/api/deposit/{id:[0-9]+}", s.requirePlayerSession(s.idempotent(makeHTTPHandlefunc(s.handleDeposit)))).Methods("POST")
+	router.HandleFunc("/api/payments/{provider}/webhook", makeHTTPHandlefunc(s.handlePaymentWebhook)).Methods("POST")
+	if _, ok := s.paymentProviders[payment.FakeProviderName]; ok {
+		log.Println("Fake payment provider is enabled, deposits can be confirmed without paying")
+		router.HandleFunc("/api/payments/fake/checkout/{ref}", makeHTTPHandlefunc(s.handleFakeCheckout)).Methods("POST")
+	}
+	router.HandleFunc("/api/uplata/quote", makeHTTPHandlefunc(s.handleQuote)).Methods("POST")
+	router.HandleFunc("/api/uplata/{id:[0-9]+}", s.requirePlayerSession(s.idempotent(makeHTTPHandlefunc(s.handleUplata)))).Methods("POST")
+	router.HandleFunc("/api/players/{id:[0-9]+}/totp", s.requirePlayerSession(makeHTTPHandlefunc(s.handleTOTP))).Methods("POST", "DELETE")
+	router.HandleFunc("/api/players/{id:[0-9]+}/totp/confirm", s.requirePlayerSession(makeHTTPHandlefunc(s.handleConfirmTOTP))).Methods("POST")
+	router.HandleFunc("/api/players/{id:[0-9]+}/sessions", s.requirePlayerSession(makeHTTPHandlefunc(s.handleSessions))).Methods("GET", "DELETE")
+	router.HandleFunc("/api/players/{id:[0-9]+}/sessions/{sessionID:[0-9]+}", s.requirePlayerSession(makeHTTPHandlefunc(s.handleRevokeSession))).Methods("DELETE")
+	router.HandleFunc("/api/players/{id:[0-9]+}/transactions", s.requirePlayerSession(makeHTTPHandlefunc(s.handleGetStatement))).Methods("GET")
+	router.HandleFunc("/api/players/{id:[0-9]+}/withdrawals", s.requirePlayerSession(makeHTTPHandlefunc(s.handlePlayerWithdrawals))).Methods("GET", "POST")
+	router.HandleFunc("/api/players/{id:[0-9]+}/uplate", s.requirePlayerSession(makeHTTPHandlefunc(s.handleGetPlayerUplate))).Methods("GET")
+	router.HandleFunc("/api/players/{id:[0-9]+}/kyc", s.requirePlayerSession(makeHTTPHandlefunc(s.handleSubmitKYC))).Methods("POST")
+	router.HandleFunc("/api/admin/players/{id:[0-9]+}/kyc", makeHTTPHandlefunc(s.handleSetKYCStatus)).Methods("PUT")
+	router.HandleFunc("/api/admin/players/{id:[0-9]+}/ledger", makeHTTPHandlefunc(s.handleLedgerAdjustment)).Methods("POST")
+	router.HandleFunc("/api/admin/ledger/reconcile", makeHTTPHandlefunc(s.handleReconcileBalances)).Methods("GET")
+	router.HandleFunc("/api/admin/withdrawals", makeHTTPHandlefunc(s.handleGetWithdrawals)).Methods("GET")
+	router.HandleFunc("/api/admin/withdrawals/{id:[0-9]+}/{action:approve|reject|pay}", makeHTTPHandlefunc(s.handleWithdrawalAction)).Methods("POST")
+	router.HandleFunc("/api/admin/exchange-rates", makeHTTPHandlefunc(s.handleGetExchangeRates)).Methods("GET")
+	router.HandleFunc("/api/admin/exchange-rates/{currency}", makeHTTPHandlefunc(s.handleSetExchangeRate)).Methods("PUT")
+	router.HandleFunc("/api/admin/reports/ledger", makeHTTPHandlefunc(s.handleGetLedgerReport)).Methods("GET")
+	router.HandleFunc("/api/admin/limits", makeHTTPHandlefunc(s.handleGetBetLimits)).Methods("GET")
+	router.HandleFunc("/api/admin/limits/global", makeHTTPHandlefunc(s.handleBetLimits)).Methods("PUT")
+	router.HandleFunc("/api/admin/limits/{scope:league|event|player}/{id:[0-9]+}", makeHTTPHandlefunc(s.handleBetLimits)).Methods("PUT", "DELETE")
+	router.HandleFunc("/api/admin/slip-rules", makeHTTPHandlefunc(s.handleGetSlipRules)).Methods("GET")
+	router.HandleFunc("/api/admin/slip-rules/exclusive-tips", makeHTTPHandlefunc(s.handleExclusiveTips)).Methods("POST", "DELETE")
+	router.HandleFunc("/api/admin/slip-rules/league-bans", makeHTTPHandlefunc(s.handleLeagueBans)).Methods("POST", "DELETE")
+	router.HandleFunc("/api/admin/tecajevi", makeHTTPHandlefunc(s.handleBulkTecajevi)).Methods("PATCH")
+	router.HandleFunc("/api/admin/tecajevi/{id:[0-9]+}", makeHTTPHandlefunc(s.handleTecaj)).Methods("PUT", "PATCH")
+	router.HandleFunc("/api/admin/ponude/{id:[0-9]+}/{action:suspend|unsuspend}", makeHTTPHandlefunc(s.handleSuspendPonuda)).Methods("POST")
+	router.HandleFunc("/api/admin/ponude/{id:[0-9]+}/result", makeHTTPHandlefunc(s.handleSetPonudaResult)).Methods("POST")
+	router.HandleFunc("/api/admin/ponude/{id:[0-9]+}/settle", makeHTTPHandlefunc(s.handleSettlePonuda)).Methods("POST")
+	router.HandleFunc("/api/admin/lige", makeHTTPHandlefunc(s.handleCreateLiga)).Methods("POST")
+	router.HandleFunc("/api/admin/lige/order", makeHTTPHandlefunc(s.handleReorderLige)).Methods("PUT")
+	router.HandleFunc("/api/admin/lige/{id:[0-9]+}", makeHTTPHandlefunc(s.handleLiga)).Methods("GET", "PUT", "DELETE")
+	router.HandleFunc("/api/admin/lige/{id:[0-9]+}/razrade", makeHTTPHandlefunc(s.handleCreateRazrada)).Methods("POST")
+	router.HandleFunc("/api/admin/razrade/{id:[0-9]+}", makeHTTPHandlefunc(s.handleRazrada)).Methods("GET", "PUT", "DELETE")
+	router.HandleFunc("/api/admin/razrade/{id:[0-9]+}/tipovi", makeHTTPHandlefunc(s.handleCreateTip)).Methods("POST")
+	router.HandleFunc("/api/admin/razrade/{id:[0-9]+}/tipovi/order", makeHTTPHandlefunc(s.handleReorderTipovi)).Methods("PUT")
+	router.HandleFunc("/api/admin/tipovi/{id:[0-9]+}", makeHTTPHandlefunc(s.handleTip)).Methods("PUT", "DELETE")
+	router.HandleFunc("/api/admin/margins", makeHTTPHandlefunc(s.handleGetMarginRules)).Methods("GET")
+	router.HandleFunc("/api/admin/margins", makeHTTPHandlefunc(s.handleMarginRule)).Methods("PUT", "DELETE")
+	router.HandleFunc("/api/admin/ponude/{id:[0-9]+}/overround", makeHTTPHandlefunc(s.handleGetOverround)).Methods("GET")
+	router.HandleFunc("/api/admin/liabilities", makeHTTPHandlefunc(s.handleGetLiabilities)).Methods("GET")
+	router.HandleFunc("/api/admin/exposure-thresholds", makeHTTPHandlefunc(s.handleGetExposureThresholds)).Methods("GET")
+	router.HandleFunc("/api/admin/exposure-thresholds/global", makeHTTPHandlefunc(s.handleExposureThresholds)).Methods("PUT", "DELETE")
+	router.HandleFunc("/api/admin/exposure-thresholds/{scope:league|event}/{id:[0-9]+}", makeHTTPHandlefunc(s.handleExposureThresholds)).Methods("PUT", "DELETE")
+	router.HandleFunc("/api/admin/uplate/referred", makeHTTPHandlefunc(s.handleGetReferredUplate)).Methods("GET")
+	router.HandleFunc("/api/admin/uplate/{id:[0-9]+}/{action:approve|reject}", makeHTTPHandlefunc(s.handleReviewUplata)).Methods("POST")
+	router.HandleFunc("/api/admin/razrade/mismatches", makeHTTPHandlefunc(s.handleGetRazradaMismatches)).Methods("GET")
+	router.HandleFunc("/api/admin/reports/fiscal", makeHTTPHandlefunc(s.handleGetFiscalReport)).Methods("GET")
+	router.HandleFunc("/api/admin/players/{id:[0-9]+}/unlock", makeHTTPHandlefunc(s.handleUnlockPlayer)).Methods("POST")
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./client/build")))
 
 	log.Println("JSON API Server is running on port: ", s.listenAddr)
@@ -66,8 +190,8 @@ func (s *APIServer) Run() {
 func enableCors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -95,6 +219,35 @@ func makeHTTPHandlefunc(handlerFunc func(w http.ResponseWriter, r *http.Request)
 
 				log.Printf("Bad request: %v", err)
 				_ = WriteJSON(w, http.StatusBadRequest, APIError{Error: e.Message})
+			case *shared.SlipError:
+
+				log.Printf("Bad request: %v", err)
+				_ = WriteJSON(w, http.StatusBadRequest, APIError{Error: e.Message, Selections: e.Selections})
+			case *shared.LimitError:
+
+				log.Printf("Bad request: %v", err)
+				_ = WriteJSON(w, http.StatusBadRequest, APIError{Error: e.Message, Violations: e.Violations})
+			case *shared.RateLimitError:
+
+				log.Printf("Rate limited: %v", err)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+				_ = WriteJSON(w, http.StatusTooManyRequests, APIError{Error: e.Message})
+			case *shared.ConflictError:
+
+				log.Printf("Conflict: %v", err)
+				_ = WriteJSON(w, http.StatusConflict, APIError{Error: e.Message})
+			case *shared.UnauthorizedError:
+
+				log.Printf("Unauthorized: %v", err)
+				_ = WriteJSON(w, http.StatusUnauthorized, APIError{Error: e.Message})
+			case *shared.ForbiddenError:
+
+				log.Printf("Forbidden: %v", err)
+				_ = WriteJSON(w, http.StatusForbidden, APIError{Error: e.Message})
+			case *shared.UnavailableError:
+
+				log.Printf("Unavailable: %v", err)
+				_ = WriteJSON(w, http.StatusServiceUnavailable, APIError{Error: e.Message})
 			case *shared.InternalError:
 
 				log.Printf("Internal server error: %v", err)
@@ -118,7 +271,7 @@ func (s *APIServer) FetchAndInsertLigeDataToDB(url string) error {
 
 	for _, liga := range jsonData.Lige {
 		ligaID, err := s.store.CreateLiga(liga.Naziv)
-		if err != nil {
+		if err != nil && !errors.Is(err, shared.ErrAlreadyExists) {
 			log.Printf("failed to insert liga %s: %v", liga.Naziv, err)
 			continue
 		}
@@ -130,9 +283,13 @@ func (s *APIServer) FetchAndInsertLigeDataToDB(url string) error {
 				continue
 			}
 
-			for _, tip := range razrada.Tipovi {
-				err := s.store.CreateTipovi(razradaID, tip.Naziv)
-				if err != nil {
+			for position, tip := range razrada.Tipovi {
+				if err := tip.Normalize(); err != nil {
+					log.Printf("skipping tip %s for razrada %d: %v", tip.Naziv, razradaID, err)
+					continue
+				}
+				_, err := s.store.CreateTipovi(razradaID, position, tip)
+				if err != nil && !errors.Is(err, shared.ErrAlreadyExists) {
 					log.Printf("failed to insert tip %s for razrada %d: %v", tip.Naziv, razradaID, err)
 
 				}
@@ -153,19 +310,40 @@ func (s *APIServer) FetchAndInsertPonudeDataToDB(url string) error {
 		return fmt.Errorf("failed to decode Ponude JSON: %v", err)
 	}
 
+	pricer, err := s.newFeedPricer()
+	if err != nil {
+		return err
+	}
+
 	for _, ponuda := range jsonData {
 		if err := s.store.CreatePonuda(&ponuda); err != nil {
-			log.Printf("failed to insert ponuda with ID %d: %v", ponuda.ID, err)
-			continue
+			// A ponuda imported before only gets its odds refreshed.
+			if _, getErr := s.store.GetPonudaByID(ponuda.ID); getErr != nil {
+				log.Printf("failed to insert ponuda with ID %d: %v", ponuda.ID, err)
+				continue
+			}
 		}
 
+		tecajevi := make([]shared.Tecajevi, 0, len(ponuda.Tecajevi))
 		for _, tecaj := range ponuda.Tecajevi {
-			if err := s.store.CreateTecaj(ponuda.ID, tecaj.Tecaj, tecaj.Naziv); err != nil {
+			if err := tecaj.Normalize(); err != nil {
+				log.Printf("skipping tecaj '%s' for ponuda ID %d: %v", tecaj.Naziv, ponuda.ID, err)
+				continue
+			}
+			tecajevi = append(tecajevi, tecaj)
+		}
+		for _, tecaj := range pricer.price(ponuda.ID, tecajevi) {
+			if err := s.store.CreateTecaj(ponuda.ID, tecaj, shared.OddsSourceFeed); err != nil {
+				if errors.Is(err, shared.ErrTraderOdds) {
+					log.Printf("keeping trader odds of '%s' for ponuda ID %d: %v", tecaj.Naziv, ponuda.ID, err)
+					continue
+				}
 				log.Printf("failed to insert tecaj '%s' for ponuda ID %d: %v", tecaj.Naziv, ponuda.ID, err)
 			}
 		}
 	}
 
+	s.oddsChanges.notify()
 	log.Println("Successfully updated Ponude data.")
 	return nil
 
@@ -211,8 +389,32 @@ func (s *APIServer) handleCreatePlayer(w http.ResponseWriter, r *http.Request) e
 	if err := json.NewDecoder(r.Body).Decode(createPlayerReq); err != nil {
 		return &shared.UserError{Message: fmt.Sprintf("failed to decode player data: %v", err)}
 	}
-	player := shared.NewPlayer(createPlayerReq.Username, createPlayerReq.Password)
+	createPlayerReq.Username = strings.TrimSpace(createPlayerReq.Username)
+	if err := shared.ValidateUsername(createPlayerReq.Username); err != nil {
+		return err
+	}
+	if err := shared.ValidatePassword(createPlayerReq.Username, createPlayerReq.Password); err != nil {
+		return err
+	}
+	currency := strings.ToUpper(strings.TrimSpace(createPlayerReq.Currency))
+	if currency == "" {
+		currency = shared.BaseCurrency
+	}
+	if err := shared.ValidateCurrencyCode(currency); err != nil {
+		return err
+	}
+	if _, err := s.exchangeRate(currency); err != nil {
+		return err
+	}
+	hash, err := password.Hash(createPlayerReq.Password)
+	if err != nil {
+		return &shared.InternalError{Message: err.Error()}
+	}
+	player := shared.NewPlayer(createPlayerReq.Username, hash, currency)
 	if err := s.store.CreatePlayer(player); err != nil {
+		if errors.Is(err, shared.ErrUsernameTaken) {
+			return &shared.UserError{Message: fmt.Sprintf("username %s is already taken", createPlayerReq.Username)}
+		}
 		return &shared.InternalError{Message: fmt.Sprintf("failed to create player: %v", err)}
 	}
 	return WriteJSON(w, http.StatusCreated, player)
@@ -228,15 +430,31 @@ func (s *APIServer) handleGetPlayers(w http.ResponseWriter, _ *http.Request) err
 
 }
 
+// handlePasswordReset changes the password of a player who can log in with the current one, and
+// logs the player out everywhere.
 func (s *APIServer) handlePasswordReset(w http.ResponseWriter, r *http.Request) error {
-	resetRequest := new(shared.CreatePlayerRequest)
+	resetRequest := new(shared.PasswordResetRequest)
 	if err := json.NewDecoder(r.Body).Decode(resetRequest); err != nil {
 		return &shared.UserError{Message: fmt.Sprintf("failed to decode player data: %v", err)}
 	}
-	if err := s.store.ResetPassword(resetRequest.Username, resetRequest.Password); err != nil {
+	player, err := s.authenticate(r, resetRequest.Username, resetRequest.Password, resetRequest.TOTPCode, resetRequest.RecoveryCode)
+	if err != nil {
+		return err
+	}
+	if err := shared.ValidatePassword(player.Username, resetRequest.NewPassword); err != nil {
+		return err
+	}
+	hash, err := password.Hash(resetRequest.NewPassword)
+	if err != nil {
+		return &shared.InternalError{Message: err.Error()}
+	}
+	if err := s.store.ResetPassword(player.Username, hash); err != nil {
 		return &shared.InternalError{Message: fmt.Sprintf("failed to reset password: %v", err)}
 	}
-	return WriteJSON(w, http.StatusOK, resetRequest)
+	if err := s.store.RevokeSessions(player.ID); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to revoke sessions: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, player.ID)
 }
 
 func (s *APIServer) handleDeleteUser(w http.ResponseWriter, r *http.Request) error {
@@ -267,24 +485,65 @@ func (s *APIServer) handleGetPlayerByID(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *APIServer) handleLogin(w http.ResponseWriter, r *http.Request) error {
-	loginReq := new(shared.Player)
+	loginReq := new(shared.LoginRequest)
 	if err := json.NewDecoder(r.Body).Decode(loginReq); err != nil {
 		return &shared.UserError{Message: fmt.Sprintf("failed to decode login data: %v", err)}
 	}
 
-	player, err := s.store.GetLogin(loginReq.Username)
+	player, err := s.authenticate(r, loginReq.Username, loginReq.Password, loginReq.TOTPCode, loginReq.RecoveryCode)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return &shared.UserError{Message: fmt.Sprintf("Player with username %s not found", loginReq.Username)}
-		}
+		return err
+	}
+	loginResp, err := s.createSession(r, player)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to create session: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, loginResp)
+}
+
+// authenticate checks a username and password, and the second factor when the player has
+// enabled it, under the login throttle. Every failure counts against the username and the
+// client's IP.
+func (s *APIServer) authenticate(r *http.Request, username, pass, code, recoveryCode string) (*shared.Player, error) {
+	ip := clientIP(r)
+	key := loginThrottleKey(username)
+	if err := s.checkLoginThrottle(shared.LoginScopeIP, ip); err != nil {
+		return nil, err
+	}
+	if err := s.checkLoginThrottle(shared.LoginScopeUsername, key); err != nil {
+		return nil, err
+	}
+
+	player, err := s.store.GetLogin(username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, &shared.InternalError{Message: fmt.Sprintf("Login failed with username: %v", err)}
+	}
+	if err != nil || !password.Verify(player.Password, pass) {
+		s.recordLoginFailures(r, username)
+		return nil, &shared.UserError{Message: "invalid username or password"}
+	}
 
-		return &shared.InternalError{Message: fmt.Sprintf("Login failed with username: %v", err)}
+	required, err := s.secondFactorRequired(player.ID)
+	if err != nil {
+		return nil, &shared.InternalError{Message: fmt.Sprintf("failed to check two-factor authentication: %v", err)}
 	}
-	if player.Password == loginReq.Password {
-		return WriteJSON(w, http.StatusOK, player)
+	if required {
+		if code == "" && recoveryCode == "" {
+			return nil, &shared.UserError{Message: "two-factor authentication code required"}
+		}
+		if err := s.verifySecondFactor(player.ID, code, recoveryCode); err != nil {
+			var userErr *shared.UserError
+			if errors.As(err, &userErr) {
+				s.recordLoginFailures(r, username)
+			}
+			return nil, err
+		}
 	}
 
-	return fmt.Errorf("invalid password")
+	if err := s.store.ResetLoginFailures(shared.LoginScopeUsername, key); err != nil {
+		log.Printf("failed to reset login failures for %s: %v", key, err)
+	}
+	return player, nil
 }
 
 func (s *APIServer) handleGetPonuda(w http.ResponseWriter, r *http.Request) error {
@@ -308,16 +567,23 @@ func (s *APIServer) handleCreatePonuda(w http.ResponseWriter, r *http.Request) e
 		return &shared.UserError{Message: fmt.Sprintf("failed to decode ponuda data: %v", err)}
 	}
 
+	for i := range createPonudaReq.Tecajevi {
+		if err := createPonudaReq.Tecajevi[i].Normalize(); err != nil {
+			return err
+		}
+	}
+
 	ponuda := shared.NewPonuda(createPonudaReq.Broj, createPonudaReq.ID, createPonudaReq.Naziv, createPonudaReq.Vrijeme, createPonudaReq.TvKanal, createPonudaReq.ImaStatistiku)
 	if err := s.store.CreatePonuda(ponuda); err != nil {
 		return &shared.InternalError{Message: fmt.Sprintf("failed to create ponuda: %v", err)}
 	}
 
 	for _, tecaj := range createPonudaReq.Tecajevi {
-		if err := s.store.CreateTecaj(createPonudaReq.ID, tecaj.Tecaj, tecaj.Naziv); err != nil {
+		if err := s.store.CreateTecaj(createPonudaReq.ID, tecaj, shared.OddsSourceTrader); err != nil {
 			return &shared.InternalError{Message: fmt.Sprintf("failed to create tecaj: %v", err)}
 		}
 	}
+	s.oddsChanges.notify()
 
 	return WriteJSON(w, http.StatusCreated, createPonudaReq)
 }
@@ -332,41 +598,108 @@ func (s *APIServer) handleUplata(w http.ResponseWriter, r *http.Request) error {
 		return &shared.UserError{Message: fmt.Sprintf("failed to decode uplata data: %v", err)}
 	}
 
-	var currentBalance, _ = s.store.GetAccountBalance(playerID)
+	if err := s.requireVerified(playerID, uplataReq.Amount, s.kycThresholds.Stake, "stakes"); err != nil {
+		return err
+	}
+
+	currentBalance, err := s.store.GetAccountBalance(playerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", playerID)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get account balance: %v", err)}
+	}
 	if uplataReq.Amount > currentBalance {
-		return &shared.UserError{Message: fmt.Sprintf("insufficient funds: %v", err)}
+		return &shared.UserError{Message: fmt.Sprintf("insufficient funds: balance is %s, uplata is %s", currentBalance, uplataReq.Amount)}
 	}
 
-	tecajevi, err := s.store.GetTecaj(uplataReq.OdigraniPar)
+	quote, err := s.priceSlip(playerID, uplataReq)
 	if err != nil {
-		return &shared.InternalError{Message: fmt.Sprintf("failed to get tecaj: %v", err)}
+		return err
 	}
-	if tecajevi == nil {
-		return &shared.InternalError{Message: "no tecajevi found for the given par"}
+	if len(quote.SelectionErrors) > 0 {
+		return &shared.SlipError{Message: "slip contains selections that can't be placed", Selections: quote.SelectionErrors}
+	}
+	if len(quote.Violations) > 0 {
+		return &shared.LimitError{Message: "ticket breaks betting limits", Violations: quote.Violations}
 	}
 
-	if err := s.store.CreateUplata(playerID, uplataReq.Amount, uplataReq.OdigraniPar); err != nil {
+	uplata := &shared.Uplata{PlayerID: playerID, Currency: quote.Currency, FiscalBreakdown: quote.FiscalBreakdown, Status: shared.TicketOpen}
+	if quote.Referred {
+		uplata.Status = shared.TicketReferred
+	}
+	for _, sel := range quote.Selections {
+		uplata.Bets = append(uplata.Bets, &shared.TicketBet{Ponuda: sel.Ponuda, NazivTipa: sel.NazivTipa, Tip: sel.Tip, Tecaj: sel.Tecaj})
+	}
+	if err := s.store.CreateUplata(uplata); err != nil {
+		if errors.Is(err, shared.ErrInsufficientFunds) {
+			return &shared.UserError{Message: "insufficient funds"}
+		}
+		if errors.Is(err, shared.ErrOddsChanged) {
+			return &shared.ConflictError{Message: fmt.Sprintf("odds changed while placing the ticket, please try again: %v", err)}
+		}
+		if errors.Is(err, shared.ErrSelectionSuspended) {
+			return &shared.ConflictError{Message: fmt.Sprintf("a selection was suspended while placing the ticket: %v", err)}
+		}
+		if errors.Is(err, shared.ErrPonudaClosed) {
+			return &shared.ConflictError{Message: fmt.Sprintf("a ponuda closed for betting while placing the ticket: %v", err)}
+		}
 		return &shared.InternalError{Message: fmt.Sprintf("failed to create uplata: %v", err)}
 	}
 
-	return WriteJSON(w, http.StatusOK, uplataReq)
+	if uplata.Status == shared.TicketReferred {
+		return WriteJSON(w, http.StatusAccepted, uplata)
+	}
+	s.suspendExposedOutcomes(betPonude(quote.Selections))
+	return WriteJSON(w, http.StatusOK, uplata)
 
 }
 
+// handleDeposit starts a deposit with a payment provider. The balance is credited once the
+// provider confirms the payment, see handlePaymentWebhook.
 func (s *APIServer) handleDeposit(w http.ResponseWriter, r *http.Request) error {
 	id, err := getID(r)
 	if err != nil {
 		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
 	}
+	if len(s.paymentProviders) == 0 {
+		return &shared.UnavailableError{Message: "deposits are disabled, no payment provider is configured"}
+	}
 	depositRequest := new(shared.DepositRequest)
 
 	if err := json.NewDecoder(r.Body).Decode(&depositRequest); err != nil {
 		return &shared.UserError{Message: fmt.Sprintf("failed to decode deposit data: %v", err)}
 	}
-	if err := s.store.Deposit(id, depositRequest.Amount); err != nil {
+	if depositRequest.Amount <= 0 {
+		return &shared.UserError{Message: "deposit amount must be positive"}
+	}
+	if err := s.requireVerified(id, depositRequest.Amount, s.kycThresholds.Deposit, "deposits"); err != nil {
+		return err
+	}
+	if depositRequest.Provider == "" {
+		depositRequest.Provider = s.defaultPaymentProvider
+	}
+	if depositRequest.Provider == "" {
+		return &shared.UserError{Message: "no payment provider given and none is configured by default"}
+	}
+	provider, ok := s.paymentProviders[depositRequest.Provider]
+	if !ok {
+		return &shared.UserError{Message: fmt.Sprintf("unknown payment provider %s", depositRequest.Provider)}
+	}
+
+	player, err := s.store.GetPlayerByID(id)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+
+	intent := &shared.PaymentIntent{Provider: provider.Name(), PlayerID: id, Amount: depositRequest.Amount, Currency: player.Currency}
+	if err := provider.CreateIntent(intent); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to create payment: %v", err)}
+	}
+	if err := s.store.CreatePaymentIntent(intent); err != nil {
 		return &shared.InternalError{Message: fmt.Sprintf("failed to deposit: %v", err)}
 	}
-	return WriteJSON(w, http.StatusOK, depositRequest)
+	return WriteJSON(w, http.StatusCreated, intent)
 }
 
 func (s *APIServer) handeGetAllPonude(w http.ResponseWriter, _ *http.Request) error {
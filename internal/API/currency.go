@@ -0,0 +1,71 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+)
+
+// exchangeRate returns the rate of currency, failing with a UserError for currencies the
+// house doesn't hold.
+func (s *APIServer) exchangeRate(currency string) (shared.Rate, error) {
+	if currency == shared.BaseCurrency {
+		return shared.RateOne, nil
+	}
+	rate, err := s.store.GetExchangeRate(currency)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, &shared.UserError{Message: fmt.Sprintf("unsupported currency %s", currency)}
+		}
+		return 0, &shared.InternalError{Message: fmt.Sprintf("failed to get exchange rate: %v", err)}
+	}
+	return rate.Rate, nil
+}
+
+func (s *APIServer) handleGetExchangeRates(w http.ResponseWriter, _ *http.Request) error {
+	rates, err := s.store.GetExchangeRates()
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get exchange rates: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, rates)
+}
+
+func (s *APIServer) handleSetExchangeRate(w http.ResponseWriter, r *http.Request) error {
+	currency := strings.ToUpper(mux.Vars(r)["currency"])
+	if err := shared.ValidateCurrencyCode(currency); err != nil {
+		return err
+	}
+	if currency == shared.BaseCurrency {
+		return &shared.UserError{Message: fmt.Sprintf("the rate of the base currency %s is always 1", shared.BaseCurrency)}
+	}
+	rateReq := new(shared.ExchangeRateRequest)
+	if err := json.NewDecoder(r.Body).Decode(rateReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode exchange rate data: %v", err)}
+	}
+	if rateReq.Rate <= 0 {
+		return &shared.UserError{Message: "rate must be positive"}
+	}
+	rate, err := s.store.SetExchangeRate(currency, rateReq.Rate)
+	if err != nil {
+		return &shared.InternalError{Message: err.Error()}
+	}
+	return WriteJSON(w, http.StatusOK, rate)
+}
+
+// handleGetLedgerReport totals wallet movements per type and currency, with totals in the base currency.
+func (s *APIServer) handleGetLedgerReport(w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseStatementFilter(r.URL.Query().Get("from"), r.URL.Query().Get("to"), "")
+	if err != nil {
+		return err
+	}
+	report, err := s.store.GetLedgerReport(filter.From, filter.To)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get ledger report: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,194 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+)
+
+// exposureReason is kept in the odds history of outcomes suspended for their liability.
+const exposureReason = "exposure threshold reached"
+
+func betPonude(selections []*shared.BetSelection) []int {
+	ids := make([]int, 0, len(selections))
+	for _, sel := range selections {
+		ids = append(ids, sel.Ponuda)
+	}
+	return ids
+}
+
+// referTicket reports whether a ticket with the given stake and potential payout, in the base
+// currency, would take any of its outcomes above the exposure threshold at which tickets are
+// held for review.
+func (s *APIServer) referTicket(stake, payout shared.Money, selections []*shared.BetSelection) (bool, error) {
+	thresholds, err := s.store.GetExposureThresholds()
+	if err != nil {
+		return false, &shared.InternalError{Message: fmt.Sprintf("failed to get exposure thresholds: %v", err)}
+	}
+	if len(thresholds) == 0 {
+		return false, nil
+	}
+	report, err := s.store.GetLiabilities(betPonude(selections))
+	if err != nil {
+		return false, &shared.InternalError{Message: fmt.Sprintf("failed to get liabilities: %v", err)}
+	}
+	return shared.ReferTicket(thresholds, report.Outcomes, stake, payout, selections), nil
+}
+
+// suspendExposedOutcomes suspends the outcomes of the ponude whose liability reached their
+// suspend threshold. The tickets that got them there are placed already, so failures are only
+// logged.
+func (s *APIServer) suspendExposedOutcomes(ponudaIDs []int) {
+	thresholds, err := s.store.GetExposureThresholds()
+	if err != nil {
+		log.Printf("failed to get exposure thresholds: %v", err)
+		return
+	}
+	if len(thresholds) == 0 {
+		return
+	}
+	report, err := s.store.GetLiabilities(ponudaIDs)
+	if err != nil {
+		log.Printf("failed to get liabilities: %v", err)
+		return
+	}
+
+	suspended := true
+	var updates []shared.TecajUpdate
+	for _, o := range shared.ExposedOutcomes(thresholds, report.Outcomes) {
+		updates = append(updates, shared.TecajUpdate{Ponuda: o.PonudaID, Naziv: o.Naziv, Suspended: &suspended})
+	}
+	if len(updates) == 0 {
+		return
+	}
+	changes, err := s.store.UpdateTecajevi(updates, shared.OddsSourceExposure, exposureReason)
+	if err != nil {
+		log.Printf("failed to suspend exposed outcomes: %v", err)
+		return
+	}
+	for _, c := range changes {
+		log.Printf("suspended tip %s on ponuda %d: %s", c.Naziv, c.PonudaID, exposureReason)
+	}
+	if len(changes) > 0 {
+		s.oddsChanges.notify()
+	}
+}
+
+// handleGetLiabilities reports the liability on every outcome with open bets and on every liga.
+func (s *APIServer) handleGetLiabilities(w http.ResponseWriter, _ *http.Request) error {
+	report, err := s.store.GetLiabilities(nil)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get liabilities: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, report)
+}
+
+func (s *APIServer) handleGetExposureThresholds(w http.ResponseWriter, _ *http.Request) error {
+	thresholds, err := s.store.GetExposureThresholds()
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get exposure thresholds: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, thresholds)
+}
+
+// handleExposureThresholds replaces or deletes the exposure thresholds of a scope. Thresholds
+// left out are inherited.
+func (s *APIServer) handleExposureThresholds(w http.ResponseWriter, r *http.Request) error {
+	scope, scopeID, err := betLimitScope(r)
+	if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case "PUT":
+		thresholdReq := new(shared.ExposureThresholdRequest)
+		if err := json.NewDecoder(r.Body).Decode(thresholdReq); err != nil {
+			return &shared.UserError{Message: fmt.Sprintf("failed to decode exposure thresholds: %v", err)}
+		}
+		if err := thresholdReq.Validate(); err != nil {
+			return err
+		}
+		threshold := &shared.ExposureThreshold{Scope: scope, ScopeID: scopeID, ReferAt: thresholdReq.ReferAt, SuspendAt: thresholdReq.SuspendAt}
+		if err := s.store.SetExposureThreshold(threshold); err != nil {
+			return &shared.InternalError{Message: err.Error()}
+		}
+		return WriteJSON(w, http.StatusOK, threshold)
+	case "DELETE":
+		if err := s.store.DeleteExposureThreshold(scope, scopeID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &shared.UserError{Message: err.Error()}
+			}
+			return &shared.InternalError{Message: fmt.Sprintf("failed to delete exposure thresholds: %v", err)}
+		}
+		return WriteJSON(w, http.StatusOK, scopeID)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+func (s *APIServer) handleGetReferredUplate(w http.ResponseWriter, _ *http.Request) error {
+	uplate, err := s.store.GetReferredUplate()
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get referred uplate: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, uplate)
+}
+
+// handleReviewUplata approves or rejects a ticket held for review. An approved ticket opens and
+// is settled straight away if its bets were resolved in the meantime. A rejected ticket is void
+// and the player gets the whole payment back.
+func (s *APIServer) handleReviewUplata(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid uplata id: %v", err)}
+	}
+	u, err := s.store.GetUplata(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get uplata %d: %v", id, err)}
+	}
+	if u.Status != shared.TicketReferred {
+		return &shared.UserError{Message: fmt.Sprintf("uplata %d isn't referred", id)}
+	}
+
+	switch action := mux.Vars(r)["action"]; action {
+	case "approve":
+		if err := s.store.ApproveUplata(id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &shared.UserError{Message: err.Error()}
+			}
+			return &shared.InternalError{Message: fmt.Sprintf("failed to approve uplata %d: %v", id, err)}
+		}
+		if _, err := s.settleUplate([]int{id}); err != nil {
+			return err
+		}
+		ponude := make([]int, 0, len(u.Bets))
+		for _, bet := range u.Bets {
+			ponude = append(ponude, bet.Ponuda)
+		}
+		s.suspendExposedOutcomes(ponude)
+	case "reject":
+		settlement := &shared.Settlement{Odds: shared.OddsOne, Refund: u.Payment}
+		ok, err := s.store.SettleUplata(id, shared.TicketVoid, settlement)
+		if err != nil {
+			return &shared.InternalError{Message: fmt.Sprintf("failed to reject uplata %d: %v", id, err)}
+		}
+		if !ok {
+			return &shared.UserError{Message: fmt.Sprintf("uplata %d was settled in the meantime", id)}
+		}
+	default:
+		return &shared.UserError{Message: fmt.Sprintf("unknown review action %s", action)}
+	}
+
+	if u, err = s.store.GetUplata(id); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get uplata %d: %v", id, err)}
+	}
+	return WriteJSON(w, http.StatusOK, u)
+}
@@ -0,0 +1,136 @@
+package API
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 255
+	// idempotencyKeyTTL is how long a key is remembered. Afterwards it may be reused.
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyHeartbeat is how often a running request shows it still holds its key. A request
+	// that died mid-flight never releases its key, so a retry may take the key over once no
+	// heartbeat came for idempotencyDeadAfter. Slow requests keep their key however long they run.
+	idempotencyHeartbeat = 10 * time.Second
+	idempotencyDeadAfter = 3 * idempotencyHeartbeat
+)
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotencyScope is the route a key is used on. Player routes carry the player's id, so keys
+// are per player as well.
+func idempotencyScope(r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotent makes next safe to retry when the client sends an Idempotency-Key header. The
+// first request with a key runs normally and its response is stored; repeating the same request
+// with the key replays that response, while reusing the key for a different request is rejected.
+// Keys are scoped to the route. Server errors aren't stored, so the request may be retried with
+// the same key, as may a request whose server died before it completed.
+func (s *APIServer) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return makeHTTPHandlefunc(func(w http.ResponseWriter, r *http.Request) error {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return nil
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return &shared.UserError{Message: fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)}
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return &shared.UserError{Message: fmt.Sprintf("failed to read request: %v", err)}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+		scope := idempotencyScope(r)
+
+		now := time.Now()
+		record, claimed, err := s.store.BeginIdempotentRequest(scope, key, fingerprint, now.Add(-idempotencyKeyTTL), now.Add(-idempotencyDeadAfter))
+		if err != nil {
+			return &shared.InternalError{Message: err.Error()}
+		}
+		if !claimed {
+			if record.Fingerprint != fingerprint {
+				return &shared.UserError{Message: fmt.Sprintf("%s %s was already used for a different request", idempotencyKeyHeader, key)}
+			}
+			if !record.Completed() {
+				return &shared.ConflictError{Message: fmt.Sprintf("a request with %s %s is still in progress", idempotencyKeyHeader, key)}
+			}
+			w.Header().Set("Content-Type", record.ContentType)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.Status)
+			_, err := w.Write(record.Body)
+			return err
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		done := make(chan struct{})
+		go s.idempotencyHeartbeat(scope, key, done)
+		func() {
+			defer close(done)
+			next(recorder, r)
+		}()
+		if recorder.status >= http.StatusInternalServerError || recorder.status == 0 {
+			if err := s.store.ReleaseIdempotentRequest(scope, key); err != nil {
+				log.Printf("failed to release idempotency key %s: %v", key, err)
+			}
+			return nil
+		}
+		if err := s.store.CompleteIdempotentRequest(scope, key, recorder.status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			log.Printf("failed to store response for idempotency key %s: %v", key, err)
+		}
+		return nil
+	})
+}
+
+// idempotencyHeartbeat keeps the key of a running request claimed until done is closed.
+func (s *APIServer) idempotencyHeartbeat(scope, key string, done <-chan struct{}) {
+	ticker := time.NewTicker(idempotencyHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.store.HeartbeatIdempotentRequest(scope, key); err != nil {
+				log.Printf("failed to renew idempotency key %s: %v", key, err)
+			}
+		}
+	}
+}
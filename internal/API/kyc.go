@@ -0,0 +1,171 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"net/http"
+	"strings"
+)
+
+// KYCThresholds are the largest amounts, in the base currency, a player who isn't verified
+// may deposit, withdraw or stake in a single request.
+type KYCThresholds struct {
+	Deposit    shared.Money
+	Withdrawal shared.Money
+	Stake      shared.Money
+}
+
+var DefaultKYCThresholds = KYCThresholds{
+	Deposit:    1000 * shared.MoneyUnit,
+	Withdrawal: 0,
+	Stake:      500 * shared.MoneyUnit,
+}
+
+// requireVerified rejects amounts above threshold unless the player passed KYC verification.
+// amount is in the player's currency and is converted to the base currency for the check.
+func (s *APIServer) requireVerified(playerID int, amount, threshold shared.Money, action string) error {
+	player, err := s.store.GetPlayerByID(playerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", playerID)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", playerID, err)}
+	}
+	rate, err := s.exchangeRate(player.Currency)
+	if err != nil {
+		return err
+	}
+	if amount.ToBase(rate) <= threshold || player.KYCStatus == shared.KYCVerified {
+		return nil
+	}
+	return &shared.UserError{Message: fmt.Sprintf("%s above %s %s require a verified account", action, threshold, shared.BaseCurrency)}
+}
+
+func (s *APIServer) handlePlayerByID(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return s.handleGetPlayerByID(w, r)
+	case "PATCH":
+		return s.handleUpdateProfile(w, r)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+func (s *APIServer) handleUpdateProfile(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	updateReq := new(shared.UpdateProfileRequest)
+	if err := json.NewDecoder(r.Body).Decode(updateReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode profile data: %v", err)}
+	}
+	player, err := s.store.GetPlayerByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+
+	before := *player
+	if updateReq.Email != nil {
+		player.Email = strings.TrimSpace(*updateReq.Email)
+		if err := shared.ValidateEmail(player.Email); err != nil {
+			return err
+		}
+	}
+	if updateReq.FullName != nil {
+		player.FullName = strings.TrimSpace(*updateReq.FullName)
+	}
+	if updateReq.DateOfBirth != nil {
+		player.DateOfBirth = strings.TrimSpace(*updateReq.DateOfBirth)
+		if err := shared.ValidateDateOfBirth(player.DateOfBirth); err != nil {
+			return err
+		}
+	}
+	if updateReq.Address != nil {
+		player.Address = strings.TrimSpace(*updateReq.Address)
+	}
+	if updateReq.NationalID != nil {
+		player.NationalID = strings.TrimSpace(*updateReq.NationalID)
+		if err := shared.ValidateOIB(player.NationalID); err != nil {
+			return err
+		}
+	}
+
+	// Verified details can't change behind the back of the KYC check.
+	identityChanged := before.FullName != player.FullName || before.DateOfBirth != player.DateOfBirth ||
+		before.Address != player.Address || before.NationalID != player.NationalID
+	if identityChanged && (player.KYCStatus == shared.KYCPending || player.KYCStatus == shared.KYCVerified) {
+		player.KYCStatus = shared.KYCUnverified
+		player.KYCReason = "profile changed"
+	}
+	if err := s.store.UpdatePlayerProfile(player, before.KYCStatus); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.ConflictError{Message: fmt.Sprintf("kyc status of player %d changed, try again", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to update profile: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, player)
+}
+
+// handleSubmitKYC lets the player ask for verification once the profile is complete.
+func (s *APIServer) handleSubmitKYC(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	player, err := s.store.GetPlayerByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+	if !player.ProfileComplete() {
+		return &shared.UserError{Message: "email, full name, date of birth, address and OIB are required for verification"}
+	}
+	return s.setKYCStatus(w, player, shared.KYCPending, "")
+}
+
+func (s *APIServer) handleSetKYCStatus(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	statusReq := new(shared.KYCStatusRequest)
+	if err := json.NewDecoder(r.Body).Decode(statusReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode kyc data: %v", err)}
+	}
+	player, err := s.store.GetPlayerByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+	if statusReq.Status == shared.KYCVerified && !player.ProfileComplete() {
+		return &shared.UserError{Message: "player profile is incomplete"}
+	}
+	return s.setKYCStatus(w, player, statusReq.Status, statusReq.Reason)
+}
+
+func (s *APIServer) setKYCStatus(w http.ResponseWriter, player *shared.Player, status, reason string) error {
+	if !shared.CanTransitionKYC(player.KYCStatus, status) {
+		return &shared.UserError{Message: fmt.Sprintf("can't change kyc status from %s to %s", player.KYCStatus, status)}
+	}
+	if err := s.store.SetKYCStatus(player.ID, player.KYCStatus, status, reason); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: "kyc status was changed concurrently, try again"}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to set kyc status: %v", err)}
+	}
+	player.KYCStatus = status
+	player.KYCReason = reason
+	return WriteJSON(w, http.StatusOK, player)
+}
@@ -0,0 +1,67 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"net/http"
+)
+
+// handleLedgerAdjustment books a manual adjustment or bonus on the player's wallet.
+func (s *APIServer) handleLedgerAdjustment(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	adjustmentReq := new(shared.LedgerAdjustmentRequest)
+	if err := json.NewDecoder(r.Body).Decode(adjustmentReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode adjustment data: %v", err)}
+	}
+	if adjustmentReq.Reference == "" {
+		return &shared.UserError{Message: "adjustments need a reference explaining the change"}
+	}
+
+	player, err := s.store.GetPlayerByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+
+	var t *shared.LedgerTransaction
+	switch adjustmentReq.Type {
+	case shared.TxAdjustment:
+		t = shared.NewWalletTransaction(shared.TxAdjustment, id, shared.HouseAdjustmentsAccount, adjustmentReq.Amount,
+			player.Currency, adjustmentReq.Reference)
+	case shared.TxBonus:
+		if adjustmentReq.Amount <= 0 {
+			return &shared.UserError{Message: "bonus amount must be positive"}
+		}
+		t = shared.NewWalletTransaction(shared.TxBonus, id, shared.HouseBonusAccount, adjustmentReq.Amount,
+			player.Currency, adjustmentReq.Reference)
+	default:
+		return &shared.UserError{Message: fmt.Sprintf("type must be %s or %s", shared.TxAdjustment, shared.TxBonus)}
+	}
+	if err := t.Validate(); err != nil {
+		return &shared.UserError{Message: err.Error()}
+	}
+
+	if err := s.store.PostLedgerTransaction(t); err != nil {
+		if errors.Is(err, shared.ErrInsufficientFunds) {
+			return &shared.UserError{Message: "adjustment would make the balance negative"}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to post ledger transaction: %v", err)}
+	}
+	return WriteJSON(w, http.StatusCreated, t)
+}
+
+func (s *APIServer) handleReconcileBalances(w http.ResponseWriter, _ *http.Request) error {
+	mismatches, err := s.store.ReconcileBalances()
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to reconcile balances: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, mismatches)
+}
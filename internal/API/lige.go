@@ -0,0 +1,269 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"net/http"
+	"strings"
+)
+
+// ligeError turns the errors of the liga, razrada and tip storage methods into user errors
+// where the request is at fault.
+func ligeError(err error, action string) error {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, shared.ErrAlreadyExists) || errors.Is(err, shared.ErrInUse) {
+		return &shared.UserError{Message: err.Error()}
+	}
+	return &shared.InternalError{Message: fmt.Sprintf("failed to %s: %v", action, err)}
+}
+
+// checkPonude makes sure a razrada only lists existing ponude, each once.
+func (s *APIServer) checkPonude(ponude []int) error {
+	seen := make(map[int]bool, len(ponude))
+	for _, id := range ponude {
+		if seen[id] {
+			return &shared.UserError{Message: fmt.Sprintf("ponuda %d is listed more than once", id)}
+		}
+		seen[id] = true
+		if _, err := s.store.GetPonudaByID(id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &shared.UserError{Message: err.Error()}
+			}
+			return &shared.InternalError{Message: fmt.Sprintf("failed to get ponuda by id %d: %v", id, err)}
+		}
+	}
+	return nil
+}
+
+func (s *APIServer) handleCreateLiga(w http.ResponseWriter, r *http.Request) error {
+	ligaReq := new(shared.LigaRequest)
+	if err := json.NewDecoder(r.Body).Decode(ligaReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode liga data: %v", err)}
+	}
+	if err := ligaReq.Validate(); err != nil {
+		return err
+	}
+	id, err := s.store.CreateLiga(ligaReq.Naziv)
+	if err != nil {
+		return ligeError(err, "create liga")
+	}
+	liga, err := s.store.GetLiga(id)
+	if err != nil {
+		return ligeError(err, "get liga")
+	}
+	return WriteJSON(w, http.StatusCreated, liga)
+}
+
+// handleLiga gets, renames or deletes a liga. A liga can only be deleted once it has no razrade.
+func (s *APIServer) handleLiga(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid liga id: %v", err)}
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		ligaReq := new(shared.LigaRequest)
+		if err := json.NewDecoder(r.Body).Decode(ligaReq); err != nil {
+			return &shared.UserError{Message: fmt.Sprintf("failed to decode liga data: %v", err)}
+		}
+		if err := ligaReq.Validate(); err != nil {
+			return err
+		}
+		if err := s.store.UpdateLiga(id, ligaReq.Naziv); err != nil {
+			return ligeError(err, "update liga")
+		}
+	case http.MethodDelete:
+		if err := s.store.DeleteLiga(id); err != nil {
+			return ligeError(err, "delete liga")
+		}
+		return WriteJSON(w, http.StatusOK, id)
+	}
+
+	liga, err := s.store.GetLiga(id)
+	if err != nil {
+		return ligeError(err, "get liga")
+	}
+	return WriteJSON(w, http.StatusOK, liga)
+}
+
+func (s *APIServer) handleCreateRazrada(w http.ResponseWriter, r *http.Request) error {
+	ligaID, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid liga id: %v", err)}
+	}
+	razradaReq := new(shared.RazradaRequest)
+	if err := json.NewDecoder(r.Body).Decode(razradaReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode razrada data: %v", err)}
+	}
+	if err := s.checkPonude(razradaReq.Ponude); err != nil {
+		return err
+	}
+	id, err := s.store.CreateRazrada(ligaID, razradaReq.Ponude)
+	if err != nil {
+		return ligeError(err, "create razrada")
+	}
+	razrada, err := s.store.GetRazrada(id)
+	if err != nil {
+		return ligeError(err, "get razrada")
+	}
+	return WriteJSON(w, http.StatusCreated, razrada)
+}
+
+// handleRazrada gets, updates or deletes a razrada. An update can move the razrada to another
+// liga and replaces its ponude. A razrada with open bets on its ponude can't be deleted.
+func (s *APIServer) handleRazrada(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid razrada id: %v", err)}
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		razradaReq := new(shared.RazradaRequest)
+		if err := json.NewDecoder(r.Body).Decode(razradaReq); err != nil {
+			return &shared.UserError{Message: fmt.Sprintf("failed to decode razrada data: %v", err)}
+		}
+		if razradaReq.LigaID == 0 {
+			razrada, err := s.store.GetRazrada(id)
+			if err != nil {
+				return ligeError(err, "get razrada")
+			}
+			razradaReq.LigaID = razrada.LigaID
+		}
+		if err := s.checkPonude(razradaReq.Ponude); err != nil {
+			return err
+		}
+		if err := s.store.UpdateRazrada(id, razradaReq.LigaID, razradaReq.Ponude); err != nil {
+			return ligeError(err, "update razrada")
+		}
+	case http.MethodDelete:
+		if err := s.store.DeleteRazrada(id); err != nil {
+			return ligeError(err, "delete razrada")
+		}
+		return WriteJSON(w, http.StatusOK, id)
+	}
+
+	razrada, err := s.store.GetRazrada(id)
+	if err != nil {
+		return ligeError(err, "get razrada")
+	}
+	return WriteJSON(w, http.StatusOK, razrada)
+}
+
+// handleCreateTip adds a tip at the end of a razrada.
+func (s *APIServer) handleCreateTip(w http.ResponseWriter, r *http.Request) error {
+	razradaID, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid razrada id: %v", err)}
+	}
+	tip := new(shared.Tipovi)
+	if err := json.NewDecoder(r.Body).Decode(tip); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode tip data: %v", err)}
+	}
+	tip.Naziv = strings.TrimSpace(tip.Naziv)
+	if err := tip.Normalize(); err != nil {
+		return err
+	}
+	razrada, err := s.store.GetRazrada(razradaID)
+	if err != nil {
+		return ligeError(err, "get razrada")
+	}
+	if _, err := s.store.CreateTipovi(razradaID, len(razrada.Tipovi), *tip); err != nil {
+		return ligeError(err, "create tip")
+	}
+	if razrada, err = s.store.GetRazrada(razradaID); err != nil {
+		return ligeError(err, "get razrada")
+	}
+	return WriteJSON(w, http.StatusCreated, razrada)
+}
+
+// handleReorderLige changes the order lige are listed in.
+func (s *APIServer) handleReorderLige(w http.ResponseWriter, r *http.Request) error {
+	orderReq := new(shared.LigeOrderRequest)
+	if err := json.NewDecoder(r.Body).Decode(orderReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode lige order: %v", err)}
+	}
+	lige, err := s.store.GetLige()
+	if err != nil {
+		return ligeError(err, "get lige")
+	}
+	ids := make([]int, len(lige))
+	for i, liga := range lige {
+		ids[i] = liga.ID
+	}
+	if err := orderReq.Validate(ids); err != nil {
+		return err
+	}
+
+	if err := s.store.ReorderLige(orderReq.Lige); err != nil {
+		return ligeError(err, "reorder lige")
+	}
+	if lige, err = s.store.GetLige(); err != nil {
+		return ligeError(err, "get lige")
+	}
+	return WriteJSON(w, http.StatusOK, lige)
+}
+
+// handleReorderTipovi changes the order of the tipovi of a razrada, which is the order of the
+// columns of its odds grid.
+func (s *APIServer) handleReorderTipovi(w http.ResponseWriter, r *http.Request) error {
+	razradaID, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid razrada id: %v", err)}
+	}
+	orderReq := new(shared.TipoviOrderRequest)
+	if err := json.NewDecoder(r.Body).Decode(orderReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode tipovi order: %v", err)}
+	}
+	razrada, err := s.store.GetRazrada(razradaID)
+	if err != nil {
+		return ligeError(err, "get razrada")
+	}
+	ids := make([]int, len(razrada.Tipovi))
+	for i, tip := range razrada.Tipovi {
+		ids[i] = tip.ID
+	}
+	if err := orderReq.Validate(razradaID, ids); err != nil {
+		return err
+	}
+
+	if err := s.store.ReorderTipovi(razradaID, orderReq.Tipovi); err != nil {
+		return ligeError(err, "reorder tipovi")
+	}
+	if razrada, err = s.store.GetRazrada(razradaID); err != nil {
+		return ligeError(err, "get razrada")
+	}
+	return WriteJSON(w, http.StatusOK, razrada)
+}
+
+// handleTip renames or deletes a tip of a razrada.
+func (s *APIServer) handleTip(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid tip id: %v", err)}
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := s.store.DeleteTip(id); err != nil {
+			return ligeError(err, "delete tip")
+		}
+		return WriteJSON(w, http.StatusOK, id)
+	}
+
+	tip := new(shared.Tipovi)
+	if err := json.NewDecoder(r.Body).Decode(tip); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode tip data: %v", err)}
+	}
+	tip.Naziv = strings.TrimSpace(tip.Naziv)
+	if err := tip.Normalize(); err != nil {
+		return err
+	}
+	if err := s.store.UpdateTip(id, *tip); err != nil {
+		return ligeError(err, "update tip")
+	}
+	tip.ID = id
+	return WriteJSON(w, http.StatusOK, tip)
+}
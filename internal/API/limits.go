@@ -0,0 +1,122 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// betLimitViolations returns the betting limits configured for the player, leagues and events a
+// ticket touches that the ticket breaks. stake and payout are in the base currency.
+func (s *APIServer) betLimitViolations(playerID int, stake, payout shared.Money, selections []*shared.BetSelection) ([]shared.LimitViolation, error) {
+	var ligaIDs, ponudaIDs []int
+	for _, sel := range selections {
+		ligaIDs = append(ligaIDs, sel.LigaID)
+		ponudaIDs = append(ponudaIDs, sel.Ponuda)
+	}
+	rules, err := s.store.GetBetLimitRules(playerID, ligaIDs, ponudaIDs)
+	if err != nil {
+		return nil, &shared.InternalError{Message: fmt.Sprintf("failed to get bet limits: %v", err)}
+	}
+	return shared.CheckBetLimits(rules, playerID, stake, payout, selections), nil
+}
+
+func (s *APIServer) handleGetBetLimits(w http.ResponseWriter, _ *http.Request) error {
+	rules, err := s.store.GetAllBetLimitRules()
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get bet limits: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, rules)
+}
+
+func (s *APIServer) handleBetLimits(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "PUT":
+		return s.handleSetBetLimits(w, r)
+	case "DELETE":
+		return s.handleDeleteBetLimits(w, r)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+// betLimitScope reads the scope and scope id from the route. The global scope has no id.
+func betLimitScope(r *http.Request) (string, int, error) {
+	scope := mux.Vars(r)["scope"]
+	if scope == "" {
+		return shared.LimitScopeGlobal, 0, nil
+	}
+	if !shared.IsLimitScope(scope) {
+		return "", 0, &shared.UserError{Message: fmt.Sprintf("unknown limit scope %s", scope)}
+	}
+	id, err := getID(r)
+	if err != nil {
+		return "", 0, &shared.UserError{Message: fmt.Sprintf("invalid %s id: %s", scope, mux.Vars(r)["id"])}
+	}
+	return scope, id, nil
+}
+
+// handleSetBetLimits replaces the limits of a scope. Limits left out are inherited.
+func (s *APIServer) handleSetBetLimits(w http.ResponseWriter, r *http.Request) error {
+	scope, scopeID, err := betLimitScope(r)
+	if err != nil {
+		return err
+	}
+	limits := new(shared.BetLimits)
+	if err := json.NewDecoder(r.Body).Decode(limits); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode limits data: %v", err)}
+	}
+	if err := validateBetLimits(limits); err != nil {
+		return err
+	}
+	rule := &shared.BetLimitRule{Scope: scope, ScopeID: scopeID, BetLimits: *limits}
+	if err := s.store.SetBetLimitRule(rule); err != nil {
+		return &shared.InternalError{Message: err.Error()}
+	}
+	return WriteJSON(w, http.StatusOK, rule)
+}
+
+func (s *APIServer) handleDeleteBetLimits(w http.ResponseWriter, r *http.Request) error {
+	scope, scopeID, err := betLimitScope(r)
+	if err != nil {
+		return err
+	}
+	if err := s.store.DeleteBetLimitRule(scope, scopeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to delete bet limits: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, scopeID)
+}
+
+func validateBetLimits(l *shared.BetLimits) error {
+	for name, m := range map[string]*shared.Money{
+		shared.LimitMinStake:  l.MinStake,
+		shared.LimitMaxStake:  l.MaxStake,
+		shared.LimitMaxPayout: l.MaxPayout,
+	} {
+		if m != nil && *m < 0 {
+			return &shared.UserError{Message: fmt.Sprintf("%s can't be negative", name)}
+		}
+	}
+	if l.MinStake != nil && l.MaxStake != nil && *l.MinStake > *l.MaxStake {
+		return &shared.UserError{Message: "min_stake can't be above max_stake"}
+	}
+	if l.MaxSelections != nil && *l.MaxSelections < 1 {
+		return &shared.UserError{Message: "max_selections must be at least 1"}
+	}
+	for name, o := range map[string]*shared.Odds{
+		shared.LimitMinSelectionOdds: l.MinSelectionOdds,
+		shared.LimitMinTicketOdds:    l.MinTicketOdds,
+	} {
+		if o != nil && *o < shared.OddsOne {
+			return &shared.UserError{Message: fmt.Sprintf("%s must be at least %s", name, shared.OddsOne)}
+		}
+	}
+	return nil
+}
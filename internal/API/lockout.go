@@ -0,0 +1,141 @@
+package API
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LoginPolicy controls how failed logins are throttled. After FreeAttempts failures every
+// further attempt has to wait BaseDelay, doubled per failure up to MaxDelay. Reaching the
+// lock threshold locks the username or IP for LockoutDuration. Failures older than
+// LockoutDuration are forgotten.
+type LoginPolicy struct {
+	FreeAttempts      int
+	BaseDelay         time.Duration
+	MaxDelay          time.Duration
+	UsernameLockAfter int
+	IPLockAfter       int
+	LockoutDuration   time.Duration
+}
+
+var DefaultLoginPolicy = LoginPolicy{
+	FreeAttempts:      3,
+	BaseDelay:         2 * time.Second,
+	MaxDelay:          2 * time.Minute,
+	UsernameLockAfter: 10,
+	IPLockAfter:       50,
+	LockoutDuration:   15 * time.Minute,
+}
+
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.FreeAttempts; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func (p LoginPolicy) lockAfter(scope string) int {
+	if scope == shared.LoginScopeIP {
+		return p.IPLockAfter
+	}
+	return p.UsernameLockAfter
+}
+
+func loginThrottleKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *APIServer) checkLoginThrottle(scope, key string) error {
+	throttle, err := s.store.GetLoginThrottle(scope, key)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to check login attempts: %v", err)}
+	}
+	now := time.Now()
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return &shared.RateLimitError{
+			Message:    "too many failed login attempts, account temporarily locked",
+			RetryAfter: throttle.LockedUntil.Sub(now),
+		}
+	}
+	if throttle.Failures == 0 || throttle.LastFailure.Before(now.Add(-s.loginPolicy.LockoutDuration)) {
+		return nil
+	}
+	if retry := throttle.LastFailure.Add(s.loginPolicy.delay(throttle.Failures)).Sub(now); retry > 0 {
+		return &shared.RateLimitError{
+			Message:    "too many failed login attempts, try again later",
+			RetryAfter: retry,
+		}
+	}
+	return nil
+}
+
+func (s *APIServer) recordLoginFailure(scope, key string) {
+	now := time.Now()
+	throttle, err := s.store.RecordLoginFailure(scope, key, now, now.Add(-s.loginPolicy.LockoutDuration))
+	if err != nil {
+		log.Printf("failed to record login failure for %s %s: %v", scope, key, err)
+		return
+	}
+	if throttle.Failures >= s.loginPolicy.lockAfter(scope) {
+		if err := s.store.LockLogin(scope, key, now.Add(s.loginPolicy.LockoutDuration)); err != nil {
+			log.Printf("failed to lock login for %s %s: %v", scope, key, err)
+			return
+		}
+		log.Printf("Login locked for %s %s after %d failed attempts", scope, key, throttle.Failures)
+	}
+}
+
+// recordLoginFailures counts a failed login, or a failed check of a code, against the username
+// and the client's IP.
+func (s *APIServer) recordLoginFailures(r *http.Request, username string) {
+	s.recordLoginFailure(shared.LoginScopeIP, clientIP(r))
+	s.recordLoginFailure(shared.LoginScopeUsername, loginThrottleKey(username))
+}
+
+// handleUnlockPlayer clears the lockouts of a player's username and of the IPs the player has
+// signed in from. IPs the player only failed from can be passed as ip query parameters.
+func (s *APIServer) handleUnlockPlayer(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	player, err := s.store.GetPlayerByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+	ips := r.URL.Query()["ip"]
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			return &shared.UserError{Message: fmt.Sprintf("invalid ip %q", ip)}
+		}
+	}
+	if err := s.store.UnlockPlayerLogins(player.ID, loginThrottleKey(player.Username), ips); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to unlock player: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, player)
+}
@@ -0,0 +1,31 @@
+package API
+
+import (
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"log"
+	"net/http"
+)
+
+// handleGetRazradaMismatches lists the ponude whose tecajevi don't match the tipovi defined for
+// their razrada.
+func (s *APIServer) handleGetRazradaMismatches(w http.ResponseWriter, _ *http.Request) error {
+	mismatches, err := s.store.GetRazradaMismatches()
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to check razrade: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, mismatches)
+}
+
+// logRazradaMismatches reports the ponude that don't offer the tipovi of their razrada after
+// the feeds were imported.
+func (s *APIServer) logRazradaMismatches() {
+	mismatches, err := s.store.GetRazradaMismatches()
+	if err != nil {
+		log.Printf("failed to check razrade: %v", err)
+		return
+	}
+	for _, m := range mismatches {
+		log.Printf("ponuda %d doesn't match razrada %d: missing %v, unexpected %v", m.Ponuda, m.RazradaID, m.Missing, m.Unexpected)
+	}
+}
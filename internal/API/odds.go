@@ -0,0 +1,184 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// handleGetTecajHistory lists the odds changes of a ponuda, optionally of one tip (naziv) and
+// between from and to.
+func (s *APIServer) handleGetTecajHistory(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	query := r.URL.Query()
+	period, err := parseStatementFilter(query.Get("from"), query.Get("to"), "")
+	if err != nil {
+		return err
+	}
+	filter := shared.TecajHistoryFilter{Naziv: query.Get("naziv"), From: period.From, To: period.To}
+	history, err := s.store.GetTecajHistory(id, filter)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get odds history: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, history)
+}
+
+// oddsNotifier wakes up the clients waiting for odds changes.
+type oddsNotifier struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+func newOddsNotifier() *oddsNotifier {
+	return &oddsNotifier{changed: make(chan struct{})}
+}
+
+// wait returns a channel that is closed on the next change.
+func (n *oddsNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.changed
+}
+
+func (n *oddsNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+const (
+	oddsChangesPageSize = 500
+	oddsChangesWait     = 25 * time.Second
+)
+
+// handleGetTecajChanges long-polls for odds changes after the since cursor, so open slips can
+// follow price changes and suspensions. Without since it returns the current cursor.
+func (s *APIServer) handleGetTecajChanges(w http.ResponseWriter, r *http.Request) error {
+	if r.URL.Query().Get("since") == "" {
+		cursor, err := s.store.LastTecajChangeID()
+		if err != nil {
+			return &shared.InternalError{Message: fmt.Sprintf("failed to get odds changes: %v", err)}
+		}
+		return WriteJSON(w, http.StatusOK, shared.TecajChangeFeed{Changes: []*shared.TecajChange{}, Cursor: cursor})
+	}
+	since, err := strconv.Atoi(r.URL.Query().Get("since"))
+	if err != nil || since < 0 {
+		return &shared.UserError{Message: fmt.Sprintf("invalid since %s", r.URL.Query().Get("since"))}
+	}
+
+	timeout := time.NewTimer(oddsChangesWait)
+	defer timeout.Stop()
+	for {
+		changed := s.oddsChanges.wait()
+		changes, err := s.store.GetTecajChanges(since, oddsChangesPageSize)
+		if err != nil {
+			return &shared.InternalError{Message: fmt.Sprintf("failed to get odds changes: %v", err)}
+		}
+		if len(changes) > 0 {
+			return WriteJSON(w, http.StatusOK, shared.TecajChangeFeed{Changes: changes, Cursor: changes[len(changes)-1].ID})
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return WriteJSON(w, http.StatusOK, shared.TecajChangeFeed{Changes: changes, Cursor: since})
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
+
+// updateTecajevi applies a trader's updates and wakes up the clients following the odds.
+func (s *APIServer) updateTecajevi(w http.ResponseWriter, updates []shared.TecajUpdate, reason string) error {
+	for i := range updates {
+		if err := updates[i].Validate(); err != nil {
+			return err
+		}
+	}
+	changes, err := s.store.UpdateTecajevi(updates, shared.OddsSourceTrader, reason)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to update odds: %v", err)}
+	}
+	if len(changes) > 0 {
+		s.oddsChanges.notify()
+	}
+	return WriteJSON(w, http.StatusOK, changes)
+}
+
+// handleTecaj sets the odds of a tip. PUT sets the odds and unsuspends the tip unless suspended
+// is given, PATCH changes only what is given.
+func (s *APIServer) handleTecaj(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid tecaj id: %v", err)}
+	}
+	tecajReq := new(shared.TecajRequest)
+	if err := json.NewDecoder(r.Body).Decode(tecajReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode tecaj data: %v", err)}
+	}
+	if r.Method == http.MethodPut {
+		if tecajReq.Tecaj == nil {
+			return &shared.UserError{Message: "tecaj is required"}
+		}
+		if tecajReq.Suspended == nil {
+			suspended := false
+			tecajReq.Suspended = &suspended
+		}
+	}
+	update := shared.TecajUpdate{ID: id, Tecaj: tecajReq.Tecaj, Suspended: tecajReq.Suspended}
+	return s.updateTecajevi(w, []shared.TecajUpdate{update}, tecajReq.Reason)
+}
+
+// handleBulkTecajevi applies many odds updates, on any number of ponude, all or nothing.
+func (s *APIServer) handleBulkTecajevi(w http.ResponseWriter, r *http.Request) error {
+	bulkReq := new(shared.BulkTecajRequest)
+	if err := json.NewDecoder(r.Body).Decode(bulkReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode tecaj data: %v", err)}
+	}
+	if len(bulkReq.Updates) == 0 {
+		return &shared.UserError{Message: "no updates given"}
+	}
+	return s.updateTecajevi(w, bulkReq.Updates, bulkReq.Reason)
+}
+
+// handleSuspendPonuda suspends or unsuspends a whole ponuda or one of its markets.
+func (s *APIServer) handleSuspendPonuda(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	suspendReq := new(shared.SuspendRequest)
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(suspendReq); err != nil {
+			return &shared.UserError{Message: fmt.Sprintf("failed to decode suspension data: %v", err)}
+		}
+	}
+	if suspendReq.Market == "" && suspendReq.Line != nil {
+		return &shared.UserError{Message: "a line needs a market"}
+	}
+
+	changes, err := s.store.SuspendPonuda(id, suspendReq, mux.Vars(r)["action"] == "suspend")
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to suspend ponuda %d: %v", id, err)}
+	}
+	if len(changes) > 0 {
+		s.oddsChanges.notify()
+	}
+	return WriteJSON(w, http.StatusOK, changes)
+}
@@ -0,0 +1,106 @@
+package API
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/payment"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"net/http"
+)
+
+const maxCallbackSize = 1 << 20
+
+// RegisterPaymentProvider makes the provider available for deposits. The first registered
+// provider is used when a deposit doesn't name one. It has to be called before Run. Deposits
+// are refused while no provider is registered; the fake provider of WithFakePayments counts,
+// but is never the default.
+func (s *APIServer) RegisterPaymentProvider(provider shared.PaymentProvider) {
+	s.paymentProviders[provider.Name()] = provider
+	if s.defaultPaymentProvider == "" {
+		s.defaultPaymentProvider = provider.Name()
+	}
+}
+
+func newFakePaymentProvider() *payment.FakeProvider {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal("failed to generate fake payment provider secret: ", err)
+	}
+	return payment.NewFakeProvider(secret, "/api/payments/fake/checkout/")
+}
+
+func (s *APIServer) handlePaymentWebhook(w http.ResponseWriter, r *http.Request) error {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackSize))
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to read callback: %v", err)}
+	}
+	intent, err := s.processPaymentCallback(mux.Vars(r)["provider"], payload, r.Header)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, intent)
+}
+
+// processPaymentCallback verifies and applies a provider callback. Repeated callbacks for the
+// same payment are acknowledged without crediting the player again.
+func (s *APIServer) processPaymentCallback(providerName string, payload []byte, header http.Header) (*shared.PaymentIntent, error) {
+	provider, ok := s.paymentProviders[providerName]
+	if !ok {
+		return nil, &shared.UserError{Message: fmt.Sprintf("unknown payment provider %s", providerName)}
+	}
+	if err := provider.VerifySignature(payload, header); err != nil {
+		return nil, &shared.UserError{Message: err.Error()}
+	}
+	callback, err := provider.ParseCallback(payload)
+	if err != nil {
+		return nil, &shared.UserError{Message: err.Error()}
+	}
+
+	intent, applied, err := s.store.CompletePaymentIntent(provider.Name(), callback)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &shared.UserError{Message: fmt.Sprintf("payment %s not found", callback.ProviderRef)}
+		}
+		return nil, &shared.InternalError{Message: fmt.Sprintf("failed to complete payment: %v", err)}
+	}
+	if !applied {
+		log.Printf("Ignoring repeated %s callback for payment %s, already %s", provider.Name(), intent.ProviderRef, intent.Status)
+	}
+	return intent, nil
+}
+
+// handleFakeCheckout stands in for the provider's checkout page: it pays the intent and
+// delivers the signed callback the fake provider would send.
+func (s *APIServer) handleFakeCheckout(w http.ResponseWriter, r *http.Request) error {
+	fake, ok := s.paymentProviders[payment.FakeProviderName].(*payment.FakeProvider)
+	if !ok {
+		return &shared.UserError{Message: "fake payment provider is not enabled"}
+	}
+	ref := mux.Vars(r)["ref"]
+	intent, err := s.store.GetPaymentIntent(fake.Name(), ref)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("payment %s not found", ref)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get payment: %v", err)}
+	}
+
+	status := shared.PaymentSucceeded
+	if r.URL.Query().Get("status") == shared.PaymentFailed {
+		status = shared.PaymentFailed
+	}
+	payload, header, err := fake.Complete(intent.ProviderRef, intent.Amount, status)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to complete fake payment: %v", err)}
+	}
+	intent, err = s.processPaymentCallback(fake.Name(), payload, header)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, intent)
+}
@@ -0,0 +1,95 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"log"
+	"net/http"
+)
+
+func (s *APIServer) handlePonuda(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		return s.handleGetPonuda(w, r)
+	case http.MethodPut, http.MethodPatch:
+		return s.handleUpdatePonuda(w, r)
+	case http.MethodDelete:
+		return s.handleDeletePonuda(w, r)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+// handleUpdatePonuda edits the naziv, vrijeme, tv kanal and statistics flag of a ponuda. Moving
+// the vrijeme into the future reopens a ponuda for betting and moving it into the past closes it.
+func (s *APIServer) handleUpdatePonuda(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	updateReq := new(shared.UpdatePonudaRequest)
+	if err := json.NewDecoder(r.Body).Decode(updateReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode ponuda data: %v", err)}
+	}
+
+	ponuda, err := s.store.GetPonudaByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("ponuda with id %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get ponuda by id %d: %v", id, err)}
+	}
+	if err := updateReq.Apply(ponuda, r.Method == http.MethodPut); err != nil {
+		return err
+	}
+	if err := s.store.UpdatePonuda(ponuda); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("ponuda with id %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to update ponuda %d: %v", id, err)}
+	}
+	return s.handleGetPonuda(w, r)
+}
+
+// handleWithdrawPonuda takes a ponuda off the offer, voids the open bets on it and settles the
+// tickets that no longer have open bets. If settling fails, handleSettlePonuda finishes it.
+func (s *APIServer) handleWithdrawPonuda(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	uplataIDs, err := s.store.WithdrawPonuda(id)
+	if err != nil {
+		if errors.Is(err, shared.ErrResultExists) {
+			return &shared.UserError{Message: fmt.Sprintf("%v, settle its open tickets through /api/admin/ponude/%d/settle", err, id)}
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to withdraw ponuda %d: %v", id, err)}
+	}
+	settled, err := s.settleUplate(uplataIDs)
+	if err != nil {
+		log.Printf("ponuda %d is withdrawn but settling its tickets failed, rerun it through /api/admin/ponude/%d/settle", id, id)
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, shared.SettlementSummary{PonudaID: id, Settled: settled})
+}
+
+// handleDeletePonuda deletes a ponuda nobody bet on. Ponude with bets can only be withdrawn.
+func (s *APIServer) handleDeletePonuda(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	if err := s.store.DeletePonuda(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, shared.ErrInUse) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to delete ponuda %d: %v", id, err)}
+	}
+	return WriteJSON(w, http.StatusOK, id)
+}
@@ -0,0 +1,110 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"log"
+	"net/http"
+)
+
+func (s *APIServer) handleGetMarginRules(w http.ResponseWriter, _ *http.Request) error {
+	rules, err := s.store.GetMarginRules()
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get margin rules: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, rules)
+}
+
+// handleMarginRule sets or deletes the margin of a liga and market. The margin applies from the
+// next feed import.
+func (s *APIServer) handleMarginRule(w http.ResponseWriter, r *http.Request) error {
+	rule := new(shared.MarginRule)
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode margin rule: %v", err)}
+	}
+
+	switch r.Method {
+	case "PUT":
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if rule.LigaID != 0 {
+			if _, err := s.store.GetLiga(rule.LigaID); err != nil {
+				return ligeError(err, "get liga")
+			}
+		}
+		if err := s.store.SetMarginRule(rule); err != nil {
+			return &shared.InternalError{Message: err.Error()}
+		}
+		return WriteJSON(w, http.StatusOK, rule)
+	case "DELETE":
+		if err := s.store.DeleteMarginRule(rule.LigaID, rule.Market); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &shared.UserError{Message: err.Error()}
+			}
+			return &shared.InternalError{Message: fmt.Sprintf("failed to delete margin rule: %v", err)}
+		}
+		return WriteJSON(w, http.StatusOK, rule)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+// handleGetOverround lists the books of a ponuda with the overround of its current odds.
+func (s *APIServer) handleGetOverround(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	ponuda, err := s.store.GetPonuda(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("ponuda with id %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get ponuda by id %d: %v", id, err)}
+	}
+	return WriteJSON(w, http.StatusOK, shared.Books(ponuda.Tecajevi))
+}
+
+// feedPricer prices the odds of the ponude in a feed with the margin rules in force when the
+// import started.
+type feedPricer struct {
+	rules []*shared.MarginRule
+	// lige maps a ponuda to the liga of its first razrada.
+	lige map[int]int
+}
+
+func (s *APIServer) newFeedPricer() (*feedPricer, error) {
+	rules, err := s.store.GetMarginRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get margin rules: %v", err)
+	}
+	lige, err := s.store.GetLige()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lige: %v", err)
+	}
+	p := &feedPricer{rules: rules, lige: make(map[int]int)}
+	for _, liga := range lige {
+		for _, razrada := range liga.Razrade {
+			for _, ponuda := range razrada.Ponude {
+				if _, ok := p.lige[ponuda]; !ok {
+					p.lige[ponuda] = liga.ID
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// price returns the priced tecajevi of the ponuda. Books rejected as an arbitrage are logged and
+// keep the odds they had before the import.
+func (p *feedPricer) price(ponudaID int, tecajevi []shared.Tecajevi) []shared.Tecajevi {
+	priced, rejected := shared.PriceTecajevi(tecajevi, p.rules, p.lige[ponudaID])
+	for _, book := range rejected {
+		log.Printf("rejecting %s market of ponuda %d: the odds of %v add up to an overround of %s", book.Market, ponudaID, book.Tipovi, book.Overround)
+	}
+	return priced
+}
@@ -0,0 +1,87 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"net/http"
+)
+
+// priceSlip prices a slip at the current odds, including the handling fee and tax, and checks it
+// against the slip rules, betting limits and exposure thresholds exactly as placing it would. A playerID of 0 prices
+// the slip in the base currency without player overrides.
+func (s *APIServer) priceSlip(playerID int, req *shared.CreateUplataRequest) (*shared.SlipQuote, error) {
+	if len(req.OdigraniPar) == 0 {
+		return nil, &shared.UserError{Message: "a ticket needs at least one selection"}
+	}
+	if req.Amount <= 0 {
+		return nil, &shared.UserError{Message: "stake must be positive"}
+	}
+	for i := range req.OdigraniPar {
+		if err := req.OdigraniPar[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	currency := shared.BaseCurrency
+	if playerID != 0 {
+		player, err := s.store.GetPlayerByID(playerID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", playerID)}
+			}
+			return nil, &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", playerID, err)}
+		}
+		currency = player.Currency
+	}
+	rate, err := s.exchangeRate(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	selections, err := s.store.GetBetSelections(req.OdigraniPar)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &shared.UserError{Message: err.Error()}
+		}
+		return nil, &shared.InternalError{Message: fmt.Sprintf("failed to get tecaj: %v", err)}
+	}
+	selectionErrors, err := s.slipErrors(selections)
+	if err != nil {
+		return nil, err
+	}
+	breakdown := s.fiscalRules.Breakdown(req.Amount, shared.TicketOdds(selections), rate)
+	violations, err := s.betLimitViolations(playerID, breakdown.Stake.ToBase(rate), breakdown.PotentialPayout.ToBase(rate), selections)
+	if err != nil {
+		return nil, err
+	}
+	referred, err := s.referTicket(breakdown.Stake.ToBase(rate), breakdown.PotentialPayout.ToBase(rate), selections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shared.SlipQuote{
+		Currency:        currency,
+		Selections:      selections,
+		FiscalBreakdown: breakdown,
+		SelectionErrors: selectionErrors,
+		Violations:      violations,
+		Placeable:       len(selectionErrors) == 0 && len(violations) == 0,
+		Referred:        referred,
+	}, nil
+}
+
+// handleQuote prices a slip without placing it or touching the balance.
+func (s *APIServer) handleQuote(w http.ResponseWriter, r *http.Request) error {
+	quoteReq := new(shared.QuoteRequest)
+	if err := json.NewDecoder(r.Body).Decode(quoteReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode uplata data: %v", err)}
+	}
+	quote, err := s.priceSlip(quoteReq.PlayerID, &quoteReq.CreateUplataRequest)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, quote)
+}
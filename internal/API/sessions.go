@@ -0,0 +1,184 @@
+package API
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxUserAgentLength = 512
+
+// SessionPolicy controls how long sessions last. A session expires after IdleTimeout without
+// requests, and MaxAge after login however active it is.
+type SessionPolicy struct {
+	IdleTimeout time.Duration
+	MaxAge      time.Duration
+}
+
+var DefaultSessionPolicy = SessionPolicy{
+	IdleTimeout: 30 * time.Minute,
+	MaxAge:      12 * time.Hour,
+}
+
+// cutoffs returns the last activity and creation times a session must be after to be live now.
+func (p SessionPolicy) cutoffs(now time.Time) (idleSince, createdSince time.Time) {
+	return now.Add(-p.IdleTimeout), now.Add(-p.MaxAge)
+}
+
+type sessionContextKey struct{}
+
+// requestSession returns the live session the request was made with, or nil.
+func requestSession(r *http.Request) *shared.Session {
+	session, _ := r.Context().Value(sessionContextKey{}).(*shared.Session)
+	return session
+}
+
+func generateSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+func (s *APIServer) createSession(r *http.Request, player *shared.Player) (*shared.LoginResponse, error) {
+	token, err := generateSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	session := &shared.Session{
+		PlayerID:  player.ID,
+		UserAgent: userAgent,
+		IP:        clientIP(r),
+	}
+	if err := s.store.CreateSession(session, hashSessionToken(token)); err != nil {
+		return nil, err
+	}
+	return &shared.LoginResponse{Player: player, SessionToken: token}, nil
+}
+
+// trackSession records activity for requests carrying a session token and keeps the session in
+// the request context. Requests whose session expired or was revoked are rejected.
+func (s *APIServer) trackSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		idleSince, createdSince := s.sessionPolicy.cutoffs(time.Now())
+		session, err := s.store.TouchSession(hashSessionToken(token), idleSince, createdSince)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				_ = WriteJSON(w, http.StatusUnauthorized, APIError{Error: "session expired or revoked"})
+				return
+			}
+			log.Printf("failed to track session: %v", err)
+			_ = WriteJSON(w, http.StatusInternalServerError, APIError{Error: "failed to check session"})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session)))
+	})
+}
+
+// requirePlayerSession only lets requests through that were made with a live session of the
+// player in the path.
+func (s *APIServer) requirePlayerSession(next http.HandlerFunc) http.HandlerFunc {
+	return makeHTTPHandlefunc(func(w http.ResponseWriter, r *http.Request) error {
+		id, err := getID(r)
+		if err != nil {
+			return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+		}
+		session := requestSession(r)
+		if session == nil {
+			return &shared.UnauthorizedError{Message: "session required"}
+		}
+		if session.PlayerID != id {
+			return &shared.ForbiddenError{Message: fmt.Sprintf("session doesn't belong to player %d", id)}
+		}
+		next(w, r)
+		return nil
+	})
+}
+
+func (s *APIServer) handleSessions(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return s.handleGetSessions(w, r)
+	case "DELETE":
+		return s.handleRevokeSessions(w, r)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+func (s *APIServer) handleGetSessions(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	idleSince, createdSince := s.sessionPolicy.cutoffs(time.Now())
+	sessions, err := s.store.GetSessions(id, idleSince, createdSince)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get sessions: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, sessions)
+}
+
+// handleRevokeSessions logs the player out everywhere.
+func (s *APIServer) handleRevokeSessions(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	if err := s.store.RevokeSessions(id); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to revoke sessions: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, id)
+}
+
+func (s *APIServer) handleRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	sessionID, err := strconv.Atoi(mux.Vars(r)["sessionID"])
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid session id: %s", mux.Vars(r)["sessionID"])}
+	}
+	if err := s.store.RevokeSession(id, sessionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("session with id %d not found", sessionID)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to revoke session: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, sessionID)
+}
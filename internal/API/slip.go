@@ -0,0 +1,111 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"net/http"
+	"strings"
+)
+
+// SlipPolicy configures the slip rules that aren't stored with the other correlation rules.
+type SlipPolicy struct {
+	// AllowSameEvent permits more than one selection on an event as long as the tipovi
+	// aren't mutually exclusive.
+	AllowSameEvent bool
+}
+
+var DefaultSlipPolicy = SlipPolicy{
+	AllowSameEvent: false,
+}
+
+func (s *APIServer) slipRules() (*shared.SlipRules, error) {
+	rules, err := s.store.GetSlipRules()
+	if err != nil {
+		return nil, &shared.InternalError{Message: fmt.Sprintf("failed to get slip rules: %v", err)}
+	}
+	rules.AllowSameEvent = s.slipPolicy.AllowSameEvent
+	return rules, nil
+}
+
+// slipErrors returns the selections that can't be combined with the rest of the slip.
+func (s *APIServer) slipErrors(selections []*shared.BetSelection) ([]shared.SelectionError, error) {
+	rules, err := s.slipRules()
+	if err != nil {
+		return nil, err
+	}
+	return shared.ValidateSlip(rules, selections), nil
+}
+
+func (s *APIServer) handleGetSlipRules(w http.ResponseWriter, _ *http.Request) error {
+	rules, err := s.slipRules()
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, rules)
+}
+
+func (s *APIServer) handleExclusiveTips(w http.ResponseWriter, r *http.Request) error {
+	tips := new(shared.ExclusiveTips)
+	if err := json.NewDecoder(r.Body).Decode(tips); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode exclusive tipovi data: %v", err)}
+	}
+	tips.TipA, tips.TipB = strings.TrimSpace(tips.TipA), strings.TrimSpace(tips.TipB)
+	if tips.TipA == "" || tips.TipB == "" || tips.TipA == tips.TipB {
+		return &shared.UserError{Message: "tip_a and tip_b must be two different tipovi"}
+	}
+	if len(tips.TipA) > shared.MaxTipNameLength || len(tips.TipB) > shared.MaxTipNameLength {
+		return &shared.UserError{Message: fmt.Sprintf("tipovi names are at most %d characters long", shared.MaxTipNameLength)}
+	}
+
+	switch r.Method {
+	case "POST":
+		if err := s.store.AddExclusiveTips(*tips); err != nil {
+			return &shared.InternalError{Message: fmt.Sprintf("failed to add exclusive tipovi: %v", err)}
+		}
+		return WriteJSON(w, http.StatusCreated, tips)
+	case "DELETE":
+		if err := s.store.DeleteExclusiveTips(*tips); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &shared.UserError{Message: fmt.Sprintf("tipovi %s and %s aren't exclusive", tips.TipA, tips.TipB)}
+			}
+			return &shared.InternalError{Message: fmt.Sprintf("failed to delete exclusive tipovi: %v", err)}
+		}
+		return WriteJSON(w, http.StatusOK, tips)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+func (s *APIServer) handleLeagueBans(w http.ResponseWriter, r *http.Request) error {
+	ban := new(shared.LeagueCombinationBan)
+	if err := json.NewDecoder(r.Body).Decode(ban); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode league ban data: %v", err)}
+	}
+	if ban.LigaA <= 0 || ban.LigaB <= 0 {
+		return &shared.UserError{Message: "liga_a and liga_b are required"}
+	}
+
+	switch r.Method {
+	case "POST":
+		if err := s.store.AddLeagueCombinationBan(*ban); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &shared.UserError{Message: err.Error()}
+			}
+			return &shared.InternalError{Message: fmt.Sprintf("failed to add league ban: %v", err)}
+		}
+		return WriteJSON(w, http.StatusCreated, ban)
+	case "DELETE":
+		if err := s.store.DeleteLeagueCombinationBan(*ban); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &shared.UserError{Message: fmt.Sprintf("leagues %d and %d may already be combined", ban.LigaA, ban.LigaB)}
+			}
+			return &shared.InternalError{Message: fmt.Sprintf("failed to delete league ban: %v", err)}
+		}
+		return WriteJSON(w, http.StatusOK, ban)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
@@ -0,0 +1,128 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStatementLimit = 50
+	maxStatementLimit     = 500
+)
+
+// handleGetStatement serves the player's transactions as JSON, or as CSV with ?format=csv.
+// Supported filters are from and to (RFC 3339 or YYYY-MM-DD, to is inclusive for dates),
+// type (comma separated), limit and offset.
+func (s *APIServer) handleGetStatement(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	query := r.URL.Query()
+	asCSV := query.Get("format") == "csv" || r.Header.Get("Accept") == "text/csv"
+
+	filter, err := parseStatementFilter(query.Get("from"), query.Get("to"), query.Get("type"))
+	if err != nil {
+		return err
+	}
+	if !asCSV {
+		filter.Limit = defaultStatementLimit
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > maxStatementLimit {
+			return &shared.UserError{Message: fmt.Sprintf("limit must be between 1 and %d", maxStatementLimit)}
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			return &shared.UserError{Message: "offset must be a non-negative number"}
+		}
+	}
+
+	if _, err := s.store.GetPlayerByID(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+	statement, err := s.store.GetStatement(id, filter)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get statement: %v", err)}
+	}
+
+	if asCSV {
+		return writeStatementCSV(w, statement)
+	}
+	return WriteJSON(w, http.StatusOK, statement)
+}
+
+func parseStatementFilter(from, to, types string) (shared.StatementFilter, error) {
+	var filter shared.StatementFilter
+	if from != "" {
+		t, _, err := parseStatementTime(from)
+		if err != nil {
+			return filter, err
+		}
+		filter.From = &t
+	}
+	if to != "" {
+		t, dateOnly, err := parseStatementTime(to)
+		if err != nil {
+			return filter, err
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = &t
+	}
+	if types != "" {
+		for _, txType := range strings.Split(types, ",") {
+			txType = strings.TrimSpace(txType)
+			if !shared.IsLedgerTxType(txType) {
+				return filter, &shared.UserError{Message: fmt.Sprintf("unknown transaction type %s", txType)}
+			}
+			filter.Types = append(filter.Types, txType)
+		}
+	}
+	return filter, nil
+}
+
+func parseStatementTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, false, &shared.UserError{Message: fmt.Sprintf("invalid date %s, expected YYYY-MM-DD or RFC 3339", value)}
+	}
+	return t, true, nil
+}
+
+func writeStatementCSV(w http.ResponseWriter, statement *shared.Statement) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"statement-%d.csv\"", statement.PlayerID))
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"transaction_id", "created_at", "type", "reference", "amount", "currency", "balance"})
+	for _, line := range statement.Lines {
+		_ = cw.Write([]string{
+			strconv.Itoa(line.TransactionID),
+			line.CreatedAt.Format(time.RFC3339),
+			line.Type,
+			line.Reference,
+			line.Amount.String(),
+			line.Currency,
+			line.Balance.String(),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
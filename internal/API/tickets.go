@@ -0,0 +1,140 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// handleGetPlayerUplate lists the player's tickets with their fiscal breakdown and settlement.
+func (s *APIServer) handleGetPlayerUplate(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	uplate, err := s.store.GetPlayerUplate(id)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get uplate: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, uplate)
+}
+
+// handleSetPonudaResult records the score and winning tipovi of a finished ponuda, or voids the
+// bets on a cancelled or abandoned one, and settles the tickets that no longer have open bets.
+// The result is committed before the tickets are settled; if settling fails, handleSettlePonuda
+// finishes it.
+func (s *APIServer) handleSetPonudaResult(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	resultReq := new(shared.ResultRequest)
+	if err := json.NewDecoder(r.Body).Decode(resultReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode result data: %v", err)}
+	}
+	if resultReq.Status == "" {
+		resultReq.Status = shared.ResultFinished
+	}
+	if !shared.IsResultStatus(resultReq.Status) {
+		return &shared.UserError{Message: fmt.Sprintf("status must be %s, %s or %s",
+			shared.ResultFinished, shared.ResultCancelled, shared.ResultAbandoned)}
+	}
+	result := &shared.PonudaResult{PonudaID: id, Status: resultReq.Status, Score: resultReq.Score, WinningTipovi: []string{}}
+	for _, tip := range resultReq.WinningTipovi {
+		if tip = strings.TrimSpace(tip); tip != "" {
+			result.WinningTipovi = append(result.WinningTipovi, tip)
+		}
+	}
+	if result.Status != shared.ResultFinished && (len(result.WinningTipovi) > 0 || result.Score != nil) {
+		return &shared.UserError{Message: fmt.Sprintf("a %s ponuda has no score or winning tipovi", result.Status)}
+	}
+	if result.Score != nil && (result.Score.Home < 0 || result.Score.Away < 0) {
+		return &shared.UserError{Message: "score can't be negative"}
+	}
+
+	uplataIDs, err := s.store.SetPonudaResult(result)
+	if err != nil {
+		if errors.Is(err, shared.ErrResultExists) {
+			return &shared.UserError{Message: fmt.Sprintf("%v, settle its open tickets through /api/admin/ponude/%d/settle", err, id)}
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to record result: %v", err)}
+	}
+	settled, err := s.settleUplate(uplataIDs)
+	if err != nil {
+		log.Printf("result of ponuda %d is recorded but settling its tickets failed, rerun it through /api/admin/ponude/%d/settle", id, id)
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, shared.SettlementSummary{PonudaID: id, Settled: settled})
+}
+
+// handleSettlePonuda settles the tickets on a resulted or withdrawn ponuda that are still open.
+// Tickets that were already settled are skipped, so it can be rerun after a settlement failed.
+func (s *APIServer) handleSettlePonuda(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid ponuda id: %v", err)}
+	}
+	uplataIDs, err := s.store.GetUnsettledUplate(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: err.Error()}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get uplate of ponuda %d: %v", id, err)}
+	}
+	settled, err := s.settleUplate(uplataIDs)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, shared.SettlementSummary{PonudaID: id, Settled: settled})
+}
+
+// settleUplate settles every ticket in ids whose bets are all resolved and returns the ones it settled.
+func (s *APIServer) settleUplate(ids []int) ([]*shared.Uplata, error) {
+	settled := []*shared.Uplata{}
+	for _, id := range ids {
+		u, err := s.store.GetUplata(id)
+		if err != nil {
+			return nil, &shared.InternalError{Message: fmt.Sprintf("failed to get uplata %d: %v", id, err)}
+		}
+		rate, err := s.exchangeRate(u.Currency)
+		if err != nil {
+			return nil, err
+		}
+		status, settlement := s.fiscalRules.Settle(u, rate)
+		if settlement == nil {
+			continue
+		}
+		ok, err := s.store.SettleUplata(u.ID, status, settlement)
+		if err != nil {
+			return nil, &shared.InternalError{Message: fmt.Sprintf("failed to settle uplata %d: %v", id, err)}
+		}
+		if !ok {
+			log.Printf("uplata %d was already settled", id)
+			continue
+		}
+		u.Status, u.Settlement = status, settlement
+		settled = append(settled, u)
+	}
+	return settled, nil
+}
+
+// handleGetFiscalReport totals the handling fees and withheld tax per currency.
+func (s *APIServer) handleGetFiscalReport(w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseStatementFilter(r.URL.Query().Get("from"), r.URL.Query().Get("to"), "")
+	if err != nil {
+		return err
+	}
+	report, err := s.store.GetFiscalReport(filter.From, filter.To)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get fiscal report: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,189 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/MKolega/Praksa/internal/totp"
+	"net/http"
+	"time"
+)
+
+const (
+	totpIssuer        = "Praksa"
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+func (s *APIServer) handleTOTP(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "POST":
+		return s.handleEnrollTOTP(w, r)
+	case "DELETE":
+		return s.handleDisableTOTP(w, r)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+// authenticateTOTPRequest decodes a TOTP request of the player in the path and checks the
+// player's password and, once two-factor authentication is enabled, the code, under the login
+// throttle.
+func (s *APIServer) authenticateTOTPRequest(r *http.Request) (*shared.Player, *shared.TOTPCodeRequest, error) {
+	id, err := getID(r)
+	if err != nil {
+		return nil, nil, &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	totpReq := new(shared.TOTPCodeRequest)
+	if err := json.NewDecoder(r.Body).Decode(totpReq); err != nil {
+		return nil, nil, &shared.UserError{Message: fmt.Sprintf("failed to decode totp data: %v", err)}
+	}
+	player, err := s.store.GetPlayerByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return nil, nil, &shared.InternalError{Message: fmt.Sprintf("failed to get player by id %d: %v", id, err)}
+	}
+	player, err = s.authenticate(r, player.Username, totpReq.Password, totpReq.Code, totpReq.RecoveryCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return player, totpReq, nil
+}
+
+func (s *APIServer) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) error {
+	player, _, err := s.authenticateTOTPRequest(r)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.store.GetTOTP(player.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get totp: %v", err)}
+	}
+	if existing != nil && existing.Enabled {
+		return &shared.UserError{Message: "two-factor authentication is already enabled"}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return &shared.InternalError{Message: err.Error()}
+	}
+	if err := s.store.SaveTOTPSecret(player.ID, secret); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to save totp secret: %v", err)}
+	}
+	return WriteJSON(w, http.StatusCreated, shared.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, player.Username, secret),
+	})
+}
+
+// handleConfirmTOTP enables two-factor authentication once the player proves the authenticator
+// works. Wrong codes count as failed logins so they can't be guessed.
+func (s *APIServer) handleConfirmTOTP(w http.ResponseWriter, r *http.Request) error {
+	player, confirmReq, err := s.authenticateTOTPRequest(r)
+	if err != nil {
+		return err
+	}
+
+	t, err := s.store.GetTOTP(player.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: "no pending two-factor enrollment"}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get totp: %v", err)}
+	}
+	if t.Enabled {
+		return &shared.UserError{Message: "two-factor authentication is already enabled"}
+	}
+	step, ok := totp.Validate(t.Secret, confirmReq.Code, time.Now(), totpSkew)
+	if !ok {
+		s.recordLoginFailures(r, player.Username)
+		return &shared.UserError{Message: "invalid two-factor code"}
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return &shared.InternalError{Message: err.Error()}
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, totp.HashRecoveryCode(code))
+	}
+	if err := s.store.EnableTOTP(player.ID, step, hashes); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to enable totp: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, shared.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (s *APIServer) handleDisableTOTP(w http.ResponseWriter, r *http.Request) error {
+	player, _, err := s.authenticateTOTPRequest(r)
+	if err != nil {
+		return err
+	}
+	required, err := s.secondFactorRequired(player.ID)
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to check two-factor authentication: %v", err)}
+	}
+	if !required {
+		return &shared.UserError{Message: "two-factor authentication is not enabled"}
+	}
+	if err := s.store.DisableTOTP(player.ID); err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to disable totp: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, player.ID)
+}
+
+// secondFactorRequired reports whether the player has to present a TOTP or recovery code to log in.
+func (s *APIServer) secondFactorRequired(playerID int) (bool, error) {
+	t, err := s.store.GetTOTP(playerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return t.Enabled, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery code.
+func (s *APIServer) verifySecondFactor(playerID int, code, recoveryCode string) error {
+	t, err := s.store.GetTOTP(playerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: "two-factor authentication is not enabled"}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get totp: %v", err)}
+	}
+	if !t.Enabled {
+		return &shared.UserError{Message: "two-factor authentication is not enabled"}
+	}
+
+	switch {
+	case code != "":
+		step, ok := totp.Validate(t.Secret, code, time.Now(), totpSkew)
+		if ok {
+			ok, err = s.store.UseTOTPStep(playerID, step)
+			if err != nil {
+				return &shared.InternalError{Message: err.Error()}
+			}
+		}
+		if !ok {
+			return &shared.UserError{Message: "invalid two-factor code"}
+		}
+	case recoveryCode != "":
+		ok, err := s.store.UseRecoveryCode(playerID, totp.HashRecoveryCode(recoveryCode))
+		if err != nil {
+			return &shared.InternalError{Message: err.Error()}
+		}
+		if !ok {
+			return &shared.UserError{Message: "invalid recovery code"}
+		}
+	default:
+		return &shared.UserError{Message: "two-factor authentication code required"}
+	}
+	return nil
+}
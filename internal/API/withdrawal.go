@@ -0,0 +1,118 @@
+package API
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+var withdrawalActions = map[string]string{
+	"approve": shared.WithdrawalApproved,
+	"reject":  shared.WithdrawalRejected,
+	"pay":     shared.WithdrawalPaid,
+}
+
+func (s *APIServer) handlePlayerWithdrawals(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return s.handleGetPlayerWithdrawals(w, r)
+	case "POST":
+		return s.handleCreateWithdrawal(w, r)
+	default:
+		return fmt.Errorf("method not allowed %s", r.Method)
+	}
+}
+
+func (s *APIServer) handleCreateWithdrawal(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	withdrawalReq := new(shared.WithdrawalRequest)
+	if err := json.NewDecoder(r.Body).Decode(withdrawalReq); err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("failed to decode withdrawal data: %v", err)}
+	}
+	if withdrawalReq.Amount <= 0 {
+		return &shared.UserError{Message: "withdrawal amount must be positive"}
+	}
+	if err := s.requireVerified(id, withdrawalReq.Amount, s.kycThresholds.Withdrawal, "withdrawals"); err != nil {
+		return err
+	}
+
+	withdrawal := &shared.Withdrawal{PlayerID: id, Amount: withdrawalReq.Amount}
+	if err := s.store.CreateWithdrawal(withdrawal); err != nil {
+		if errors.Is(err, shared.ErrInsufficientFunds) {
+			return &shared.UserError{Message: "insufficient funds"}
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("Player with ID %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to create withdrawal: %v", err)}
+	}
+	return WriteJSON(w, http.StatusCreated, withdrawal)
+}
+
+func (s *APIServer) handleGetPlayerWithdrawals(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r)
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid player id: %v", err)}
+	}
+	withdrawals, err := s.store.GetWithdrawals(id, r.URL.Query().Get("status"))
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get withdrawals: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, withdrawals)
+}
+
+func (s *APIServer) handleGetWithdrawals(w http.ResponseWriter, r *http.Request) error {
+	withdrawals, err := s.store.GetWithdrawals(0, r.URL.Query().Get("status"))
+	if err != nil {
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get withdrawals: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, withdrawals)
+}
+
+// handleWithdrawalAction approves, rejects or marks a withdrawal as paid.
+func (s *APIServer) handleWithdrawalAction(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return &shared.UserError{Message: fmt.Sprintf("invalid withdrawal id: %s", mux.Vars(r)["id"])}
+	}
+	to, ok := withdrawalActions[mux.Vars(r)["action"]]
+	if !ok {
+		return &shared.UserError{Message: fmt.Sprintf("unknown withdrawal action %s", mux.Vars(r)["action"])}
+	}
+	actionReq := new(shared.WithdrawalActionRequest)
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(actionReq); err != nil {
+			return &shared.UserError{Message: fmt.Sprintf("failed to decode withdrawal data: %v", err)}
+		}
+	}
+	if to == shared.WithdrawalRejected && actionReq.Reason == "" {
+		return &shared.UserError{Message: "rejecting a withdrawal needs a reason"}
+	}
+
+	withdrawal, err := s.store.GetWithdrawal(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: fmt.Sprintf("withdrawal with id %d not found", id)}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to get withdrawal: %v", err)}
+	}
+	if !shared.CanTransitionWithdrawal(withdrawal.Status, to) {
+		return &shared.UserError{Message: fmt.Sprintf("can't change withdrawal from %s to %s", withdrawal.Status, to)}
+	}
+	withdrawal, err = s.store.SetWithdrawalStatus(id, withdrawal.Status, to, actionReq.Reason)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &shared.UserError{Message: "withdrawal was changed concurrently, try again"}
+		}
+		return &shared.InternalError{Message: fmt.Sprintf("failed to update withdrawal: %v", err)}
+	}
+	return WriteJSON(w, http.StatusOK, withdrawal)
+}
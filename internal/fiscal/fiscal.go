@@ -0,0 +1,146 @@
+// Package fiscal applies the handling fee and winnings tax regulated betting operators charge.
+package fiscal
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"os"
+)
+
+// Bracket taxes the part of a payout above From at Rate.
+type Bracket struct {
+	From shared.Money `json:"from"`
+	Rate shared.Rate  `json:"rate"`
+}
+
+// Rules are the fiscal rules applied to tickets. The handling fee is taken from every payment
+// and the rest is staked. Payouts above TaxFreeThreshold are taxed in full with progressive
+// brackets. Amounts are in the base currency.
+type Rules struct {
+	HandlingFee      shared.Rate  `json:"handling_fee"`
+	TaxFreeThreshold shared.Money `json:"tax_free_threshold"`
+	Brackets         []Bracket    `json:"brackets"`
+}
+
+// Croatia charges a 5% handling fee (manipulativni trošak) and taxes winnings at 10% up to
+// 10.000 EUR, 15% up to 30.000 EUR, 20% up to 500.000 EUR and 30% above.
+var Croatia = Rules{
+	HandlingFee: 50000,
+	Brackets: []Bracket{
+		{From: 0, Rate: 100000},
+		{From: 10000 * shared.MoneyUnit, Rate: 150000},
+		{From: 30000 * shared.MoneyUnit, Rate: 200000},
+		{From: 500000 * shared.MoneyUnit, Rate: 300000},
+	},
+}
+
+// LoadRules reads rules from a JSON file and validates them.
+func LoadRules(path string) (Rules, error) {
+	var rules Rules
+	f, err := os.Open(path)
+	if err != nil {
+		return rules, fmt.Errorf("failed to open fiscal rules: %v", err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return rules, fmt.Errorf("failed to decode fiscal rules %s: %v", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return rules, fmt.Errorf("invalid fiscal rules %s: %v", path, err)
+	}
+	return rules, nil
+}
+
+func (r Rules) Validate() error {
+	if r.HandlingFee < 0 || r.HandlingFee >= shared.RateOne {
+		return fmt.Errorf("handling fee must be between 0 and 1")
+	}
+	if r.TaxFreeThreshold < 0 {
+		return fmt.Errorf("tax free threshold can't be negative")
+	}
+	for i, b := range r.Brackets {
+		if b.From < 0 {
+			return fmt.Errorf("tax bracket %d can't start below 0", i)
+		}
+		if b.Rate < 0 || b.Rate > shared.RateOne {
+			return fmt.Errorf("tax rate of bracket %d must be between 0 and 1", i)
+		}
+		if i > 0 && b.From <= r.Brackets[i-1].From {
+			return fmt.Errorf("tax brackets must be in ascending order")
+		}
+	}
+	return nil
+}
+
+// Fee returns the handling fee charged on payment.
+func (r Rules) Fee(payment shared.Money) shared.Money {
+	return payment.MulRate(r.HandlingFee)
+}
+
+// Tax returns the tax withheld from a payout in the base currency.
+func (r Rules) Tax(payout shared.Money) shared.Money {
+	if payout <= r.TaxFreeThreshold {
+		return 0
+	}
+	var tax shared.Money
+	for i, b := range r.Brackets {
+		if payout <= b.From {
+			break
+		}
+		taxed := payout - b.From
+		if i+1 < len(r.Brackets) && payout > r.Brackets[i+1].From {
+			taxed = r.Brackets[i+1].From - b.From
+		}
+		tax += taxed.MulRate(b.Rate)
+	}
+	return tax
+}
+
+// TaxIn returns the tax withheld from a payout in a currency with exchange rate rate.
+func (r Rules) TaxIn(payout shared.Money, rate shared.Rate) shared.Money {
+	tax := r.Tax(payout.ToBase(rate)).FromBase(rate)
+	if tax > payout {
+		return payout
+	}
+	return tax
+}
+
+// Breakdown prices a payment at odds for a wallet in a currency with exchange rate rate.
+func (r Rules) Breakdown(payment shared.Money, odds shared.Odds, rate shared.Rate) shared.FiscalBreakdown {
+	fee := r.Fee(payment)
+	stake := payment - fee
+	payout := stake.MulOdds(odds)
+	tax := r.TaxIn(payout, rate)
+	return shared.FiscalBreakdown{
+		Payment:         payment,
+		Fee:             fee,
+		Stake:           stake,
+		TotalOdds:       odds,
+		PotentialPayout: payout,
+		Tax:             tax,
+		NetPayout:       payout - tax,
+	}
+}
+
+// Settle works out the status u settles to and what it pays out. The settlement is nil while
+// the ticket still has open bets.
+func (r Rules) Settle(u *shared.Uplata, rate shared.Rate) (string, *shared.Settlement) {
+	status, odds := u.Outcome()
+	switch status {
+	case shared.TicketOpen:
+		return status, nil
+	case shared.TicketLost:
+		return status, &shared.Settlement{}
+	case shared.TicketVoid:
+		return status, &shared.Settlement{Odds: shared.OddsOne, Refund: u.Payment}
+	}
+	payout := u.Stake.MulOdds(odds)
+	tax := r.TaxIn(payout, rate)
+	return status, &shared.Settlement{Odds: odds, Payout: payout, Tax: tax, NetPayout: payout - tax}
+}
@@ -0,0 +1,224 @@
+package fiscal
+
+import (
+	"github.com/MKolega/Praksa/internal/shared"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const eur = shared.MoneyUnit
+
+func TestCroatiaIsValid(t *testing.T) {
+	if err := Croatia.Validate(); err != nil {
+		t.Fatalf("Croatia.Validate: %v", err)
+	}
+}
+
+func TestTaxBrackets(t *testing.T) {
+	tests := []struct {
+		name   string
+		payout shared.Money
+		want   shared.Money
+	}{
+		{"nothing", 0, 0},
+		{"first bracket", 100 * eur, 10 * eur},
+		{"at 10k", 10000 * eur, 1000 * eur},
+		{"above 10k", 10100 * eur, 1015 * eur},
+		{"at 30k", 30000 * eur, 4000 * eur},
+		{"above 30k", 30100 * eur, 4020 * eur},
+		{"at 500k", 500000 * eur, 98000 * eur},
+		{"above 500k", 500100 * eur, 98030 * eur},
+		{"rounded to the cent", 10000*eur + 3, 1000*eur + 0},
+		{"rounded half up", 10000*eur + 4, 1000*eur + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Croatia.Tax(tt.payout); got != tt.want {
+				t.Errorf("Tax(%s) = %s, want %s", tt.payout, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaxFreeThreshold(t *testing.T) {
+	rules := Rules{TaxFreeThreshold: 1000 * eur, Brackets: []Bracket{{From: 0, Rate: 100000}}}
+	tests := []struct {
+		payout shared.Money
+		want   shared.Money
+	}{
+		{999 * eur, 0},
+		{1000 * eur, 0},
+		{1000*eur + 10, 100*eur + 1},
+	}
+	for _, tt := range tests {
+		if got := rules.Tax(tt.payout); got != tt.want {
+			t.Errorf("Tax(%s) = %s, want %s", tt.payout, got, tt.want)
+		}
+	}
+}
+
+func TestTaxIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		payout shared.Money
+		rate   shared.Rate
+		want   shared.Money
+	}{
+		{"base currency", 20000 * eur, shared.RateOne, 2500 * eur},
+		{"weaker currency stays in the first bracket", 20000 * eur, shared.RateOne / 2, 2000 * eur},
+		{"stronger currency reaches the second bracket", 10000 * eur, 2 * shared.RateOne, 1250 * eur},
+		{"rounded back into the wallet currency", 1 * eur, 3 * shared.RateOne, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Croatia.TaxIn(tt.payout, tt.rate); got != tt.want {
+				t.Errorf("TaxIn(%s, %s) = %s, want %s", tt.payout, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		payment shared.Money
+		odds    shared.Odds
+		rate    shared.Rate
+		want    shared.FiscalBreakdown
+	}{
+		{
+			name:    "fee is taken before staking",
+			payment: 10 * eur,
+			odds:    200,
+			rate:    shared.RateOne,
+			want:    shared.FiscalBreakdown{Payment: 1000, Fee: 50, Stake: 950, TotalOdds: 200, PotentialPayout: 1900, Tax: 190, NetPayout: 1710},
+		},
+		{
+			name:    "fee rounds half up",
+			payment: 10,
+			odds:    300,
+			rate:    shared.RateOne,
+			want:    shared.FiscalBreakdown{Payment: 10, Fee: 1, Stake: 9, TotalOdds: 300, PotentialPayout: 27, Tax: 3, NetPayout: 24},
+		},
+		{
+			name:    "fee rounds down below half a cent",
+			payment: 9,
+			odds:    300,
+			rate:    shared.RateOne,
+			want:    shared.FiscalBreakdown{Payment: 9, Fee: 0, Stake: 9, TotalOdds: 300, PotentialPayout: 27, Tax: 3, NetPayout: 24},
+		},
+		{
+			name:    "tax in a non-base currency",
+			payment: 20000 * eur,
+			odds:    200,
+			rate:    shared.RateOne / 2,
+			want: shared.FiscalBreakdown{Payment: 20000 * eur, Fee: 1000 * eur, Stake: 19000 * eur, TotalOdds: 200,
+				PotentialPayout: 38000 * eur, Tax: 4700 * eur, NetPayout: 33300 * eur},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Croatia.Breakdown(tt.payment, tt.odds, tt.rate); got != tt.want {
+				t.Errorf("Breakdown = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettle(t *testing.T) {
+	ticket := func(statuses ...string) *shared.Uplata {
+		u := &shared.Uplata{FiscalBreakdown: Croatia.Breakdown(10*eur, 600, shared.RateOne)}
+		for i, status := range statuses {
+			u.Bets = append(u.Bets, &shared.TicketBet{ID: i + 1, Tecaj: shared.Odds(200 + 100*i), Status: status})
+		}
+		return u
+	}
+
+	tests := []struct {
+		name       string
+		u          *shared.Uplata
+		rate       shared.Rate
+		wantStatus string
+		want       *shared.Settlement
+	}{
+		{"open bets leave it open", ticket(shared.BetWon, shared.BetOpen), shared.RateOne, shared.TicketOpen, nil},
+		{"a lost bet loses it", ticket(shared.BetWon, shared.BetLost), shared.RateOne, shared.TicketLost, &shared.Settlement{}},
+		{"only void bets refund the payment", ticket(shared.BetVoid, shared.BetVoid), shared.RateOne, shared.TicketVoid,
+			&shared.Settlement{Odds: shared.OddsOne, Refund: 10 * eur}},
+		{"won", ticket(shared.BetWon, shared.BetWon), shared.RateOne, shared.TicketWon,
+			&shared.Settlement{Odds: 600, Payout: 5700, Tax: 570, NetPayout: 5130}},
+		{"void bets pay at 1.00", ticket(shared.BetWon, shared.BetVoid), shared.RateOne, shared.TicketWon,
+			&shared.Settlement{Odds: 200, Payout: 1900, Tax: 190, NetPayout: 1710}},
+		{"taxed in the wallet currency", ticket(shared.BetWon, shared.BetWon), 2 * shared.RateOne, shared.TicketWon,
+			&shared.Settlement{Odds: 600, Payout: 5700, Tax: 570, NetPayout: 5130}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, settlement := Croatia.Settle(tt.u, tt.rate)
+			if status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(settlement, tt.want) {
+				t.Errorf("settlement = %+v, want %+v", settlement, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		ok    bool
+	}{
+		{"no tax", Rules{}, true},
+		{"fee of 100%", Rules{HandlingFee: shared.RateOne}, false},
+		{"negative fee", Rules{HandlingFee: -1}, false},
+		{"negative threshold", Rules{TaxFreeThreshold: -1}, false},
+		{"rate above 100%", Rules{Brackets: []Bracket{{Rate: shared.RateOne + 1}}}, false},
+		{"negative bracket", Rules{Brackets: []Bracket{{From: -1, Rate: 1}}}, false},
+		{"brackets out of order", Rules{Brackets: []Bracket{{From: 0, Rate: 1}, {From: 0, Rate: 2}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rules.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	rules, err := LoadRules(write("valid.json", `{"handling_fee": "0.05", "tax_free_threshold": "100", "brackets": [{"from": "0", "rate": "0.1"}]}`))
+	if err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	want := Rules{HandlingFee: 50000, TaxFreeThreshold: 100 * eur, Brackets: []Bracket{{From: 0, Rate: 100000}}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("LoadRules = %+v, want %+v", rules, want)
+	}
+
+	for name, content := range map[string]string{
+		"invalid.json": `{"handling_fee": "1.5"}`,
+		"unknown.json": `{"handling_fees": "0.05"}`,
+		"broken.json":  `{`,
+	} {
+		if _, err := LoadRules(write(name, content)); err == nil {
+			t.Errorf("LoadRules accepted %s", name)
+		}
+	}
+	if _, err := LoadRules(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadRules accepted a missing file")
+	}
+}
@@ -0,0 +1,93 @@
+// Package password hashes passwords with PBKDF2-HMAC-SHA256 (RFC 8018) and a random salt per
+// password. Hashes are stored as "pbkdf2-sha256$<iterations>$<salt>$<key>" with the salt and key
+// base64 encoded, so the iteration count can be raised without invalidating older hashes.
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	prefix     = "pbkdf2-sha256"
+	Iterations = 600000
+	saltSize   = 16
+	keySize    = 32
+)
+
+var encoding = base64.RawStdEncoding
+
+// Hash returns the encoded hash of the password under a new random salt.
+func Hash(password string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate password salt: %v", err)
+	}
+	key := pbkdf2([]byte(password), salt, Iterations, keySize)
+	return fmt.Sprintf("%s$%d$%s$%s", prefix, Iterations, encoding.EncodeToString(salt), encoding.EncodeToString(key)), nil
+}
+
+// IsHash reports whether the stored value is a hash made by Hash rather than a plaintext password.
+func IsHash(stored string) bool {
+	return strings.HasPrefix(stored, prefix+"$")
+}
+
+// Verify reports whether the password matches the encoded hash. Malformed hashes never match.
+func Verify(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != prefix {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := encoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got := pbkdf2([]byte(password), salt, iterations, len(want))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// pbkdf2 derives a key of keyLen bytes from the password and salt with HMAC-SHA256 as the
+// pseudorandom function.
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	size := prf.Size()
+	blocks := (keyLen + size - 1) / size
+
+	key := make([]byte, 0, blocks*size)
+	u := make([]byte, size)
+	t := make([]byte, size)
+	var counter [4]byte
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,68 @@
+package password
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Published PBKDF2-HMAC-SHA256 test vectors, in the style of RFC 6070.
+var pbkdf2Vectors = []struct {
+	password   string
+	salt       string
+	iterations int
+	keyLen     int
+	key        string
+}{
+	{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+	{"password", "salt", 2, 32, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+	{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40, "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+}
+
+func TestPBKDF2Vectors(t *testing.T) {
+	for _, tt := range pbkdf2Vectors {
+		got := hex.EncodeToString(pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen))
+		if got != tt.key {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.key)
+		}
+	}
+}
+
+func TestHashVerify(t *testing.T) {
+	hash, err := Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !IsHash(hash) || IsHash("correct horse") {
+		t.Errorf("IsHash doesn't tell the hash %q from a plaintext password", hash)
+	}
+	if !Verify(hash, "correct horse") {
+		t.Error("Verify rejected the right password")
+	}
+	if Verify(hash, "wrong horse") {
+		t.Error("Verify accepted a wrong password")
+	}
+	other, err := Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if other == hash {
+		t.Error("Hash reused a salt")
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"correct horse",
+		"pbkdf2-sha256$0$c2FsdA$a2V5",
+		"pbkdf2-sha256$x$c2FsdA$a2V5",
+		"pbkdf2-sha256$1$!!$a2V5",
+		"pbkdf2-sha256$1$c2FsdA$",
+		"pbkdf2-sha1$1$c2FsdA$a2V5",
+	} {
+		if Verify(hash, "correct horse") {
+			t.Errorf("Verify(%q) = true, want false", hash)
+		}
+	}
+}
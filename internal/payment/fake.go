@@ -0,0 +1,87 @@
+// Package payment contains the payment providers deposits can go through.
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"net/http"
+)
+
+const (
+	FakeProviderName    = "fake"
+	FakeSignatureHeader = "X-Fake-Signature"
+)
+
+// FakeProvider is an in-process payment provider for development and tests. Nothing is
+// charged; Complete produces the signed callback a real provider would send.
+type FakeProvider struct {
+	secret          []byte
+	checkoutBaseURL string
+}
+
+type fakeCallback struct {
+	IntentID string       `json:"intent_id"`
+	Status   string       `json:"status"`
+	Amount   shared.Money `json:"amount"`
+}
+
+// NewFakeProvider returns a provider signing callbacks with secret. Checkout URLs are built
+// from checkoutBaseURL followed by the intent reference.
+func NewFakeProvider(secret []byte, checkoutBaseURL string) *FakeProvider {
+	return &FakeProvider{secret: secret, checkoutBaseURL: checkoutBaseURL}
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) CreateIntent(intent *shared.PaymentIntent) error {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate intent id: %v", err)
+	}
+	intent.ProviderRef = "fake_" + hex.EncodeToString(b)
+	intent.CheckoutURL = p.checkoutBaseURL + intent.ProviderRef
+	return nil
+}
+
+func (p *FakeProvider) VerifySignature(payload []byte, header http.Header) error {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.sign(payload)) {
+		return fmt.Errorf("invalid %s signature", FakeProviderName)
+	}
+	return nil
+}
+
+func (p *FakeProvider) ParseCallback(payload []byte) (*shared.PaymentCallback, error) {
+	var cb fakeCallback
+	if err := json.Unmarshal(payload, &cb); err != nil {
+		return nil, fmt.Errorf("failed to decode callback: %v", err)
+	}
+	if cb.Status != shared.PaymentSucceeded && cb.Status != shared.PaymentFailed {
+		return nil, fmt.Errorf("unknown payment status %s", cb.Status)
+	}
+	return &shared.PaymentCallback{ProviderRef: cb.IntentID, Status: cb.Status, Amount: cb.Amount}, nil
+}
+
+// Complete returns the callback payload and headers reporting the intent as finished with status.
+func (p *FakeProvider) Complete(intentID string, amount shared.Money, status string) ([]byte, http.Header, error) {
+	payload, err := json.Marshal(fakeCallback{IntentID: intentID, Status: status, Amount: amount})
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, hex.EncodeToString(p.sign(payload)))
+	return payload, header, nil
+}
+
+func (p *FakeProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"fmt"
+	"time"
+)
+
+// BaseCurrency is the currency reports are converted to. Its exchange rate is always 1.
+const BaseCurrency = "EUR"
+
+type ExchangeRate struct {
+	Currency  string    `json:"currency"`
+	Rate      Rate      `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ExchangeRateRequest struct {
+	Rate Rate `json:"rate"`
+}
+
+// ValidateCurrencyCode accepts ISO 4217 style codes: three upper case letters.
+func ValidateCurrencyCode(code string) error {
+	if len(code) != 3 {
+		return &UserError{Message: fmt.Sprintf("invalid currency code %s", code)}
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return &UserError{Message: fmt.Sprintf("invalid currency code %s", code)}
+		}
+	}
+	return nil
+}
+
+// LedgerReportLine totals the wallet movements of one type and currency.
+type LedgerReportLine struct {
+	Type      string `json:"type"`
+	Currency  string `json:"currency"`
+	Total     Money  `json:"total"`
+	TotalBase Money  `json:"total_base"`
+}
+
+type LedgerReport struct {
+	BaseCurrency string              `json:"base_currency"`
+	Lines        []*LedgerReportLine `json:"lines"`
+}
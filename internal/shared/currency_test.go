@@ -0,0 +1,25 @@
+package shared
+
+import "testing"
+
+func TestValidateCurrencyCode(t *testing.T) {
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{"EUR", true},
+		{"USD", true},
+		{"HRK", true},
+		{"eur", false},
+		{"EU", false},
+		{"EURO", false},
+		{"E1R", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		err := ValidateCurrencyCode(tt.code)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateCurrencyCode(%q) = %v, want ok %v", tt.code, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package shared
+
+import "time"
+
+// Exposure totals live tickets. Liability is what the house stands to lose: their potential
+// payouts less their stakes, in the base currency. A ticket is live while it is open and none of
+// its bets lost.
+type Exposure struct {
+	Tickets   int   `json:"tickets"`
+	Stakes    Money `json:"stakes"`
+	Payouts   Money `json:"potential_payouts"`
+	Liability Money `json:"liability"`
+}
+
+// Add counts the stakes and potential payouts of tickets in the exposure.
+func (l *Exposure) Add(tickets int, stakes, payouts Money) {
+	l.Tickets += tickets
+	l.Stakes += stakes
+	l.Payouts += payouts
+	l.Liability = l.Payouts - l.Stakes
+}
+
+// OutcomeLiability is the liability on a tip of a ponuda, the loss if it wins and the other bets
+// of the tickets on it win too.
+type OutcomeLiability struct {
+	PonudaID int    `json:"ponuda_id"`
+	Naziv    string `json:"naziv"`
+	LigaID   int    `json:"liga_id,omitempty"`
+	Exposure
+}
+
+// LigaLiability is the liability of the live tickets with an open bet in a liga. A ticket with
+// several bets in the liga is counted once.
+type LigaLiability struct {
+	LigaID int `json:"liga_id"`
+	Exposure
+}
+
+type LiabilityReport struct {
+	BaseCurrency string              `json:"base_currency"`
+	Outcomes     []*OutcomeLiability `json:"outcomes"`
+	Lige         []*LigaLiability    `json:"lige"`
+}
+
+// ExposureThreshold limits the liability on every outcome of a scope. A ticket that would bring
+// the liability on an outcome to ReferAt is held for review instead of being placed, and an
+// outcome whose liability reaches SuspendAt is suspended. Nil thresholds are inherited from the less specific
+// scopes, like betting limits. Thresholds can be set globally, per league and per event.
+type ExposureThreshold struct {
+	Scope     string    `json:"scope"`
+	ScopeID   int       `json:"scope_id"`
+	ReferAt   *Money    `json:"refer_at,omitempty"`
+	SuspendAt *Money    `json:"suspend_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExposureThresholdRequest sets the thresholds of a scope.
+type ExposureThresholdRequest struct {
+	ReferAt   *Money `json:"refer_at,omitempty"`
+	SuspendAt *Money `json:"suspend_at,omitempty"`
+}
+
+func (r *ExposureThresholdRequest) Validate() error {
+	if r.ReferAt != nil && *r.ReferAt <= 0 {
+		return &UserError{Message: "refer_at must be positive"}
+	}
+	if r.SuspendAt != nil && *r.SuspendAt <= 0 {
+		return &UserError{Message: "suspend_at must be positive"}
+	}
+	if r.ReferAt != nil && r.SuspendAt != nil && *r.ReferAt > *r.SuspendAt {
+		return &UserError{Message: "refer_at can't be above suspend_at"}
+	}
+	return nil
+}
+
+// exposureLimits returns the refer and suspend thresholds of an outcome on the ponuda in the
+// liga. The most specific scope that sets a threshold wins.
+func exposureLimits(thresholds []*ExposureThreshold, ligaID, ponudaID int) (referAt, suspendAt *Money) {
+	for _, scope := range []string{LimitScopeGlobal, LimitScopeLeague, LimitScopeEvent} {
+		for _, t := range thresholds {
+			if t.Scope != scope {
+				continue
+			}
+			switch {
+			case scope == LimitScopeGlobal,
+				scope == LimitScopeLeague && t.ScopeID == ligaID,
+				scope == LimitScopeEvent && t.ScopeID == ponudaID:
+				if t.ReferAt != nil {
+					referAt = t.ReferAt
+				}
+				if t.SuspendAt != nil {
+					suspendAt = t.SuspendAt
+				}
+			}
+		}
+	}
+	return referAt, suspendAt
+}
+
+// ReferTicket reports whether a ticket with the given stake and potential payout, in the base
+// currency, would bring the liability on any of its selections to the refer threshold.
+// outcomes are the current liabilities of the ticket's ponude.
+func ReferTicket(thresholds []*ExposureThreshold, outcomes []*OutcomeLiability, stake, payout Money, selections []*BetSelection) bool {
+	for _, sel := range selections {
+		referAt, _ := exposureLimits(thresholds, sel.LigaID, sel.Ponuda)
+		if referAt == nil {
+			continue
+		}
+		liability := payout - stake
+		for _, o := range outcomes {
+			if o.PonudaID == sel.Ponuda && o.Naziv == sel.NazivTipa {
+				liability += o.Liability
+			}
+		}
+		if liability >= *referAt {
+			return true
+		}
+	}
+	return false
+}
+
+// ExposedOutcomes returns the outcomes whose liability reached their suspend threshold.
+func ExposedOutcomes(thresholds []*ExposureThreshold, outcomes []*OutcomeLiability) []*OutcomeLiability {
+	exposed := []*OutcomeLiability{}
+	for _, o := range outcomes {
+		if _, suspendAt := exposureLimits(thresholds, o.LigaID, o.PonudaID); suspendAt != nil && o.Liability >= *suspendAt {
+			exposed = append(exposed, o)
+		}
+	}
+	return exposed
+}
@@ -0,0 +1,60 @@
+package shared
+
+import "testing"
+
+func TestReferTicket(t *testing.T) {
+	thresholds := []*ExposureThreshold{
+		{Scope: LimitScopeGlobal, ReferAt: ptr(Money(100000))},
+		{Scope: LimitScopeLeague, ScopeID: 2, ReferAt: ptr(Money(50000))},
+		{Scope: LimitScopeEvent, ScopeID: 20, SuspendAt: ptr(Money(200000))},
+	}
+	outcomes := []*OutcomeLiability{
+		{PonudaID: 10, Naziv: "1", LigaID: 1, Exposure: Exposure{Liability: 90000}},
+		{PonudaID: 20, Naziv: "X", LigaID: 2, Exposure: Exposure{Liability: 40000}},
+	}
+	home := &BetSelection{Ponuda: 10, NazivTipa: "1", LigaID: 1}
+	away := &BetSelection{Ponuda: 10, NazivTipa: "2", LigaID: 1}
+	draw := &BetSelection{Ponuda: 20, NazivTipa: "X", LigaID: 2}
+
+	tests := []struct {
+		name       string
+		thresholds []*ExposureThreshold
+		stake      Money
+		payout     Money
+		selections []*BetSelection
+		want       bool
+	}{
+		{"below the global threshold", thresholds, 1000, 10000, []*BetSelection{home}, false},
+		{"reaching the global threshold", thresholds, 1000, 11000, []*BetSelection{home}, true},
+		{"outcome without liability", thresholds, 1000, 11000, []*BetSelection{away}, false},
+		{"league threshold overrides global", thresholds, 1000, 11000, []*BetSelection{draw}, true},
+		{"any selection refers the ticket", thresholds, 1000, 11000, []*BetSelection{away, draw}, true},
+		{"no thresholds", nil, 1000, 1000000, []*BetSelection{home}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReferTicket(tt.thresholds, outcomes, tt.stake, tt.payout, tt.selections); got != tt.want {
+				t.Errorf("ReferTicket() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExposedOutcomes(t *testing.T) {
+	thresholds := []*ExposureThreshold{
+		{Scope: LimitScopeGlobal, SuspendAt: ptr(Money(100000))},
+		{Scope: LimitScopeEvent, ScopeID: 30, SuspendAt: ptr(Money(300000))},
+	}
+	below := &OutcomeLiability{PonudaID: 10, Naziv: "1", Exposure: Exposure{Liability: 99999}}
+	reached := &OutcomeLiability{PonudaID: 10, Naziv: "X", Exposure: Exposure{Liability: 100000}}
+	above := &OutcomeLiability{PonudaID: 20, Naziv: "2", Exposure: Exposure{Liability: 150000}}
+	eventLimit := &OutcomeLiability{PonudaID: 30, Naziv: "1", Exposure: Exposure{Liability: 150000}}
+
+	got := ExposedOutcomes(thresholds, []*OutcomeLiability{below, reached, above, eventLimit})
+	if len(got) != 2 || got[0] != reached || got[1] != above {
+		t.Errorf("ExposedOutcomes() = %+v, want the outcomes of ponude 10 (X) and 20", got)
+	}
+	if got := ExposedOutcomes(nil, []*OutcomeLiability{above}); len(got) != 0 {
+		t.Errorf("ExposedOutcomes() without thresholds = %+v, want none", got)
+	}
+}
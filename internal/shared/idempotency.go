@@ -0,0 +1,20 @@
+package shared
+
+import "time"
+
+// IdempotencyRecord remembers a request made with an Idempotency-Key header and, once it
+// finished, the response it produced. Keys are unique within a scope, the route they were used on.
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.CompletedAt != nil
+}
@@ -0,0 +1,30 @@
+package shared
+
+const (
+	KYCUnverified = "unverified"
+	KYCPending    = "pending"
+	KYCVerified   = "verified"
+	KYCRejected   = "rejected"
+)
+
+var kycTransitions = map[string][]string{
+	KYCUnverified: {KYCPending},
+	KYCPending:    {KYCVerified, KYCRejected, KYCUnverified},
+	KYCRejected:   {KYCPending, KYCUnverified},
+	KYCVerified:   {KYCRejected, KYCUnverified},
+}
+
+// CanTransitionKYC reports whether a player may move from one KYC state to another.
+func CanTransitionKYC(from, to string) bool {
+	for _, next := range kycTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileComplete reports whether the player filled in everything needed for verification.
+func (p *Player) ProfileComplete() bool {
+	return p.Email != "" && p.FullName != "" && p.DateOfBirth != "" && p.Address != "" && p.NationalID != ""
+}
@@ -0,0 +1,51 @@
+package shared
+
+import "testing"
+
+func TestCanTransitionKYC(t *testing.T) {
+	tests := []struct {
+		from, to string
+		ok       bool
+	}{
+		{KYCUnverified, KYCPending, true},
+		{KYCUnverified, KYCVerified, false},
+		{KYCUnverified, KYCRejected, false},
+		{KYCPending, KYCVerified, true},
+		{KYCPending, KYCRejected, true},
+		{KYCPending, KYCUnverified, true},
+		{KYCRejected, KYCPending, true},
+		{KYCRejected, KYCUnverified, true},
+		{KYCRejected, KYCVerified, false},
+		{KYCVerified, KYCRejected, true},
+		{KYCVerified, KYCUnverified, true},
+		{KYCVerified, KYCPending, false},
+		{KYCPending, KYCPending, false},
+		{"unknown", KYCPending, false},
+		{KYCPending, "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := CanTransitionKYC(tt.from, tt.to); got != tt.ok {
+			t.Errorf("CanTransitionKYC(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.ok)
+		}
+	}
+}
+
+func TestProfileComplete(t *testing.T) {
+	complete := Player{Email: "ana@example.com", FullName: "Ana Anić", DateOfBirth: "1990-01-01", Address: "Ilica 1, Zagreb", NationalID: "69435151530"}
+	if !complete.ProfileComplete() {
+		t.Error("ProfileComplete() = false for a complete profile")
+	}
+	for name, unset := range map[string]func(p *Player){
+		"email":         func(p *Player) { p.Email = "" },
+		"full name":     func(p *Player) { p.FullName = "" },
+		"date of birth": func(p *Player) { p.DateOfBirth = "" },
+		"address":       func(p *Player) { p.Address = "" },
+		"national id":   func(p *Player) { p.NationalID = "" },
+	} {
+		p := complete
+		unset(&p)
+		if p.ProfileComplete() {
+			t.Errorf("ProfileComplete() = true without %s", name)
+		}
+	}
+}
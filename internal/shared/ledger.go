@@ -0,0 +1,201 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TxDeposit    = "deposit"
+	TxStake      = "stake"
+	TxWin        = "win"
+	TxRefund     = "refund"
+	TxBonus      = "bonus"
+	TxWithdrawal = "withdrawal"
+	TxAdjustment = "adjustment"
+)
+
+// House accounts are the counterparties of player wallet entries.
+const (
+	HouseCashAccount        = "house:cash"
+	HouseBetsAccount        = "house:bets"
+	HouseBonusAccount       = "house:bonus"
+	HouseAdjustmentsAccount = "house:adjustments"
+	HouseFeesAccount        = "house:fees"
+	HouseTaxAccount         = "house:tax"
+)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+var ledgerTxTypes = map[string]bool{
+	TxDeposit:    true,
+	TxStake:      true,
+	TxWin:        true,
+	TxRefund:     true,
+	TxBonus:      true,
+	TxWithdrawal: true,
+	TxAdjustment: true,
+}
+
+func IsLedgerTxType(txType string) bool {
+	return ledgerTxTypes[txType]
+}
+
+// PlayerAccount is the ledger account holding the player's wallet.
+func PlayerAccount(playerID int) string {
+	return fmt.Sprintf("player:%d", playerID)
+}
+
+// ParsePlayerAccount returns the player ID of a wallet account.
+func ParsePlayerAccount(account string) (int, bool) {
+	id, found := strings.CutPrefix(account, "player:")
+	if !found {
+		return 0, false
+	}
+	playerID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, false
+	}
+	return playerID, true
+}
+
+type LedgerEntry struct {
+	Account  string `json:"account"`
+	Amount   Money  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// LedgerTransaction is an immutable, balanced set of entries: the amounts in each currency sum to zero.
+// A positive amount credits the account, a negative one debits it.
+type LedgerTransaction struct {
+	ID        int           `json:"id"`
+	Type      string        `json:"type"`
+	PlayerID  int           `json:"player_id"`
+	Reference string        `json:"reference,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Entries   []LedgerEntry `json:"entries"`
+}
+
+// NewWalletTransaction moves amount between the player's wallet and a house account.
+// A positive amount is credited to the player.
+func NewWalletTransaction(txType string, playerID int, houseAccount string, amount Money, currency, reference string) *LedgerTransaction {
+	return &LedgerTransaction{
+		Type:      txType,
+		PlayerID:  playerID,
+		Reference: reference,
+		Entries: []LedgerEntry{
+			{Account: PlayerAccount(playerID), Amount: amount, Currency: currency},
+			{Account: houseAccount, Amount: -amount, Currency: currency},
+		},
+	}
+}
+
+func (t *LedgerTransaction) Validate() error {
+	if !IsLedgerTxType(t.Type) {
+		return fmt.Errorf("unknown ledger transaction type %s", t.Type)
+	}
+	if len(t.Entries) < 2 {
+		return fmt.Errorf("ledger transaction needs at least two entries")
+	}
+	sums := map[string]Money{}
+	for _, e := range t.Entries {
+		if e.Amount == 0 {
+			return fmt.Errorf("ledger entry for %s has no amount", e.Account)
+		}
+		if e.Currency == "" {
+			return fmt.Errorf("ledger entry for %s has no currency", e.Account)
+		}
+		sums[e.Currency] += e.Amount
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("ledger transaction is not balanced, %s entries sum to %s", currency, sum)
+		}
+	}
+	return nil
+}
+
+// BalanceMismatch is a player whose stored balance differs from the ledger.
+type BalanceMismatch struct {
+	PlayerID      int   `json:"player_id"`
+	Balance       Money `json:"balance"`
+	LedgerBalance Money `json:"ledger_balance"`
+}
+
+type LedgerAdjustmentRequest struct {
+	Type      string `json:"type"`
+	Amount    Money  `json:"amount"`
+	Reference string `json:"reference"`
+}
+
+// StatementFilter selects the lines of an account statement. From is inclusive, To exclusive.
+// A Limit of 0 returns every matching line.
+type StatementFilter struct {
+	From   *time.Time
+	To     *time.Time
+	Types  []string
+	Limit  int
+	Offset int
+}
+
+// StatementLine is one wallet movement with the balance right after it.
+type StatementLine struct {
+	TransactionID int       `json:"transaction_id"`
+	Type          string    `json:"type"`
+	Reference     string    `json:"reference,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	Amount        Money     `json:"amount"`
+	Balance       Money     `json:"balance"`
+	Currency      string    `json:"currency"`
+}
+
+type Statement struct {
+	PlayerID int              `json:"player_id"`
+	Total    int              `json:"total"`
+	Limit    int              `json:"limit"`
+	Offset   int              `json:"offset"`
+	Lines    []*StatementLine `json:"lines"`
+}
+
+// BuildStatement works out the balance after each of the movements, oldest first, starting from
+// opening, the balance before the first of them. It then keeps the lines of the filter's types
+// and returns the requested page, newest first. The balances count every movement, so they stay
+// right when types are filtered out.
+func BuildStatement(playerID int, opening Money, movements []*StatementLine, filter StatementFilter) *Statement {
+	types := map[string]bool{}
+	for _, txType := range filter.Types {
+		types[txType] = true
+	}
+
+	balance := opening
+	lines := []*StatementLine{}
+	for _, m := range movements {
+		balance += m.Amount
+		m.Balance = balance
+		if len(types) == 0 || types[m.Type] {
+			lines = append(lines, m)
+		}
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	statement := &Statement{
+		PlayerID: playerID,
+		Total:    len(lines),
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+		Lines:    []*StatementLine{},
+	}
+	if filter.Offset < len(lines) {
+		lines = lines[filter.Offset:]
+		if filter.Limit > 0 && filter.Limit < len(lines) {
+			lines = lines[:filter.Limit]
+		}
+		statement.Lines = lines
+	}
+	return statement
+}
@@ -0,0 +1,87 @@
+package shared
+
+import "testing"
+
+func TestLedgerTransactionValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   *LedgerTransaction
+		ok   bool
+	}{
+		{
+			name: "wallet transaction",
+			tx:   NewWalletTransaction(TxDeposit, 7, HouseCashAccount, 1000, "EUR", "intent 1"),
+			ok:   true,
+		},
+		{
+			name: "fee and tax split",
+			tx: &LedgerTransaction{Type: TxWin, PlayerID: 7, Entries: []LedgerEntry{
+				{Account: PlayerAccount(7), Amount: 900, Currency: "EUR"},
+				{Account: HouseTaxAccount, Amount: 100, Currency: "EUR"},
+				{Account: HouseBetsAccount, Amount: -1000, Currency: "EUR"},
+			}},
+			ok: true,
+		},
+		{
+			name: "unknown type",
+			tx:   NewWalletTransaction("gift", 7, HouseCashAccount, 1000, "EUR", ""),
+		},
+		{
+			name: "single entry",
+			tx: &LedgerTransaction{Type: TxDeposit, PlayerID: 7, Entries: []LedgerEntry{
+				{Account: PlayerAccount(7), Amount: 1000, Currency: "EUR"},
+			}},
+		},
+		{
+			name: "zero amount",
+			tx:   NewWalletTransaction(TxDeposit, 7, HouseCashAccount, 0, "EUR", ""),
+		},
+		{
+			name: "no currency",
+			tx:   NewWalletTransaction(TxDeposit, 7, HouseCashAccount, 1000, "", ""),
+		},
+		{
+			name: "unbalanced",
+			tx: &LedgerTransaction{Type: TxAdjustment, PlayerID: 7, Entries: []LedgerEntry{
+				{Account: PlayerAccount(7), Amount: 1000, Currency: "EUR"},
+				{Account: HouseAdjustmentsAccount, Amount: -999, Currency: "EUR"},
+			}},
+		},
+		{
+			name: "balanced overall but not per currency",
+			tx: &LedgerTransaction{Type: TxAdjustment, PlayerID: 7, Entries: []LedgerEntry{
+				{Account: PlayerAccount(7), Amount: 1000, Currency: "EUR"},
+				{Account: HouseAdjustmentsAccount, Amount: -1000, Currency: "USD"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tx.Validate()
+			if (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParsePlayerAccount(t *testing.T) {
+	tests := []struct {
+		account string
+		id      int
+		ok      bool
+	}{
+		{PlayerAccount(42), 42, true},
+		{"player:7", 7, true},
+		{HouseCashAccount, 0, false},
+		{"player:", 0, false},
+		{"player:x", 0, false},
+		{"players:7", 0, false},
+	}
+	for _, tt := range tests {
+		id, ok := ParsePlayerAccount(tt.account)
+		if id != tt.id || ok != tt.ok {
+			t.Errorf("ParsePlayerAccount(%q) = %d, %v, want %d, %v", tt.account, id, ok, tt.id, tt.ok)
+		}
+	}
+}
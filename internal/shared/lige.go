@@ -0,0 +1,83 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrAlreadyExists is returned when a liga or a tip of a razrada is named like an existing one.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInUse is returned when deleting something other records still depend on.
+	ErrInUse = errors.New("still in use")
+)
+
+type LigaRequest struct {
+	Naziv string `json:"naziv"`
+}
+
+// Validate trims the naziv of the liga and checks it isn't empty.
+func (r *LigaRequest) Validate() error {
+	if r.Naziv = strings.TrimSpace(r.Naziv); r.Naziv == "" {
+		return &UserError{Message: "naziv is required"}
+	}
+	return nil
+}
+
+// RazradaRequest creates a razrada or moves it to another liga and replaces its ponude.
+type RazradaRequest struct {
+	LigaID int   `json:"liga_id,omitempty"`
+	Ponude []int `json:"ponude"`
+}
+
+// LigeOrderRequest lists the ids of all lige in their new order.
+type LigeOrderRequest struct {
+	Lige []int `json:"lige"`
+}
+
+// Validate checks that the order lists each of the lige exactly once.
+func (r *LigeOrderRequest) Validate(lige []int) error {
+	switch i := checkOrder(r.Lige, lige); {
+	case i == len(r.Lige):
+		return &UserError{Message: "the order must list every liga"}
+	case i >= 0:
+		return &UserError{Message: fmt.Sprintf("liga %d doesn't exist or is listed more than once", r.Lige[i])}
+	}
+	return nil
+}
+
+// TipoviOrderRequest lists the ids of all tipovi of a razrada in their new order.
+type TipoviOrderRequest struct {
+	Tipovi []int `json:"tipovi"`
+}
+
+// Validate checks that the order lists each of the tipovi of the razrada exactly once.
+func (r *TipoviOrderRequest) Validate(razradaID int, tipovi []int) error {
+	switch i := checkOrder(r.Tipovi, tipovi); {
+	case i == len(r.Tipovi):
+		return &UserError{Message: "the order must list every tip of the razrada"}
+	case i >= 0:
+		return &UserError{Message: fmt.Sprintf("tip %d isn't a tip of razrada %d or is listed more than once", r.Tipovi[i], razradaID)}
+	}
+	return nil
+}
+
+// checkOrder returns the index of the first id of order that isn't one of ids or repeats, or
+// len(order) if order leaves some of ids out. It returns -1 if order lists each of ids once.
+func checkOrder(order, ids []int) int {
+	remaining := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	for i, id := range order {
+		if !remaining[id] {
+			return i
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		return len(order)
+	}
+	return -1
+}
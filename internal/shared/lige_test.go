@@ -0,0 +1,58 @@
+package shared
+
+import "testing"
+
+func TestLigaRequestValidate(t *testing.T) {
+	req := &LigaRequest{Naziv: "  1. HNL "}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if req.Naziv != "1. HNL" {
+		t.Errorf("Validate left naziv %q, want it trimmed", req.Naziv)
+	}
+	if err := (&LigaRequest{Naziv: " \t"}).Validate(); err == nil {
+		t.Error("Validate accepted a blank naziv")
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	ids := []int{3, 1, 2}
+	tests := []struct {
+		name  string
+		order []int
+		want  int
+	}{
+		{"same order", []int{3, 1, 2}, -1},
+		{"new order", []int{1, 2, 3}, -1},
+		{"unknown id", []int{1, 4, 2, 3}, 1},
+		{"repeated id", []int{1, 2, 1, 3}, 2},
+		{"missing id", []int{1, 2}, 2},
+		{"empty order", []int{}, 0},
+		{"zero id", []int{0, 1, 2, 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkOrder(tt.order, ids); got != tt.want {
+				t.Errorf("checkOrder(%v, %v) = %d, want %d", tt.order, ids, got, tt.want)
+			}
+		})
+	}
+	if got := checkOrder(nil, nil); got != -1 {
+		t.Errorf("checkOrder(nil, nil) = %d, want -1", got)
+	}
+}
+
+func TestOrderRequestValidate(t *testing.T) {
+	if err := (&LigeOrderRequest{Lige: []int{2, 1}}).Validate([]int{1, 2}); err != nil {
+		t.Errorf("LigeOrderRequest.Validate: %v", err)
+	}
+	if err := (&LigeOrderRequest{Lige: []int{2}}).Validate([]int{1, 2}); err == nil {
+		t.Error("LigeOrderRequest.Validate accepted an order without every liga")
+	}
+	if err := (&TipoviOrderRequest{Tipovi: []int{5, 6}}).Validate(1, []int{6, 5}); err != nil {
+		t.Errorf("TipoviOrderRequest.Validate: %v", err)
+	}
+	if err := (&TipoviOrderRequest{Tipovi: []int{5, 5}}).Validate(1, []int{6, 5}); err == nil {
+		t.Error("TipoviOrderRequest.Validate accepted a repeated tip")
+	}
+}
@@ -0,0 +1,195 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Betting limits can be set globally and overridden per league, per event and per player.
+// A more specific scope wins over a less specific one, with player overrides winning over all.
+const (
+	LimitScopeGlobal = "global"
+	LimitScopeLeague = "league"
+	LimitScopeEvent  = "event"
+	LimitScopePlayer = "player"
+)
+
+const (
+	LimitMinStake         = "min_stake"
+	LimitMaxStake         = "max_stake"
+	LimitMaxPayout        = "max_payout"
+	LimitMaxSelections    = "max_selections"
+	LimitMinSelectionOdds = "min_selection_odds"
+	LimitMinTicketOdds    = "min_ticket_odds"
+)
+
+func IsLimitScope(scope string) bool {
+	switch scope {
+	case LimitScopeGlobal, LimitScopeLeague, LimitScopeEvent, LimitScopePlayer:
+		return true
+	}
+	return false
+}
+
+// BetLimits holds the limits set on one scope. Nil limits are inherited from the less specific
+// scopes. Amounts are in the base currency.
+type BetLimits struct {
+	MinStake         *Money `json:"min_stake,omitempty"`
+	MaxStake         *Money `json:"max_stake,omitempty"`
+	MaxPayout        *Money `json:"max_payout,omitempty"`
+	MaxSelections    *int   `json:"max_selections,omitempty"`
+	MinSelectionOdds *Odds  `json:"min_selection_odds,omitempty"`
+	MinTicketOdds    *Odds  `json:"min_ticket_odds,omitempty"`
+}
+
+// BetLimitRule is the set of limits configured for a scope. ScopeID is the league, event or
+// player id and 0 for the global scope.
+type BetLimitRule struct {
+	Scope   string `json:"scope"`
+	ScopeID int    `json:"scope_id"`
+	BetLimits
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrOddsChanged is returned when odds moved between pricing a ticket and placing it.
+var ErrOddsChanged = errors.New("odds changed")
+
+// BetSelection is a selection on a ticket together with its current odds and league.
+type BetSelection struct {
+	Ponuda    int    `json:"ponuda"`
+	NazivTipa string `json:"naziv"`
+	Tip
+	Tecaj     Odds `json:"tecaj"`
+	LigaID    int  `json:"liga_id"`
+	Suspended bool `json:"suspended,omitempty"`
+	Closed    bool `json:"closed,omitempty"`
+}
+
+// LimitViolation names a limit a ticket broke. Selection is the index of the offending
+// selection for per-selection limits.
+type LimitViolation struct {
+	Limit     string `json:"limit"`
+	Scope     string `json:"scope"`
+	ScopeID   int    `json:"scope_id,omitempty"`
+	Selection *int   `json:"selection,omitempty"`
+	Allowed   string `json:"allowed"`
+	Actual    string `json:"actual"`
+}
+
+// LimitError rejects a ticket that broke one or more betting limits.
+type LimitError struct {
+	Message    string
+	Violations []LimitViolation
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %d limit(s) violated", e.Message, len(e.Violations))
+}
+
+// TicketOdds multiplies the odds of all selections.
+func TicketOdds(selections []*BetSelection) Odds {
+	odds := OddsOne
+	for _, sel := range selections {
+		odds = odds.Mul(sel.Tecaj)
+	}
+	return odds
+}
+
+// CheckBetLimits checks a ticket with the given stake and potential payout, in the base currency,
+// against rules. Per-selection limits use the rules of the selection's league and event. Ticket
+// limits use the strictest value over all selections.
+func CheckBetLimits(rules []*BetLimitRule, playerID int, stake, payout Money, selections []*BetSelection) []LimitViolation {
+	var chains [][]*BetLimitRule
+	for _, sel := range selections {
+		chains = append(chains, limitChain(rules, playerID, sel))
+	}
+	if len(chains) == 0 {
+		chains = append(chains, limitChain(rules, playerID, nil))
+	}
+
+	violations := []LimitViolation{}
+	for i, sel := range selections {
+		if minOdds, rule := effectiveLimit(chains[i], func(l *BetLimits) *Odds { return l.MinSelectionOdds }); minOdds != nil && sel.Tecaj < *minOdds {
+			index := i
+			violations = append(violations, newLimitViolation(LimitMinSelectionOdds, rule, &index, minOdds.String(), sel.Tecaj.String()))
+		}
+	}
+
+	if minStake, rule := strictestLimit(chains, func(l *BetLimits) *Money { return l.MinStake }, false); minStake != nil && stake < *minStake {
+		violations = append(violations, newLimitViolation(LimitMinStake, rule, nil, minStake.String(), stake.String()))
+	}
+	if maxStake, rule := strictestLimit(chains, func(l *BetLimits) *Money { return l.MaxStake }, true); maxStake != nil && stake > *maxStake {
+		violations = append(violations, newLimitViolation(LimitMaxStake, rule, nil, maxStake.String(), stake.String()))
+	}
+	if maxSelections, rule := strictestLimit(chains, func(l *BetLimits) *int { return l.MaxSelections }, true); maxSelections != nil && len(selections) > *maxSelections {
+		violations = append(violations, newLimitViolation(LimitMaxSelections, rule, nil,
+			strconv.Itoa(*maxSelections), strconv.Itoa(len(selections))))
+	}
+	odds := TicketOdds(selections)
+	if minOdds, rule := strictestLimit(chains, func(l *BetLimits) *Odds { return l.MinTicketOdds }, false); minOdds != nil && odds < *minOdds {
+		violations = append(violations, newLimitViolation(LimitMinTicketOdds, rule, nil, minOdds.String(), odds.String()))
+	}
+	if maxPayout, rule := strictestLimit(chains, func(l *BetLimits) *Money { return l.MaxPayout }, true); maxPayout != nil && payout > *maxPayout {
+		violations = append(violations, newLimitViolation(LimitMaxPayout, rule, nil, maxPayout.String(), payout.String()))
+	}
+	return violations
+}
+
+func newLimitViolation(limit string, rule *BetLimitRule, selection *int, allowed, actual string) LimitViolation {
+	return LimitViolation{
+		Limit:     limit,
+		Scope:     rule.Scope,
+		ScopeID:   rule.ScopeID,
+		Selection: selection,
+		Allowed:   allowed,
+		Actual:    actual,
+	}
+}
+
+// limitChain returns the rules that apply to sel, from the least to the most specific.
+func limitChain(rules []*BetLimitRule, playerID int, sel *BetSelection) []*BetLimitRule {
+	var chain []*BetLimitRule
+	for _, scope := range []string{LimitScopeGlobal, LimitScopeLeague, LimitScopeEvent, LimitScopePlayer} {
+		for _, rule := range rules {
+			if rule.Scope != scope {
+				continue
+			}
+			switch {
+			case scope == LimitScopeGlobal,
+				scope == LimitScopePlayer && rule.ScopeID == playerID,
+				scope == LimitScopeLeague && sel != nil && rule.ScopeID == sel.LigaID,
+				scope == LimitScopeEvent && sel != nil && rule.ScopeID == sel.Ponuda:
+				chain = append(chain, rule)
+			}
+		}
+	}
+	return chain
+}
+
+// effectiveLimit returns the most specific value set in chain and the rule that set it.
+func effectiveLimit[T Money | Odds | int](chain []*BetLimitRule, get func(*BetLimits) *T) (*T, *BetLimitRule) {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if v := get(&chain[i].BetLimits); v != nil {
+			return v, chain[i]
+		}
+	}
+	return nil, nil
+}
+
+// strictestLimit returns the lowest effective value over all chains if lower is set, the highest otherwise.
+func strictestLimit[T Money | Odds | int](chains [][]*BetLimitRule, get func(*BetLimits) *T, lower bool) (*T, *BetLimitRule) {
+	var strictest *T
+	var strictestRule *BetLimitRule
+	for _, chain := range chains {
+		v, rule := effectiveLimit(chain, get)
+		if v == nil {
+			continue
+		}
+		if strictest == nil || (lower && *v < *strictest) || (!lower && *v > *strictest) {
+			strictest, strictestRule = v, rule
+		}
+	}
+	return strictest, strictestRule
+}
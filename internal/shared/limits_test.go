@@ -0,0 +1,168 @@
+package shared
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func limitRule(scope string, scopeID int, limits BetLimits) *BetLimitRule {
+	return &BetLimitRule{Scope: scope, ScopeID: scopeID, BetLimits: limits}
+}
+
+// violationKeys describes violations as limit@scope:id, with the selection index for
+// per-selection limits.
+func violationKeys(violations []LimitViolation) []string {
+	keys := []string{}
+	for _, v := range violations {
+		key := fmt.Sprintf("%s@%s:%d", v.Limit, v.Scope, v.ScopeID)
+		if v.Selection != nil {
+			key += fmt.Sprintf("[%d]", *v.Selection)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestCheckBetLimitsPrecedence(t *testing.T) {
+	const player = 7
+	sel := []*BetSelection{{Ponuda: 100, LigaID: 10, Tecaj: 200}}
+
+	tests := []struct {
+		name  string
+		rules []*BetLimitRule
+		stake Money
+		want  []string
+	}{
+		{
+			name:  "global applies without overrides",
+			rules: []*BetLimitRule{limitRule(LimitScopeGlobal, 0, BetLimits{MaxStake: ptr[Money](10000)})},
+			stake: 15000,
+			want:  []string{"max_stake@global:0"},
+		},
+		{
+			name: "league overrides global",
+			rules: []*BetLimitRule{
+				limitRule(LimitScopeGlobal, 0, BetLimits{MaxStake: ptr[Money](10000)}),
+				limitRule(LimitScopeLeague, 10, BetLimits{MaxStake: ptr[Money](20000)}),
+			},
+			stake: 15000,
+			want:  []string{},
+		},
+		{
+			name: "event overrides league",
+			rules: []*BetLimitRule{
+				limitRule(LimitScopeGlobal, 0, BetLimits{MaxStake: ptr[Money](10000)}),
+				limitRule(LimitScopeLeague, 10, BetLimits{MaxStake: ptr[Money](20000)}),
+				limitRule(LimitScopeEvent, 100, BetLimits{MaxStake: ptr[Money](5000)}),
+			},
+			stake: 15000,
+			want:  []string{"max_stake@event:100"},
+		},
+		{
+			name: "player overrides every other scope",
+			rules: []*BetLimitRule{
+				limitRule(LimitScopeGlobal, 0, BetLimits{MaxStake: ptr[Money](10000)}),
+				limitRule(LimitScopeEvent, 100, BetLimits{MaxStake: ptr[Money](5000)}),
+				limitRule(LimitScopePlayer, player, BetLimits{MaxStake: ptr[Money](50000)}),
+			},
+			stake: 15000,
+			want:  []string{},
+		},
+		{
+			name: "rules of other leagues, events and players don't apply",
+			rules: []*BetLimitRule{
+				limitRule(LimitScopeGlobal, 0, BetLimits{MaxStake: ptr[Money](10000)}),
+				limitRule(LimitScopeLeague, 11, BetLimits{MaxStake: ptr[Money](50000)}),
+				limitRule(LimitScopeEvent, 101, BetLimits{MaxStake: ptr[Money](50000)}),
+				limitRule(LimitScopePlayer, player+1, BetLimits{MaxStake: ptr[Money](50000)}),
+			},
+			stake: 15000,
+			want:  []string{"max_stake@global:0"},
+		},
+		{
+			name: "unset limits are inherited",
+			rules: []*BetLimitRule{
+				limitRule(LimitScopeGlobal, 0, BetLimits{MaxStake: ptr[Money](10000)}),
+				limitRule(LimitScopeEvent, 100, BetLimits{MinStake: ptr[Money](500)}),
+			},
+			stake: 15000,
+			want:  []string{"max_stake@global:0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violationKeys(CheckBetLimits(tt.rules, player, tt.stake, tt.stake.MulOdds(TicketOdds(sel)), sel))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBetLimitsStrictestAcrossChains(t *testing.T) {
+	sels := []*BetSelection{
+		{Ponuda: 100, LigaID: 10, Tecaj: 140},
+		{Ponuda: 200, LigaID: 20, Tecaj: 140},
+	}
+	rules := []*BetLimitRule{
+		limitRule(LimitScopeGlobal, 0, BetLimits{MaxPayout: ptr[Money](100000), MaxSelections: ptr(20)}),
+		limitRule(LimitScopeLeague, 10, BetLimits{MaxPayout: ptr[Money](50000), MinStake: ptr[Money](100), MinSelectionOdds: ptr[Odds](150)}),
+		limitRule(LimitScopeLeague, 20, BetLimits{MaxPayout: ptr[Money](3000), MinStake: ptr[Money](500)}),
+		limitRule(LimitScopeEvent, 200, BetLimits{MaxSelections: ptr(1), MinTicketOdds: ptr[Odds](300)}),
+	}
+
+	tests := []struct {
+		name  string
+		stake Money
+		sels  []*BetSelection
+		want  []string
+	}{
+		{
+			name:  "strictest limit of either selection",
+			stake: 300,
+			sels:  sels,
+			want: []string{
+				"min_selection_odds@league:10[0]",
+				"min_stake@league:20",
+				"max_selections@event:200",
+				"min_ticket_odds@event:200",
+			},
+		},
+		{
+			name:  "lowest max payout over the chains",
+			stake: 2000,
+			sels:  sels,
+			want: []string{
+				"min_selection_odds@league:10[0]",
+				"max_selections@event:200",
+				"min_ticket_odds@event:200",
+				"max_payout@league:20",
+			},
+		},
+		{
+			name:  "selection limits only use their own chain",
+			stake: 1000,
+			sels:  sels[1:],
+			want:  []string{"min_ticket_odds@event:200"},
+		},
+		{
+			name:  "global limits apply to an empty ticket",
+			stake: 1000,
+			sels:  nil,
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violationKeys(CheckBetLimits(rules, 1, tt.stake, tt.stake.MulOdds(TicketOdds(tt.sels)), tt.sels))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package shared
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Market types. A tip is an outcome of a market, optionally with a line: the handicap added to
+// the home team's score or the number of goals a total is compared with.
+const (
+	MarketMatchResult  = "1x2"
+	MarketDoubleChance = "double_chance"
+	MarketHandicap     = "handicap"
+	MarketTotals       = "totals"
+	MarketCorrectScore = "correct_score"
+	// MarketOther holds tipovi the server can't interpret. They are settled by listing the
+	// winning tipovi in the result.
+	MarketOther = "other"
+)
+
+const (
+	OutcomeHome       = "1"
+	OutcomeDraw       = "X"
+	OutcomeAway       = "2"
+	OutcomeHomeOrDraw = "1X"
+	OutcomeDrawOrAway = "X2"
+	OutcomeHomeOrAway = "12"
+	OutcomeOver       = "over"
+	OutcomeUnder      = "under"
+)
+
+// MaxTipNameLength is the longest tip name that fits the naziv columns of tipovi and tecajevi.
+const MaxTipNameLength = 255
+
+// Line is a handicap or total in hundredths, 2.5 is Line(250).
+type Line int64
+
+func ParseLine(s string) (Line, error) {
+	v, err := parseFixed(s, 2, false)
+	if err != nil {
+		return 0, fmt.Errorf("invalid line %q: %v", s, err)
+	}
+	return Line(v), nil
+}
+
+// String formats the line without trailing zeros, such as "2.5" or "-1".
+func (l Line) String() string {
+	s := formatFixed(int64(l), 2)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+func (l Line) MarshalJSON() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Line) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := ParseLine(strings.Trim(string(b), `"`))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+func (l Line) Value() (driver.Value, error) {
+	return formatFixed(int64(l), 2), nil
+}
+
+func (l *Line) Scan(src any) error {
+	v, err := scanFixed(src, 2, false)
+	if err != nil {
+		return err
+	}
+	*l = Line(v)
+	return nil
+}
+
+// Score is the final score of a ponuda.
+type Score struct {
+	Home int `json:"home"`
+	Away int `json:"away"`
+}
+
+func (s Score) String() string {
+	return fmt.Sprintf("%d:%d", s.Home, s.Away)
+}
+
+func parseScore(outcome string) (Score, bool) {
+	home, away, found := strings.Cut(outcome, ":")
+	if !found {
+		return Score{}, false
+	}
+	h, err := strconv.Atoi(home)
+	if err != nil || h < 0 {
+		return Score{}, false
+	}
+	a, err := strconv.Atoi(away)
+	if err != nil || a < 0 {
+		return Score{}, false
+	}
+	return Score{Home: h, Away: a}, true
+}
+
+// Tip identifies an outcome of a market.
+type Tip struct {
+	Market  string `json:"market,omitempty"`
+	Line    *Line  `json:"line,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// ParseTipName works out the market of a tip from its name: "1", "X2", "over 2.5",
+// "1 (-1.5)" or "2:1". Names it doesn't recognise are in MarketOther.
+func ParseTipName(naziv string) Tip {
+	name := strings.TrimSpace(naziv)
+	switch strings.ToUpper(name) {
+	case OutcomeHome, OutcomeDraw, OutcomeAway:
+		return Tip{Market: MarketMatchResult, Outcome: strings.ToUpper(name)}
+	case OutcomeHomeOrDraw, OutcomeDrawOrAway, OutcomeHomeOrAway, "2X", "21", "X1":
+		return Tip{Market: MarketDoubleChance, Outcome: normalizeDoubleChance(strings.ToUpper(name))}
+	}
+	if _, ok := parseScore(name); ok {
+		return Tip{Market: MarketCorrectScore, Outcome: name}
+	}
+	if outcome, line, found := strings.Cut(strings.ToLower(name), " "); found && (outcome == OutcomeOver || outcome == OutcomeUnder) {
+		if l, err := ParseLine(strings.TrimSpace(line)); err == nil && l >= 0 {
+			return Tip{Market: MarketTotals, Line: &l, Outcome: outcome}
+		}
+	}
+	if outcome, line, found := strings.Cut(name, " ("); found && strings.HasSuffix(line, ")") {
+		outcome = strings.ToUpper(outcome)
+		if l, err := ParseLine(strings.TrimSuffix(line, ")")); err == nil &&
+			(outcome == OutcomeHome || outcome == OutcomeDraw || outcome == OutcomeAway) {
+			return Tip{Market: MarketHandicap, Line: &l, Outcome: outcome}
+		}
+	}
+	return Tip{Market: MarketOther, Outcome: name}
+}
+
+func normalizeDoubleChance(outcome string) string {
+	switch outcome {
+	case "X1":
+		return OutcomeHomeOrDraw
+	case "2X":
+		return OutcomeDrawOrAway
+	case "21":
+		return OutcomeHomeOrAway
+	}
+	return outcome
+}
+
+// Name is the canonical name of the tip, the inverse of ParseTipName.
+func (t Tip) Name() string {
+	switch t.Market {
+	case MarketTotals:
+		return fmt.Sprintf("%s %s", t.Outcome, t.Line)
+	case MarketHandicap:
+		sign := ""
+		if *t.Line >= 0 {
+			sign = "+"
+		}
+		return fmt.Sprintf("%s (%s%s)", t.Outcome, sign, t.Line)
+	}
+	return t.Outcome
+}
+
+// Validate checks that the outcome and line make sense for the market.
+func (t Tip) Validate() error {
+	needsLine := t.Market == MarketTotals || t.Market == MarketHandicap
+	if needsLine && t.Line == nil {
+		return &UserError{Message: fmt.Sprintf("%s tipovi need a line", t.Market)}
+	}
+	if !needsLine && t.Line != nil {
+		return &UserError{Message: fmt.Sprintf("%s tipovi have no line", t.Market)}
+	}
+	valid := false
+	switch t.Market {
+	case MarketMatchResult, MarketHandicap:
+		valid = t.Outcome == OutcomeHome || t.Outcome == OutcomeDraw || t.Outcome == OutcomeAway
+	case MarketDoubleChance:
+		valid = t.Outcome == OutcomeHomeOrDraw || t.Outcome == OutcomeDrawOrAway || t.Outcome == OutcomeHomeOrAway
+	case MarketTotals:
+		valid = (t.Outcome == OutcomeOver || t.Outcome == OutcomeUnder) && *t.Line >= 0
+	case MarketCorrectScore:
+		_, valid = parseScore(t.Outcome)
+	case MarketOther:
+		valid = t.Outcome != ""
+	default:
+		return &UserError{Message: fmt.Sprintf("unknown market %s", t.Market)}
+	}
+	if !valid {
+		return &UserError{Message: fmt.Sprintf("invalid %s outcome %s", t.Market, t.Outcome)}
+	}
+	return nil
+}
+
+// Settle returns BetWon, BetLost or BetVoid for the tip given the final score. A total equal to
+// the line is void. It returns false for MarketOther, which can't be settled from a score.
+func (t Tip) Settle(score Score) (string, bool) {
+	result := func(won bool) (string, bool) {
+		if won {
+			return BetWon, true
+		}
+		return BetLost, true
+	}
+	matchResult := func(home, away int64) string {
+		switch {
+		case home > away:
+			return OutcomeHome
+		case home < away:
+			return OutcomeAway
+		}
+		return OutcomeDraw
+	}
+
+	home, away := int64(score.Home)*100, int64(score.Away)*100
+	switch t.Market {
+	case MarketMatchResult:
+		return result(matchResult(home, away) == t.Outcome)
+	case MarketDoubleChance:
+		return result(strings.Contains(t.Outcome, matchResult(home, away)))
+	case MarketHandicap:
+		return result(matchResult(home+int64(*t.Line), away) == t.Outcome)
+	case MarketTotals:
+		total, line := home+away, int64(*t.Line)
+		if total == line {
+			return BetVoid, true
+		}
+		return result((total > line) == (t.Outcome == OutcomeOver))
+	case MarketCorrectScore:
+		s, _ := parseScore(t.Outcome)
+		return result(s == score)
+	}
+	return "", false
+}
+
+// Normalize works out the market of the tecaj from its name or, if the market is given, checks
+// it and names the tecaj after it when it has no name.
+func (t *Tecajevi) Normalize() error {
+	return normalizeTip(&t.Naziv, &t.Tip)
+}
+
+// Normalize works out the market of a tip defined for a razrada, like Tecajevi.Normalize.
+func (t *Tipovi) Normalize() error {
+	return normalizeTip(&t.Naziv, &t.Tip)
+}
+
+func normalizeTip(naziv *string, tip *Tip) error {
+	if len(*naziv) > MaxTipNameLength {
+		return &UserError{Message: fmt.Sprintf("tip naziv must be at most %d characters long", MaxTipNameLength)}
+	}
+	if tip.Market == "" {
+		if strings.TrimSpace(*naziv) == "" {
+			return &UserError{Message: "a tip needs a naziv or a market"}
+		}
+		*tip = ParseTipName(*naziv)
+		return nil
+	}
+	if err := tip.Validate(); err != nil {
+		return err
+	}
+	if *naziv == "" {
+		*naziv = tip.Name()
+	}
+	return nil
+}
+
+// key identifies the tip regardless of how its name is written.
+func (t Tip) key() string {
+	return t.Market + "|" + t.Name()
+}
+
+// Validate checks that the selection names its tip or gives a valid market and outcome.
+func (p *OdigraniPar) Validate() error {
+	if p.NazivTipa != "" {
+		return nil
+	}
+	if p.Market == "" {
+		return &UserError{Message: fmt.Sprintf("selection on ponuda %d needs a naziv or a market", p.Ponuda)}
+	}
+	return p.Tip.Validate()
+}
+
+// MarketColumn is a market of a razrada with its tipovi in the order they're shown, a group of
+// columns of the odds grid.
+type MarketColumn struct {
+	Market string   `json:"market"`
+	Line   *Line    `json:"line,omitempty"`
+	Tipovi []string `json:"tipovi"`
+}
+
+// MarketColumns groups the tipovi of a razrada by market and line, keeping their order.
+func MarketColumns(tipovi []Tipovi) []MarketColumn {
+	columns := []MarketColumn{}
+	index := make(map[string]int)
+	for _, t := range tipovi {
+		key := t.Market
+		if t.Line != nil {
+			key += "|" + t.Line.String()
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(columns)
+			index[key] = i
+			columns = append(columns, MarketColumn{Market: t.Market, Line: t.Line, Tipovi: []string{}})
+		}
+		columns[i].Tipovi = append(columns[i].Tipovi, t.Naziv)
+	}
+	return columns
+}
+
+// RazradaMismatch is a ponuda of a razrada whose tecajevi don't match the tipovi defined for
+// the razrada. Missing tipovi have no tecaj and unexpected tecajevi aren't defined.
+type RazradaMismatch struct {
+	RazradaID  int      `json:"razrada_id"`
+	LigaID     int      `json:"liga_id"`
+	Ponuda     int      `json:"ponuda"`
+	Missing    []string `json:"missing"`
+	Unexpected []string `json:"unexpected"`
+}
+
+// CheckRazrada checks that every ponuda of the razrada offers exactly its tipovi. Tipovi and
+// tecajevi are matched by market, line and outcome, so "x" matches "X". offered maps a ponuda
+// to its tecajevi.
+func CheckRazrada(ligaID int, razrada *Razrade, offered map[int][]Tecajevi) []RazradaMismatch {
+	defined := make(map[string]bool, len(razrada.Tipovi))
+	for _, t := range razrada.Tipovi {
+		defined[t.Tip.key()] = true
+	}
+
+	mismatches := []RazradaMismatch{}
+	for _, ponuda := range razrada.Ponude {
+		m := RazradaMismatch{RazradaID: razrada.ID, LigaID: ligaID, Ponuda: ponuda, Missing: []string{}, Unexpected: []string{}}
+		offers := make(map[string]bool, len(offered[ponuda]))
+		for _, t := range offered[ponuda] {
+			offers[t.Tip.key()] = true
+			if !defined[t.Tip.key()] {
+				m.Unexpected = append(m.Unexpected, t.Naziv)
+			}
+		}
+		for _, t := range razrada.Tipovi {
+			if !offers[t.Tip.key()] {
+				m.Missing = append(m.Missing, t.Naziv)
+			}
+		}
+		if len(m.Missing) > 0 || len(m.Unexpected) > 0 {
+			mismatches = append(mismatches, m)
+		}
+	}
+	return mismatches
+}
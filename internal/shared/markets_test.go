@@ -0,0 +1,208 @@
+package shared
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTipName(t *testing.T) {
+	tests := []struct {
+		naziv string
+		want  Tip
+		name  string
+	}{
+		{"1", Tip{Market: MarketMatchResult, Outcome: OutcomeHome}, "1"},
+		{"x", Tip{Market: MarketMatchResult, Outcome: OutcomeDraw}, "X"},
+		{" 2 ", Tip{Market: MarketMatchResult, Outcome: OutcomeAway}, "2"},
+		{"X1", Tip{Market: MarketDoubleChance, Outcome: OutcomeHomeOrDraw}, "1X"},
+		{"1X", Tip{Market: MarketDoubleChance, Outcome: OutcomeHomeOrDraw}, "1X"},
+		{"2x", Tip{Market: MarketDoubleChance, Outcome: OutcomeDrawOrAway}, "X2"},
+		{"21", Tip{Market: MarketDoubleChance, Outcome: OutcomeHomeOrAway}, "12"},
+		{"1 (-1.5)", Tip{Market: MarketHandicap, Line: ptr(Line(-150)), Outcome: OutcomeHome}, "1 (-1.5)"},
+		{"1 (1.5)", Tip{Market: MarketHandicap, Line: ptr(Line(150)), Outcome: OutcomeHome}, "1 (+1.5)"},
+		{"x (+1)", Tip{Market: MarketHandicap, Line: ptr(Line(100)), Outcome: OutcomeDraw}, "X (+1)"},
+		{"2 (0)", Tip{Market: MarketHandicap, Line: ptr(Line(0)), Outcome: OutcomeAway}, "2 (+0)"},
+		{"over 2.5", Tip{Market: MarketTotals, Line: ptr(Line(250)), Outcome: OutcomeOver}, "over 2.5"},
+		{"Under 3", Tip{Market: MarketTotals, Line: ptr(Line(300)), Outcome: OutcomeUnder}, "under 3"},
+		{"2:1", Tip{Market: MarketCorrectScore, Outcome: "2:1"}, "2:1"},
+		{"0:0", Tip{Market: MarketCorrectScore, Outcome: "0:0"}, "0:0"},
+		{"over -1", Tip{Market: MarketOther, Outcome: "over -1"}, "over -1"},
+		{"1 (abc)", Tip{Market: MarketOther, Outcome: "1 (abc)"}, "1 (abc)"},
+		{"F+2", Tip{Market: MarketOther, Outcome: "F+2"}, "F+2"},
+		{"-1:0", Tip{Market: MarketOther, Outcome: "-1:0"}, "-1:0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.naziv, func(t *testing.T) {
+			got := ParseTipName(tt.naziv)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseTipName(%q) = %+v, want %+v", tt.naziv, got, tt.want)
+			}
+			if name := got.Name(); name != tt.name {
+				t.Errorf("Name() = %q, want %q", name, tt.name)
+			}
+			if again := ParseTipName(got.Name()); !reflect.DeepEqual(again, got) {
+				t.Errorf("ParseTipName(%q) = %+v, want %+v", got.Name(), again, got)
+			}
+		})
+	}
+}
+
+// Handicap lines are added to the home team's score, also for X and 2.
+func TestTipSettle(t *testing.T) {
+	tests := []struct {
+		naziv string
+		score Score
+		want  string
+	}{
+		{"1", Score{2, 1}, BetWon},
+		{"1", Score{1, 1}, BetLost},
+		{"X", Score{1, 1}, BetWon},
+		{"2", Score{0, 3}, BetWon},
+
+		{"1X", Score{2, 0}, BetWon},
+		{"1X", Score{0, 0}, BetWon},
+		{"1X", Score{0, 1}, BetLost},
+		{"X2", Score{1, 1}, BetWon},
+		{"X2", Score{1, 0}, BetLost},
+		{"12", Score{3, 2}, BetWon},
+		{"12", Score{2, 3}, BetWon},
+		{"12", Score{2, 2}, BetLost},
+
+		{"1 (-1.5)", Score{2, 0}, BetWon},
+		{"1 (-1.5)", Score{2, 1}, BetLost},
+		{"2 (+1.5)", Score{1, 1}, BetLost},
+		{"2 (+1.5)", Score{0, 2}, BetWon},
+		{"2 (-1.5)", Score{2, 1}, BetWon},
+		{"2 (-1.5)", Score{3, 1}, BetLost},
+		{"1 (+0.5)", Score{0, 0}, BetWon},
+		{"1 (-1)", Score{3, 1}, BetWon},
+		{"1 (-1)", Score{2, 1}, BetLost},
+		{"X (-1)", Score{2, 1}, BetWon},
+		{"X (-1)", Score{1, 1}, BetLost},
+		{"2 (-1)", Score{1, 1}, BetWon},
+		{"2 (+1)", Score{0, 2}, BetWon},
+		{"X (+1)", Score{0, 1}, BetWon},
+
+		{"over 2.5", Score{2, 1}, BetWon},
+		{"over 2.5", Score{1, 1}, BetLost},
+		{"under 2.5", Score{1, 1}, BetWon},
+		{"over 3", Score{2, 1}, BetVoid},
+		{"under 3", Score{2, 1}, BetVoid},
+		{"over 3", Score{2, 2}, BetWon},
+		{"under 3", Score{1, 1}, BetWon},
+		{"over 0", Score{0, 0}, BetVoid},
+
+		{"2:1", Score{2, 1}, BetWon},
+		{"2:1", Score{1, 2}, BetLost},
+		{"0:0", Score{0, 0}, BetWon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.naziv+" at "+tt.score.String(), func(t *testing.T) {
+			got, ok := ParseTipName(tt.naziv).Settle(tt.score)
+			if !ok {
+				t.Fatalf("Settle didn't settle %s", tt.naziv)
+			}
+			if got != tt.want {
+				t.Errorf("Settle = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTipSettleOther(t *testing.T) {
+	if status, ok := ParseTipName("F+2").Settle(Score{1, 0}); ok {
+		t.Errorf("Settle settled an other market tip to %s", status)
+	}
+}
+
+func TestPonudaResultSettleBet(t *testing.T) {
+	finished := &PonudaResult{Status: ResultFinished, Score: &Score{2, 1}, WinningTipovi: []string{"F+2"}}
+	noScore := &PonudaResult{Status: ResultFinished, WinningTipovi: []string{"1"}}
+	cancelled := &PonudaResult{Status: ResultCancelled}
+
+	tests := []struct {
+		name   string
+		result *PonudaResult
+		naziv  string
+		want   string
+	}{
+		{"settled from the score", finished, "over 2.5", BetWon},
+		{"other market from the winning tipovi", finished, "F+2", BetWon},
+		{"without a score from the winning tipovi", noScore, "1", BetWon},
+		{"without a score and not listed", noScore, "X", BetLost},
+		{"cancelled ponuda", cancelled, "1", BetVoid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.SettleBet(tt.naziv, ParseTipName(tt.naziv)); got != tt.want {
+				t.Errorf("SettleBet(%s) = %s, want %s", tt.naziv, got, tt.want)
+			}
+		})
+	}
+}
+
+func tipovi(nazivi ...string) []Tipovi {
+	tipovi := make([]Tipovi, len(nazivi))
+	for i, naziv := range nazivi {
+		tipovi[i] = Tipovi{Naziv: naziv, Tip: ParseTipName(naziv)}
+	}
+	return tipovi
+}
+
+func TestMarketColumns(t *testing.T) {
+	got := MarketColumns(tipovi("1", "X", "2", "over 2.5", "under 2.5", "over 3.5", "1X", "under 3.5"))
+	want := []MarketColumn{
+		{Market: MarketMatchResult, Tipovi: []string{"1", "X", "2"}},
+		{Market: MarketTotals, Line: ptr(Line(250)), Tipovi: []string{"over 2.5", "under 2.5"}},
+		{Market: MarketTotals, Line: ptr(Line(350)), Tipovi: []string{"over 3.5", "under 3.5"}},
+		{Market: MarketDoubleChance, Tipovi: []string{"1X"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MarketColumns = %+v, want %+v", got, want)
+	}
+	if got := MarketColumns(nil); got == nil || len(got) != 0 {
+		t.Errorf("MarketColumns(nil) = %#v, want an empty slice", got)
+	}
+}
+
+func TestCheckRazrada(t *testing.T) {
+	razrada := &Razrade{ID: 3, Tipovi: tipovi("1", "X", "2"), Ponude: []int{10, 11, 12}}
+	offered := map[int][]Tecajevi{
+		10: {{Naziv: "1", Tip: ParseTipName("1")}, {Naziv: "x", Tip: ParseTipName("x")}, {Naziv: "2", Tip: ParseTipName("2")}},
+		11: {{Naziv: "1", Tip: ParseTipName("1")}, {Naziv: "2", Tip: ParseTipName("2")}, {Naziv: "over 2.5", Tip: ParseTipName("over 2.5")}},
+	}
+
+	got := CheckRazrada(7, razrada, offered)
+	want := []RazradaMismatch{
+		{RazradaID: 3, LigaID: 7, Ponuda: 11, Missing: []string{"X"}, Unexpected: []string{"over 2.5"}},
+		{RazradaID: 3, LigaID: 7, Ponuda: 12, Missing: []string{"1", "X", "2"}, Unexpected: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckRazrada = %+v, want %+v", got, want)
+	}
+}
+
+func TestTipoviNormalize(t *testing.T) {
+	named := Tipovi{Naziv: "over 2.5"}
+	if err := named.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if want := (Tip{Market: MarketTotals, Line: ptr(Line(250)), Outcome: OutcomeOver}); !reflect.DeepEqual(named.Tip, want) {
+		t.Errorf("Normalize parsed %+v, want %+v", named.Tip, want)
+	}
+
+	fromMarket := Tipovi{Tip: Tip{Market: MarketDoubleChance, Outcome: OutcomeDrawOrAway}}
+	if err := fromMarket.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if fromMarket.Naziv != "X2" {
+		t.Errorf("Normalize named the tip %q, want X2", fromMarket.Naziv)
+	}
+
+	var empty Tipovi
+	var userErr *UserError
+	if err := empty.Normalize(); !errors.As(err, &userErr) {
+		t.Errorf("Normalize of an empty tip = %v, want a UserError", err)
+	}
+}
@@ -0,0 +1,282 @@
+package shared
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount in hundredths of the currency unit (cents).
+type Money int64
+
+// Odds are decimal odds in hundredths, 1.85 is Odds(185).
+type Odds int64
+
+// Rate is a ratio with six decimals, such as an exchange rate (the value of one unit of a
+// currency in the base currency) or a fee or tax percentage.
+type Rate int64
+
+const (
+	MoneyUnit Money = 100
+	OddsOne   Odds  = 100
+	RateOne   Rate  = 1000000
+)
+
+const (
+	fixedScale = 100
+	rateScale  = 1000000
+)
+
+// ParseMoney parses a decimal amount such as "12.5" or "-3.05". More than two decimals are rejected.
+func ParseMoney(s string) (Money, error) {
+	v, err := parseFixed(s, 2, false)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %v", s, err)
+	}
+	return Money(v), nil
+}
+
+// ParseOdds parses decimal odds such as "1.85". Further decimals are rounded half up.
+func ParseOdds(s string) (Odds, error) {
+	v, err := parseFixed(s, 2, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid odds %q: %v", s, err)
+	}
+	return Odds(v), nil
+}
+
+// ParseRate parses an exchange rate such as "0.924531". Further decimals are rounded half up.
+func ParseRate(s string) (Rate, error) {
+	v, err := parseFixed(s, 6, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %v", s, err)
+	}
+	return Rate(v), nil
+}
+
+func (m Money) String() string {
+	return formatFixed(int64(m), 2)
+}
+
+// MulRate returns m times r, rounding half up to the cent.
+func (m Money) MulRate(r Rate) Money {
+	return Money(mulDivRound(int64(m), int64(r), rateScale))
+}
+
+// ToBase converts m into the base currency at rate r, rounding half up to the cent.
+func (m Money) ToBase(r Rate) Money {
+	return m.MulRate(r)
+}
+
+// FromBase converts m from the base currency into a currency with rate r, rounding half up to the cent.
+func (m Money) FromBase(r Rate) Money {
+	if r == 0 {
+		return 0
+	}
+	return Money(mulDivRound(int64(m), rateScale, int64(r)))
+}
+
+// MulOdds returns the payout of stake m at odds o, rounding half up to the cent.
+func (m Money) MulOdds(o Odds) Money {
+	return Money(mulDivRound(int64(m), int64(o), fixedScale))
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := ParseMoney(strings.Trim(string(b), `"`))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src any) error {
+	v, err := scanFixed(src, 2, false)
+	if err != nil {
+		return err
+	}
+	*m = Money(v)
+	return nil
+}
+
+func (o Odds) String() string {
+	return formatFixed(int64(o), 2)
+}
+
+// Mul combines two odds, rounding half up to two decimals.
+func (o Odds) Mul(p Odds) Odds {
+	return Odds(mulDivRound(int64(o), int64(p), fixedScale))
+}
+
+func (o Odds) MarshalJSON() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+func (o *Odds) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := ParseOdds(strings.Trim(string(b), `"`))
+	if err != nil {
+		return err
+	}
+	*o = v
+	return nil
+}
+
+func (o Odds) Value() (driver.Value, error) {
+	return o.String(), nil
+}
+
+func (o *Odds) Scan(src any) error {
+	v, err := scanFixed(src, 2, true)
+	if err != nil {
+		return err
+	}
+	*o = Odds(v)
+	return nil
+}
+
+func (r Rate) String() string {
+	return formatFixed(int64(r), 6)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := ParseRate(strings.Trim(string(b), `"`))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func (r *Rate) Scan(src any) error {
+	v, err := scanFixed(src, 6, true)
+	if err != nil {
+		return err
+	}
+	*r = Rate(v)
+	return nil
+}
+
+// mulDivRound returns a*b/c rounded half away from zero without overflowing in between.
+// c must be positive.
+func mulDivRound(a, b, c int64) int64 {
+	n := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	half := big.NewInt(c / 2)
+	if n.Sign() < 0 {
+		n.Sub(n, half)
+	} else {
+		n.Add(n, half)
+	}
+	return n.Quo(n, big.NewInt(c)).Int64()
+}
+
+func formatFixed(v int64, decimals int) string {
+	scale := pow10(decimals)
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign = "-"
+		u = uint64(-v)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, u/uint64(scale), decimals, u%uint64(scale))
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// parseFixed parses a decimal string into an integer with the given number of decimals
+// without going through float64. Extra decimals are rounded half up if round is set.
+func parseFixed(s string, decimals int, round bool) (int64, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("not a decimal number")
+		}
+	}
+
+	roundUp := false
+	if len(frac) > decimals {
+		if !round && strings.Trim(frac[decimals:], "0") != "" {
+			return 0, fmt.Errorf("more than %d decimals", decimals)
+		}
+		roundUp = frac[decimals] >= '5'
+		frac = frac[:decimals]
+	}
+	frac += strings.Repeat("0", decimals-len(frac))
+
+	scale := pow10(decimals)
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/scale-1 {
+		return 0, fmt.Errorf("out of range")
+	}
+	var part int64
+	if frac != "" {
+		part, _ = strconv.ParseInt(frac, 10, 64)
+	}
+	v := units*scale + part
+	if roundUp {
+		v++
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+func scanFixed(src any, decimals int, round bool) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case []byte:
+		return parseFixed(string(v), decimals, round)
+	case string:
+		return parseFixed(v, decimals, round)
+	case int64:
+		return v * pow10(decimals), nil
+	case float64:
+		return int64(math.Round(v * float64(pow10(decimals)))), nil
+	default:
+		return 0, fmt.Errorf("can't scan %T into a fixed-point number", src)
+	}
+}
@@ -0,0 +1,220 @@
+package shared
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "12.5", want: 1250},
+		{in: "12.50", want: 1250},
+		{in: "0.01", want: 1},
+		{in: ".5", want: 50},
+		{in: "7.", want: 700},
+		{in: "-3.05", want: -305},
+		{in: "+3.05", want: 305},
+		{in: " 4 ", want: 400},
+		{in: "1.000", want: 100},
+		{in: "1.005", wantErr: true},
+		{in: "0.001", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "1,50", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "92233720368547756", want: 9223372036854775600},
+		{in: "92233720368547758", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseOddsAndRateRound(t *testing.T) {
+	odds := []struct {
+		in   string
+		want Odds
+	}{
+		{"1.85", 185},
+		{"1.854", 185},
+		{"1.855", 186},
+		{"1.995", 200},
+		{"0.005", 1},
+		{"-1.005", -101},
+	}
+	for _, tt := range odds {
+		if got, err := ParseOdds(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseOdds(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	rates := []struct {
+		in   string
+		want Rate
+	}{
+		{"0.924531", 924531},
+		{"1", RateOne},
+		{"0.0000004", 0},
+		{"0.0000005", 1},
+		{"1.2345675", 1234568},
+	}
+	for _, tt := range rates {
+		if got, err := ParseRate(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatFixed(t *testing.T) {
+	tests := []struct {
+		v        int64
+		decimals int
+		want     string
+	}{
+		{0, 2, "0.00"},
+		{5, 2, "0.05"},
+		{-5, 2, "-0.05"},
+		{-100, 2, "-1.00"},
+		{-1234, 2, "-12.34"},
+		{123456789, 2, "1234567.89"},
+		{-1, 6, "-0.000001"},
+		{1500000, 6, "1.500000"},
+		{math.MinInt64, 2, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := formatFixed(tt.v, tt.decimals); got != tt.want {
+			t.Errorf("formatFixed(%d, %d) = %s, want %s", tt.v, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyStringRoundTrip(t *testing.T) {
+	for _, m := range []Money{0, 1, -1, 99, -1050, 123456} {
+		got, err := ParseMoney(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseMoney(%s) = %d, %v, want %d", m, got, err, m)
+		}
+	}
+}
+
+func TestMoneyMulRate(t *testing.T) {
+	tests := []struct {
+		m    Money
+		r    Rate
+		want Money
+	}{
+		{1000, 50000, 50},
+		{1001, 500000, 501},
+		{-1001, 500000, -501},
+		{1003, 500000, 502},
+		{999, 1, 0},
+		{1, 499999, 0},
+		{1, 500000, 1},
+		{math.MaxInt64 / 2, 2 * RateOne, math.MaxInt64 - 1},
+	}
+	for _, tt := range tests {
+		if got := tt.m.MulRate(tt.r); got != tt.want {
+			t.Errorf("%d.MulRate(%d) = %d, want %d", tt.m, tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyBaseConversion(t *testing.T) {
+	tests := []struct {
+		m        Money
+		r        Rate
+		toBase   Money
+		fromBase Money
+	}{
+		{1000, RateOne, 1000, 1000},
+		{1000, 1085000, 1085, 922},
+		{1, 1500000, 2, 1},
+		{-1000, 1085000, -1085, -922},
+		{10000, 133333, 1333, 75000},
+	}
+	for _, tt := range tests {
+		if got := tt.m.ToBase(tt.r); got != tt.toBase {
+			t.Errorf("%d.ToBase(%d) = %d, want %d", tt.m, tt.r, got, tt.toBase)
+		}
+		if got := tt.m.FromBase(tt.r); got != tt.fromBase {
+			t.Errorf("%d.FromBase(%d) = %d, want %d", tt.m, tt.r, got, tt.fromBase)
+		}
+	}
+	if got := Money(1000).FromBase(0); got != 0 {
+		t.Errorf("FromBase without a rate = %d, want 0", got)
+	}
+}
+
+func TestMoneyMulOdds(t *testing.T) {
+	tests := []struct {
+		m    Money
+		o    Odds
+		want Money
+	}{
+		{1000, 185, 1850},
+		{333, 185, 616},
+		{101, 185, 187},
+		{1, 150, 2},
+		{1, 149, 1},
+		{0, 250, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.m.MulOdds(tt.o); got != tt.want {
+			t.Errorf("%d.MulOdds(%d) = %d, want %d", tt.m, tt.o, got, tt.want)
+		}
+	}
+}
+
+func TestOddsMul(t *testing.T) {
+	tests := []struct {
+		a, b Odds
+		want Odds
+	}{
+		{185, 210, 389},
+		{150, 150, 225},
+		{101, 101, 102},
+		{OddsOne, 333, 333},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Mul(tt.b); got != tt.want {
+			t.Errorf("%d.Mul(%d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var m Money
+	if err := m.UnmarshalJSON([]byte(`"12.34"`)); err != nil || m != 1234 {
+		t.Errorf("UnmarshalJSON string = %d, %v, want 1234", m, err)
+	}
+	if err := m.UnmarshalJSON([]byte(`5`)); err != nil || m != 500 {
+		t.Errorf("UnmarshalJSON number = %d, %v, want 500", m, err)
+	}
+	if err := m.UnmarshalJSON([]byte(`1.234`)); err == nil {
+		t.Error("UnmarshalJSON accepted three decimals")
+	}
+	if b, _ := Money(-5).MarshalJSON(); string(b) != "-0.05" {
+		t.Errorf("MarshalJSON = %s, want -0.05", b)
+	}
+}
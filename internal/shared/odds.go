@@ -0,0 +1,108 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sources of odds changes. Initial odds were set before the history was kept. Exposure changes
+// are the suspensions of outcomes whose liability reached their threshold.
+const (
+	OddsSourceFeed     = "feed"
+	OddsSourceTrader   = "trader"
+	OddsSourceInitial  = "initial"
+	OddsSourceExposure = "exposure"
+)
+
+var (
+	// ErrSelectionSuspended is returned when a ticket is placed on a suspended tip.
+	ErrSelectionSuspended = errors.New("selection suspended")
+	// ErrTraderOdds is returned when the feed would overwrite odds a trader set.
+	ErrTraderOdds = errors.New("odds set by a trader")
+)
+
+// TecajChange is a change of the odds of a tip on a ponuda or of its suspension. Previous is
+// nil when the tip was first offered.
+type TecajChange struct {
+	ID        int       `json:"id"`
+	TecajID   int       `json:"tecaj_id"`
+	PonudaID  int       `json:"ponuda_id"`
+	Naziv     string    `json:"naziv"`
+	Tecaj     Odds      `json:"tecaj"`
+	Previous  *Odds     `json:"previous,omitempty"`
+	Suspended bool      `json:"suspended"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Set changes the odds to tecaj and keeps the odds it had as Previous. It returns false if the
+// odds already are tecaj.
+func (c *TecajChange) Set(tecaj Odds) bool {
+	if c.Tecaj == tecaj {
+		return false
+	}
+	previous := c.Tecaj
+	c.Tecaj, c.Previous = tecaj, &previous
+	return true
+}
+
+// TecajUpdate changes the odds of a tip, suspends it or both. The tip is picked by ID or by
+// ponuda and naziv. Nil fields are left as they are.
+type TecajUpdate struct {
+	ID        int    `json:"id,omitempty"`
+	Ponuda    int    `json:"ponuda,omitempty"`
+	Naziv     string `json:"naziv,omitempty"`
+	Tecaj     *Odds  `json:"tecaj,omitempty"`
+	Suspended *bool  `json:"suspended,omitempty"`
+}
+
+func (u *TecajUpdate) Validate() error {
+	if u.ID == 0 && (u.Ponuda == 0 || u.Naziv == "") {
+		return &UserError{Message: "an update needs the id of the tecaj or its ponuda and naziv"}
+	}
+	if u.Tecaj == nil && u.Suspended == nil {
+		return &UserError{Message: "an update needs a tecaj or suspended"}
+	}
+	if u.Tecaj != nil && *u.Tecaj <= OddsOne {
+		return &UserError{Message: fmt.Sprintf("odds must be above %s, got %s", OddsOne, *u.Tecaj)}
+	}
+	return nil
+}
+
+// TecajRequest sets the odds of a single tip. Reason is kept in the odds history.
+type TecajRequest struct {
+	Tecaj     *Odds  `json:"tecaj"`
+	Suspended *bool  `json:"suspended,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// BulkTecajRequest applies many updates at once. Either all of them are applied or none.
+type BulkTecajRequest struct {
+	Updates []TecajUpdate `json:"updates"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+// SuspendRequest suspends or unsuspends a market of a ponuda, or the whole ponuda if Market is
+// empty. Line picks one line of a market with several.
+type SuspendRequest struct {
+	Market string `json:"market,omitempty"`
+	Line   *Line  `json:"line,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// TecajHistoryFilter narrows the odds history of a ponuda to one tip and to changes between
+// From (inclusive) and To (exclusive).
+type TecajHistoryFilter struct {
+	Naziv string
+	From  *time.Time
+	To    *time.Time
+}
+
+// TecajChangeFeed is a page of odds changes. Cursor is the id of the last change the client has
+// seen and is passed as since to get the next page.
+type TecajChangeFeed struct {
+	Changes []*TecajChange `json:"changes"`
+	Cursor  int            `json:"cursor"`
+}
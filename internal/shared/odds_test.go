@@ -0,0 +1,48 @@
+package shared
+
+import "testing"
+
+func TestTecajChangeSet(t *testing.T) {
+	change := &TecajChange{Tecaj: 185}
+	if !change.Set(190) {
+		t.Fatal("Set(1.90) reported no change from 1.85")
+	}
+	if change.Tecaj != 190 || change.Previous == nil || *change.Previous != 185 {
+		t.Errorf("after Set(1.90) tecaj = %s, previous = %v, want 1.90 and 1.85", change.Tecaj, change.Previous)
+	}
+
+	unchanged := &TecajChange{Tecaj: 185}
+	if unchanged.Set(185) {
+		t.Error("Set(1.85) reported a change from 1.85")
+	}
+	if unchanged.Previous != nil {
+		t.Errorf("Set without a change kept previous %s", *unchanged.Previous)
+	}
+}
+
+func TestTecajUpdateValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update TecajUpdate
+		ok     bool
+	}{
+		{"by id", TecajUpdate{ID: 1, Tecaj: ptr(Odds(150))}, true},
+		{"by ponuda and naziv", TecajUpdate{Ponuda: 2, Naziv: "X", Tecaj: ptr(Odds(320))}, true},
+		{"suspension only", TecajUpdate{ID: 1, Suspended: ptr(true)}, true},
+		{"odds and suspension", TecajUpdate{ID: 1, Tecaj: ptr(Odds(101)), Suspended: ptr(false)}, true},
+		{"no tip", TecajUpdate{Tecaj: ptr(Odds(150))}, false},
+		{"ponuda without naziv", TecajUpdate{Ponuda: 2, Tecaj: ptr(Odds(150))}, false},
+		{"naziv without ponuda", TecajUpdate{Naziv: "1", Tecaj: ptr(Odds(150))}, false},
+		{"nothing to change", TecajUpdate{ID: 1}, false},
+		{"odds of one", TecajUpdate{ID: 1, Tecaj: ptr(OddsOne)}, false},
+		{"odds below one", TecajUpdate{ID: 1, Tecaj: ptr(Odds(50))}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.update.Validate()
+			if (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
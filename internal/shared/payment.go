@@ -0,0 +1,43 @@
+package shared
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	PaymentCreated   = "created"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+)
+
+// PaymentProvider takes deposits from players. Funds are only credited once the provider
+// confirms the payment through a signed callback.
+type PaymentProvider interface {
+	Name() string
+	// CreateIntent registers the payment with the provider and fills in ProviderRef and CheckoutURL.
+	CreateIntent(intent *PaymentIntent) error
+	// VerifySignature checks that a callback payload was sent by the provider.
+	VerifySignature(payload []byte, header http.Header) error
+	ParseCallback(payload []byte) (*PaymentCallback, error)
+}
+
+type PaymentIntent struct {
+	ID          int       `json:"id"`
+	Provider    string    `json:"provider"`
+	ProviderRef string    `json:"provider_ref"`
+	PlayerID    int       `json:"player_id"`
+	Amount      Money     `json:"amount"`
+	Currency    string    `json:"currency"`
+	Status      string    `json:"status"`
+	CheckoutURL string    `json:"checkout_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PaymentCallback is the outcome of a payment as reported by the provider.
+type PaymentCallback struct {
+	ProviderRef string
+	Status      string
+	Amount      Money
+}
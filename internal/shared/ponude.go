@@ -0,0 +1,74 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A ponuda is open for betting until it starts, is withdrawn or gets a result. Its status is
+// worked out whenever it is read, so rescheduling a ponuda reopens or closes it.
+const (
+	PonudaOpen      = "open"
+	PonudaStarted   = "started"
+	PonudaResulted  = "resulted"
+	PonudaWithdrawn = "withdrawn"
+)
+
+// ErrPonudaClosed is returned when betting on a ponuda that isn't open.
+var ErrPonudaClosed = errors.New("ponuda is closed for betting")
+
+// UpdatePonudaRequest edits a ponuda. A PUT must give the naziv and vrijeme and resets the
+// fields it leaves out, a PATCH only changes the fields it gives.
+type UpdatePonudaRequest struct {
+	Naziv         *string `json:"naziv"`
+	Vrijeme       *string `json:"vrijeme"`
+	TvKanal       *string `json:"tv_kanal"`
+	ImaStatistiku *bool   `json:"ima_statistiku"`
+}
+
+// Apply sets the fields of the request on p. With replace set the naziv and vrijeme are required
+// and the other fields are cleared unless given.
+func (r *UpdatePonudaRequest) Apply(p *Ponude, replace bool) error {
+	if replace {
+		if r.Naziv == nil || r.Vrijeme == nil {
+			return &UserError{Message: "naziv and vrijeme are required"}
+		}
+		p.TvKanal, p.ImaStatistiku = "", false
+	}
+	if r.Naziv != nil {
+		if p.Naziv = strings.TrimSpace(*r.Naziv); p.Naziv == "" {
+			return &UserError{Message: "naziv can't be empty"}
+		}
+	}
+	if r.Vrijeme != nil {
+		vrijeme, err := parseVrijeme(strings.TrimSpace(*r.Vrijeme))
+		if err != nil {
+			return err
+		}
+		p.Vrijeme = vrijeme.Format("2006-01-02T15:04:05")
+	}
+	if r.TvKanal != nil {
+		p.TvKanal = strings.TrimSpace(*r.TvKanal)
+	}
+	if r.ImaStatistiku != nil {
+		p.ImaStatistiku = *r.ImaStatistiku
+	}
+	return nil
+}
+
+// parseVrijeme accepts the start of a ponuda as an RFC 3339 time or as a local time without the
+// zone. Vrijeme is stored as local time and compared with the database's LOCALTIMESTAMP, so a time
+// with an offset is converted to the local zone, which the server shares with the database.
+func parseVrijeme(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(time.Local), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, &UserError{Message: fmt.Sprintf("invalid vrijeme %q, expected a time such as 2006-01-02T15:04:05", s)}
+}
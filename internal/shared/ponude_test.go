@@ -0,0 +1,71 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdatePonudaRequestApply(t *testing.T) {
+	current := Ponude{ID: 1, Naziv: "Dinamo - Hajduk", Vrijeme: "2026-10-20T18:00:00", TvKanal: "HRT2", ImaStatistiku: true}
+	withOffset := time.Date(2026, 10, 21, 18, 30, 0, 0, time.UTC).In(time.Local).Format("2006-01-02T15:04:05")
+
+	tests := []struct {
+		name    string
+		req     UpdatePonudaRequest
+		replace bool
+		want    Ponude
+		ok      bool
+	}{
+		{
+			name: "patch naziv",
+			req:  UpdatePonudaRequest{Naziv: ptr(" Hajduk - Dinamo ")},
+			want: Ponude{ID: 1, Naziv: "Hajduk - Dinamo", Vrijeme: "2026-10-20T18:00:00", TvKanal: "HRT2", ImaStatistiku: true},
+			ok:   true,
+		},
+		{
+			name: "patch local vrijeme",
+			req:  UpdatePonudaRequest{Vrijeme: ptr("2026-10-21 20:30:00")},
+			want: Ponude{ID: 1, Naziv: "Dinamo - Hajduk", Vrijeme: "2026-10-21T20:30:00", TvKanal: "HRT2", ImaStatistiku: true},
+			ok:   true,
+		},
+		{
+			name: "patch vrijeme with an offset",
+			req:  UpdatePonudaRequest{Vrijeme: ptr("2026-10-21T20:30:00+02:00")},
+			want: Ponude{ID: 1, Naziv: "Dinamo - Hajduk", Vrijeme: withOffset, TvKanal: "HRT2", ImaStatistiku: true},
+			ok:   true,
+		},
+		{
+			name:    "put clears what it leaves out",
+			req:     UpdatePonudaRequest{Naziv: ptr("Dinamo - Hajduk"), Vrijeme: ptr("2026-10-20T19:00")},
+			replace: true,
+			want:    Ponude{ID: 1, Naziv: "Dinamo - Hajduk", Vrijeme: "2026-10-20T19:00:00"},
+			ok:      true,
+		},
+		{
+			name:    "put without vrijeme",
+			req:     UpdatePonudaRequest{Naziv: ptr("Dinamo - Hajduk")},
+			replace: true,
+		},
+		{
+			name: "empty naziv",
+			req:  UpdatePonudaRequest{Naziv: ptr("  ")},
+		},
+		{
+			name: "invalid vrijeme",
+			req:  UpdatePonudaRequest{Vrijeme: ptr("20.10.2026 18:00")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := current
+			err := tt.req.Apply(&p, tt.replace)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Apply() = %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("Apply() set %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package shared
+
+import (
+	"fmt"
+	"time"
+)
+
+// MarginRule sets the margin built into the feed odds of a liga and market type on import, 0.05
+// for a 5% margin. A rule without a liga applies to every liga and one without a market to every
+// market. The most specific rule wins: liga and market, then liga, then market, then global.
+type MarginRule struct {
+	LigaID    int       `json:"liga_id,omitempty"`
+	Market    string    `json:"market,omitempty"`
+	Margin    Rate      `json:"margin"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MaxMargin is the highest margin a rule can set.
+const MaxMargin = RateOne / 2
+
+// Validate checks that the rule is for a market the margin can be applied to and that the margin
+// is between 0 and MaxMargin.
+func (r *MarginRule) Validate() error {
+	if r.Market != "" && bookOutcomes(r.Market, nil) == nil {
+		return &UserError{Message: fmt.Sprintf("margins can only be set for the %s, %s and %s markets",
+			MarketMatchResult, MarketHandicap, MarketTotals)}
+	}
+	if r.Margin < 0 || r.Margin > MaxMargin {
+		return &UserError{Message: fmt.Sprintf("margin must be between 0 and %s", MaxMargin)}
+	}
+	return nil
+}
+
+// EffectiveMargin returns the rule that sets the margin of the market in the liga, or nil if no
+// rule applies.
+func EffectiveMargin(rules []*MarginRule, ligaID int, market string) *MarginRule {
+	var best *MarginRule
+	bestRank := -1
+	for _, rule := range rules {
+		if (rule.LigaID != 0 && rule.LigaID != ligaID) || (rule.Market != "" && rule.Market != market) {
+			continue
+		}
+		rank := 0
+		if rule.Market != "" {
+			rank++
+		}
+		if rule.LigaID != 0 {
+			rank += 2
+		}
+		if rank > bestRank {
+			best, bestRank = rule, rank
+		}
+	}
+	return best
+}
+
+// MinOdds are the lowest odds on the ladder.
+const MinOdds Odds = 101
+
+// oddsLadder lists the steps of the standard odds ladder: odds up to max move in steps of step.
+var oddsLadder = []struct{ max, step Odds }{
+	{200, 1}, {300, 2}, {400, 5}, {600, 10}, {1000, 20}, {2000, 50}, {3000, 100}, {5000, 200}, {10000, 500}, {100000, 1000},
+}
+
+// RoundToLadder rounds the odds down to the odds ladder, so rounding never lowers the margin.
+// Odds above the ladder are capped, odds at or below its bottom are left alone.
+func RoundToLadder(o Odds) Odds {
+	if o <= MinOdds {
+		return o
+	}
+	lower := OddsOne
+	for _, band := range oddsLadder {
+		if o <= band.max {
+			return lower + (o-lower)/band.step*band.step
+		}
+		lower = band.max
+	}
+	return lower
+}
+
+// Book is a market of a ponuda whose tipovi cover every outcome exactly once, so the implied
+// probabilities of its odds add up to 100% plus the margin. Overround is that sum.
+type Book struct {
+	Market    string   `json:"market"`
+	Line      *Line    `json:"line,omitempty"`
+	Tipovi    []string `json:"tipovi"`
+	Overround Rate     `json:"overround"`
+	// tecajevi are the indexes of the tecajevi of the book.
+	tecajevi []int
+}
+
+// Arbitrage reports whether the book pays out more than it takes in whatever the outcome.
+func (b *Book) Arbitrage() bool {
+	return b.Overround < RateOne
+}
+
+// bookOutcomes lists the outcomes of a market that make up a book at the line. Handicaps with a
+// whole line can end in a draw. It returns nil for markets that don't make up a book.
+func bookOutcomes(market string, line *Line) []string {
+	switch market {
+	case MarketMatchResult:
+		return []string{OutcomeHome, OutcomeDraw, OutcomeAway}
+	case MarketHandicap:
+		if line != nil && *line%100 != 0 {
+			return []string{OutcomeHome, OutcomeAway}
+		}
+		return []string{OutcomeHome, OutcomeDraw, OutcomeAway}
+	case MarketTotals:
+		return []string{OutcomeOver, OutcomeUnder}
+	}
+	return nil
+}
+
+// impliedProbability is the probability the odds pay out fairly at.
+func impliedProbability(o Odds) Rate {
+	return Rate(mulDivRound(int64(RateOne), int64(OddsOne), int64(o)))
+}
+
+// Books groups the tecajevi into the books they make up and works out the overround of each.
+// Tecajevi of markets that don't make up a book, or of books missing an outcome, are left out.
+func Books(tecajevi []Tecajevi) []*Book {
+	var books []*Book
+	index := make(map[string]*Book)
+	for i, t := range tecajevi {
+		if bookOutcomes(t.Market, t.Line) == nil || t.Tecaj <= OddsOne {
+			continue
+		}
+		key := t.Market
+		if t.Line != nil {
+			key += "|" + t.Line.String()
+		}
+		book, ok := index[key]
+		if !ok {
+			book = &Book{Market: t.Market, Line: t.Line, Tipovi: []string{}}
+			index[key] = book
+			books = append(books, book)
+		}
+		book.Tipovi = append(book.Tipovi, t.Naziv)
+		book.tecajevi = append(book.tecajevi, i)
+	}
+
+	complete := []*Book{}
+	for _, book := range books {
+		outcomes := bookOutcomes(book.Market, book.Line)
+		if len(book.tecajevi) != len(outcomes) {
+			continue
+		}
+		covered := make(map[string]bool, len(outcomes))
+		for _, i := range book.tecajevi {
+			covered[tecajevi[i].Outcome] = true
+		}
+		if len(covered) != len(outcomes) {
+			continue
+		}
+		book.Overround = overround(tecajevi, book.tecajevi)
+		complete = append(complete, book)
+	}
+	return complete
+}
+
+// overround adds up the implied probabilities of the tecajevi at the indexes.
+func overround(tecajevi []Tecajevi, indexes []int) Rate {
+	var sum Rate
+	for _, i := range indexes {
+		sum += impliedProbability(tecajevi[i].Tecaj)
+	}
+	return sum
+}
+
+// PriceTecajevi prices the feed odds of a ponuda in the liga. The odds of every book are
+// rescaled so its overround is 100% plus the margin set by rules, keeping the ratios between
+// them, and never go below MinOdds. Books without a margin rule keep the feed's margin. All odds
+// are then rounded to the odds ladder. Books whose feed or priced odds are an arbitrage are left
+// out and returned as rejected.
+func PriceTecajevi(tecajevi []Tecajevi, rules []*MarginRule, ligaID int) (priced []Tecajevi, rejected []*Book) {
+	priced = make([]Tecajevi, len(tecajevi))
+	copy(priced, tecajevi)
+	skip := make(map[int]bool)
+	for _, book := range Books(tecajevi) {
+		if book.Arbitrage() {
+			rejected = append(rejected, book)
+			for _, i := range book.tecajevi {
+				skip[i] = true
+			}
+			continue
+		}
+		rule := EffectiveMargin(rules, ligaID, book.Market)
+		if rule == nil {
+			continue
+		}
+		for _, i := range book.tecajevi {
+			o := Odds(mulDivRound(int64(tecajevi[i].Tecaj), int64(book.Overround), int64(RateOne+rule.Margin)))
+			priced[i].Tecaj = RoundToLadder(max(o, MinOdds))
+		}
+		// Raising a heavy favourite to MinOdds takes margin out of the book.
+		pricedBook := *book
+		pricedBook.Overround = overround(priced, book.tecajevi)
+		if pricedBook.Arbitrage() {
+			rejected = append(rejected, &pricedBook)
+			for _, i := range book.tecajevi {
+				skip[i] = true
+			}
+		}
+	}
+
+	kept := priced[:0]
+	for i, t := range priced {
+		if skip[i] {
+			continue
+		}
+		t.Tecaj = RoundToLadder(t.Tecaj)
+		kept = append(kept, t)
+	}
+	return kept, rejected
+}
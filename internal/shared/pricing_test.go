@@ -0,0 +1,235 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+func tecaj(naziv string, odds Odds) Tecajevi {
+	return Tecajevi{Naziv: naziv, Tecaj: odds, Tip: ParseTipName(naziv)}
+}
+
+func TestRoundToLadder(t *testing.T) {
+	tests := []struct {
+		in, want Odds
+	}{
+		{95, 95},
+		{100, 100},
+		{101, 101},
+		{157, 157},
+		{200, 200},
+		{201, 200},
+		{203, 202},
+		{300, 300},
+		{304, 300},
+		{305, 305},
+		{399, 395},
+		{599, 590},
+		{999, 980},
+		{1000, 1000},
+		{1049, 1000},
+		{1050, 1050},
+		{2999, 2900},
+		{4999, 4800},
+		{9999, 9500},
+		{99999, 99000},
+		{100000, 100000},
+		{150000, 100000},
+	}
+	for _, tt := range tests {
+		if got := RoundToLadder(tt.in); got != tt.want {
+			t.Errorf("RoundToLadder(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		tecajevi []Tecajevi
+		want     []Book
+	}{
+		{
+			name:     "match result",
+			tecajevi: []Tecajevi{tecaj("1", 200), tecaj("X", 340), tecaj("2", 380)},
+			want:     []Book{{Market: MarketMatchResult, Tipovi: []string{"1", "X", "2"}, Overround: 1057276}},
+		},
+		{
+			name:     "half line handicap has no draw",
+			tecajevi: []Tecajevi{tecaj("1 (-1.5)", 250), tecaj("2 (-1.5)", 150)},
+			want:     []Book{{Market: MarketHandicap, Line: ptr(Line(-150)), Tipovi: []string{"1 (-1.5)", "2 (-1.5)"}, Overround: 1066667}},
+		},
+		{
+			name:     "whole line handicap needs the draw",
+			tecajevi: []Tecajevi{tecaj("1 (-1)", 300), tecaj("X (-1)", 350), tecaj("2 (-1)", 220)},
+			want: []Book{{Market: MarketHandicap, Line: ptr(Line(-100)), Tipovi: []string{"1 (-1)", "X (-1)", "2 (-1)"},
+				Overround: 333333 + 285714 + 454545}},
+		},
+		{
+			name:     "whole line handicap without the draw",
+			tecajevi: []Tecajevi{tecaj("1 (-1)", 300), tecaj("2 (-1)", 220)},
+			want:     []Book{},
+		},
+		{
+			name: "one book per line",
+			tecajevi: []Tecajevi{
+				tecaj("over 2.5", 190), tecaj("under 2.5", 190),
+				tecaj("over 3.5", 300), tecaj("under 3.5", 135),
+			},
+			want: []Book{
+				{Market: MarketTotals, Line: ptr(Line(250)), Tipovi: []string{"over 2.5", "under 2.5"}, Overround: 1052632},
+				{Market: MarketTotals, Line: ptr(Line(350)), Tipovi: []string{"over 3.5", "under 3.5"}, Overround: 333333 + 740741},
+			},
+		},
+		{
+			name:     "markets that aren't books and missing outcomes",
+			tecajevi: []Tecajevi{tecaj("1X", 130), tecaj("F+2", 500), tecaj("1", 200), tecaj("2", 200), tecaj("over 1.5", 120)},
+			want:     []Book{},
+		},
+		{
+			name:     "duplicated outcome",
+			tecajevi: []Tecajevi{tecaj("1", 200), tecaj("1", 210), tecaj("2", 380)},
+			want:     []Book{},
+		},
+		{
+			name:     "odds of 1.00 are no price",
+			tecajevi: []Tecajevi{tecaj("over 2.5", 100), tecaj("under 2.5", 190)},
+			want:     []Book{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []Book{}
+			for _, book := range Books(tt.tecajevi) {
+				b := *book
+				b.tecajevi = nil
+				got = append(got, b)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Books = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookArbitrage(t *testing.T) {
+	books := Books([]Tecajevi{tecaj("over 2.5", 210), tecaj("under 2.5", 210), tecaj("1", 200), tecaj("X", 400), tecaj("2", 400)})
+	if len(books) != 2 {
+		t.Fatalf("got %d books, want 2", len(books))
+	}
+	if !books[0].Arbitrage() {
+		t.Errorf("totals at 2.10 and 2.10 with an overround of %s aren't an arbitrage", books[0].Overround)
+	}
+	if books[1].Arbitrage() {
+		t.Errorf("match result at exactly 100%% is an arbitrage")
+	}
+}
+
+func TestEffectiveMargin(t *testing.T) {
+	global := &MarginRule{Margin: 50000}
+	market := &MarginRule{Market: MarketTotals, Margin: 60000}
+	liga := &MarginRule{LigaID: 10, Margin: 70000}
+	ligaMarket := &MarginRule{LigaID: 10, Market: MarketTotals, Margin: 80000}
+	otherLiga := &MarginRule{LigaID: 11, Market: MarketTotals, Margin: 90000}
+	all := []*MarginRule{ligaMarket, otherLiga, liga, market, global}
+
+	tests := []struct {
+		name   string
+		rules  []*MarginRule
+		ligaID int
+		market string
+		want   *MarginRule
+	}{
+		{"liga and market first", all, 10, MarketTotals, ligaMarket},
+		{"then liga", all, 10, MarketMatchResult, liga},
+		{"then market", all, 12, MarketTotals, market},
+		{"then global", all, 12, MarketHandicap, global},
+		{"liga wins over market", []*MarginRule{market, liga}, 10, MarketTotals, liga},
+		{"rules of other lige don't apply", []*MarginRule{otherLiga}, 10, MarketTotals, nil},
+		{"no rules", nil, 10, MarketTotals, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EffectiveMargin(tt.rules, tt.ligaID, tt.market); got != tt.want {
+				t.Errorf("EffectiveMargin = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceTecajevi(t *testing.T) {
+	fivePercent := []*MarginRule{{Margin: 50000}}
+	tenPercent := []*MarginRule{{Margin: 100000}}
+
+	tests := []struct {
+		name         string
+		tecajevi     []Tecajevi
+		rules        []*MarginRule
+		want         []Odds
+		wantRejected []string
+	}{
+		{
+			name:     "without a rule the odds are only rounded",
+			tecajevi: []Tecajevi{tecaj("1", 153), tecaj("X", 345), tecaj("2", 1049)},
+			want:     []Odds{153, 345, 1000},
+		},
+		{
+			name:     "rescaled to the margin",
+			tecajevi: []Tecajevi{tecaj("over 2.5", 200), tecaj("under 2.5", 200)},
+			rules:    fivePercent,
+			want:     []Odds{190, 190},
+		},
+		{
+			name:     "ratios are kept",
+			tecajevi: []Tecajevi{tecaj("1", 200), tecaj("X", 400), tecaj("2", 400)},
+			rules:    fivePercent,
+			want:     []Odds{190, 380, 380},
+		},
+		{
+			name:     "heavy favourite is kept at the minimum odds",
+			tecajevi: []Tecajevi{tecaj("over 0.5", 105), tecaj("under 0.5", 1200)},
+			rules:    tenPercent,
+			want:     []Odds{MinOdds, 1100},
+		},
+		{
+			name:         "arbitrage books are rejected",
+			tecajevi:     []Tecajevi{tecaj("over 2.5", 210), tecaj("under 2.5", 210), tecaj("1", 200), tecaj("X", 400), tecaj("2", 400)},
+			rules:        fivePercent,
+			want:         []Odds{190, 380, 380},
+			wantRejected: []string{"over 2.5", "under 2.5"},
+		},
+		{
+			name:     "tecajevi outside books are only rounded",
+			tecajevi: []Tecajevi{tecaj("1X", 133), tecaj("F+2", 1234), tecaj("1", 95)},
+			rules:    fivePercent,
+			want:     []Odds{133, 1200, 95},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priced, rejected := PriceTecajevi(tt.tecajevi, tt.rules, 10)
+			got := []Odds{}
+			for _, p := range priced {
+				got = append(got, p.Tecaj)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("priced odds = %v, want %v", got, tt.want)
+			}
+			var gotRejected []string
+			for _, book := range rejected {
+				gotRejected = append(gotRejected, book.Tipovi...)
+			}
+			if !reflect.DeepEqual(gotRejected, tt.wantRejected) {
+				t.Errorf("rejected = %v, want %v", gotRejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestPriceTecajeviKeepsFeedOdds(t *testing.T) {
+	feed := []Tecajevi{tecaj("1", 200), tecaj("X", 400), tecaj("2", 400)}
+	PriceTecajevi(feed, []*MarginRule{{Margin: 50000}}, 10)
+	if feed[0].Tecaj != 200 || feed[1].Tecaj != 400 || feed[2].Tecaj != 400 {
+		t.Errorf("PriceTecajevi changed the feed odds to %v", feed)
+	}
+}
@@ -0,0 +1,181 @@
+package shared
+
+import "fmt"
+
+// Codes of the selection errors returned for a slip that can't be placed.
+const (
+	SlipErrDuplicateSelection = "duplicate_selection"
+	SlipErrSameEvent          = "same_event"
+	SlipErrExclusiveTips      = "exclusive_tips"
+	SlipErrLeagueCombination  = "league_combination_banned"
+	SlipErrSuspended          = "suspended"
+	SlipErrClosed             = "closed"
+)
+
+// ExclusiveTips are two tipovi that can't both win, so they can't be combined on one event.
+type ExclusiveTips struct {
+	TipA string `json:"tip_a"`
+	TipB string `json:"tip_b"`
+}
+
+// LeagueCombinationBan forbids combining selections from two leagues on one slip. A ban of a
+// league with itself forbids combining two of its events.
+type LeagueCombinationBan struct {
+	LigaA int `json:"liga_a"`
+	LigaB int `json:"liga_b"`
+}
+
+// SlipRules are the correlation rules a slip is checked against. Unless AllowSameEvent is set,
+// an event can be played only once per slip. Besides ExclusiveTips, the outcomes of a totals or
+// handicap market on the same line, and different correct scores, are always exclusive.
+type SlipRules struct {
+	AllowSameEvent bool                   `json:"allow_same_event"`
+	ExclusiveTips  []ExclusiveTips        `json:"exclusive_tips"`
+	LeagueBans     []LeagueCombinationBan `json:"league_bans"`
+}
+
+// SelectionError marks a selection of the slip, by index, that conflicts with the Related ones.
+type SelectionError struct {
+	Selection int    `json:"selection"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Related   []int  `json:"related,omitempty"`
+}
+
+// SlipError rejects a slip whose selections conflict with each other, are suspended or are on
+// ponude closed for betting.
+type SlipError struct {
+	Message    string
+	Selections []SelectionError
+}
+
+func (e *SlipError) Error() string {
+	return fmt.Sprintf("%s: %d conflicting selection(s)", e.Message, len(e.Selections))
+}
+
+func (r *SlipRules) tipsExclusive(a, b string) bool {
+	for _, t := range r.ExclusiveTips {
+		if (t.TipA == a && t.TipB == b) || (t.TipA == b && t.TipB == a) {
+			return true
+		}
+	}
+	return marketOutcomesExclusive(ParseTipName(a), ParseTipName(b))
+}
+
+// marketOutcomesExclusive reports whether two different tipovi of a parameterized market can't
+// both win. Lines vary per ponuda, so these pairs can't be listed in ExclusiveTips.
+func marketOutcomesExclusive(a, b Tip) bool {
+	if a.Market != b.Market || a.Name() == b.Name() {
+		return false
+	}
+	switch a.Market {
+	case MarketTotals, MarketHandicap:
+		return *a.Line == *b.Line
+	case MarketCorrectScore:
+		return true
+	}
+	return false
+}
+
+func (r *SlipRules) leaguesBanned(a, b int) bool {
+	for _, ban := range r.LeagueBans {
+		if (ban.LigaA == a && ban.LigaB == b) || (ban.LigaA == b && ban.LigaB == a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSlip checks every pair of selections against the rules and returns an error for each
+// selection involved in a conflict, for each suspended selection and for each selection on a
+// ponuda closed for betting.
+func ValidateSlip(rules *SlipRules, selections []*BetSelection) []SelectionError {
+	// conflicts maps a selection to the selections it conflicts with, by error code.
+	conflicts := make(map[int]map[string][]int)
+	add := func(i, j int, code string) {
+		if conflicts[i] == nil {
+			conflicts[i] = make(map[string][]int)
+		}
+		conflicts[i][code] = append(conflicts[i][code], j)
+	}
+
+	for i, a := range selections {
+		for j := i + 1; j < len(selections); j++ {
+			b := selections[j]
+			var code string
+			switch {
+			case a.Ponuda == b.Ponuda && a.NazivTipa == b.NazivTipa:
+				code = SlipErrDuplicateSelection
+			case a.Ponuda == b.Ponuda && rules.tipsExclusive(a.NazivTipa, b.NazivTipa):
+				code = SlipErrExclusiveTips
+			case a.Ponuda == b.Ponuda && !rules.AllowSameEvent:
+				code = SlipErrSameEvent
+			case a.Ponuda != b.Ponuda && rules.leaguesBanned(a.LigaID, b.LigaID):
+				code = SlipErrLeagueCombination
+			default:
+				continue
+			}
+			add(i, j, code)
+			add(j, i, code)
+		}
+	}
+
+	errs := []SelectionError{}
+	for i, sel := range selections {
+		if sel.Closed {
+			errs = append(errs, SelectionError{Selection: i, Code: SlipErrClosed, Message: slipErrorMessage(SlipErrClosed, sel)})
+		}
+		if sel.Suspended {
+			errs = append(errs, SelectionError{Selection: i, Code: SlipErrSuspended, Message: slipErrorMessage(SlipErrSuspended, sel)})
+		}
+		for _, code := range []string{SlipErrDuplicateSelection, SlipErrExclusiveTips, SlipErrSameEvent, SlipErrLeagueCombination} {
+			related, ok := conflicts[i][code]
+			if !ok {
+				continue
+			}
+			errs = append(errs, SelectionError{
+				Selection: i,
+				Code:      code,
+				Message:   slipErrorMessage(code, sel),
+				Related:   related,
+			})
+		}
+	}
+	return errs
+}
+
+func slipErrorMessage(code string, sel *BetSelection) string {
+	switch code {
+	case SlipErrDuplicateSelection:
+		return fmt.Sprintf("tip %s on ponuda %d is on the slip more than once", sel.NazivTipa, sel.Ponuda)
+	case SlipErrExclusiveTips:
+		return fmt.Sprintf("tip %s on ponuda %d can't be combined with the other tipovi on the same ponuda", sel.NazivTipa, sel.Ponuda)
+	case SlipErrSameEvent:
+		return fmt.Sprintf("ponuda %d can only be played once per slip", sel.Ponuda)
+	case SlipErrLeagueCombination:
+		return fmt.Sprintf("ponuda %d is from a league that can't be combined with the other selections", sel.Ponuda)
+	case SlipErrSuspended:
+		return fmt.Sprintf("tip %s on ponuda %d is suspended", sel.NazivTipa, sel.Ponuda)
+	case SlipErrClosed:
+		return fmt.Sprintf("ponuda %d is closed for betting", sel.Ponuda)
+	}
+	return code
+}
+
+type QuoteRequest struct {
+	CreateUplataRequest
+	PlayerID int `json:"player_id,omitempty"`
+}
+
+// SlipQuote prices a slip at the current odds. Amounts are in Currency. The slip can be placed
+// as quoted if it has no selection errors and no limit violations. Referred slips are held for
+// review when placed.
+type SlipQuote struct {
+	Currency   string          `json:"currency"`
+	Selections []*BetSelection `json:"selections"`
+	FiscalBreakdown
+	SelectionErrors []SelectionError `json:"selection_errors"`
+	Violations      []LimitViolation `json:"violations"`
+	Placeable       bool             `json:"placeable"`
+	Referred        bool             `json:"referred,omitempty"`
+}
@@ -0,0 +1,155 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+func slipSelection(ponuda, ligaID int, naziv string) *BetSelection {
+	return &BetSelection{Ponuda: ponuda, LigaID: ligaID, NazivTipa: naziv}
+}
+
+var testSlipRules = SlipRules{
+	ExclusiveTips: []ExclusiveTips{{"1", "X"}, {"1", "2"}, {"2", "X"}, {"1", "X2"}, {"1X", "2"}, {"12", "X"}},
+	LeagueBans:    []LeagueCombinationBan{{LigaA: 10, LigaB: 20}, {LigaA: 30, LigaB: 30}},
+}
+
+func TestValidateSlip(t *testing.T) {
+	sameEvent := testSlipRules
+	sameEvent.AllowSameEvent = true
+
+	suspended := slipSelection(2, 10, "2")
+	suspended.Suspended = true
+	closed := slipSelection(3, 10, "X")
+	closed.Closed = true
+
+	tests := []struct {
+		name       string
+		rules      SlipRules
+		selections []*BetSelection
+		want       []SelectionError
+	}{
+		{
+			name:       "independent selections",
+			rules:      testSlipRules,
+			selections: []*BetSelection{slipSelection(1, 10, "1"), slipSelection(2, 10, "2"), slipSelection(3, 40, "over 2.5")},
+			want:       []SelectionError{},
+		},
+		{
+			name:       "duplicate selection",
+			rules:      sameEvent,
+			selections: []*BetSelection{slipSelection(1, 10, "1"), slipSelection(1, 10, "1")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrDuplicateSelection, Related: []int{1}},
+				{Selection: 1, Code: SlipErrDuplicateSelection, Related: []int{0}},
+			},
+		},
+		{
+			name:       "same event not allowed",
+			rules:      testSlipRules,
+			selections: []*BetSelection{slipSelection(1, 10, "1"), slipSelection(1, 10, "over 2.5")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrSameEvent, Related: []int{1}},
+				{Selection: 1, Code: SlipErrSameEvent, Related: []int{0}},
+			},
+		},
+		{
+			name:       "same event allowed",
+			rules:      sameEvent,
+			selections: []*BetSelection{slipSelection(1, 10, "1"), slipSelection(1, 10, "over 2.5")},
+			want:       []SelectionError{},
+		},
+		{
+			name:       "exclusive tipovi",
+			rules:      sameEvent,
+			selections: []*BetSelection{slipSelection(1, 10, "1"), slipSelection(1, 10, "X2")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrExclusiveTips, Related: []int{1}},
+				{Selection: 1, Code: SlipErrExclusiveTips, Related: []int{0}},
+			},
+		},
+		{
+			name:       "exclusive tipovi are reported even when the event can't repeat",
+			rules:      testSlipRules,
+			selections: []*BetSelection{slipSelection(1, 10, "12"), slipSelection(1, 10, "X")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrExclusiveTips, Related: []int{1}},
+				{Selection: 1, Code: SlipErrExclusiveTips, Related: []int{0}},
+			},
+		},
+		{
+			name:       "totals on the same line",
+			rules:      sameEvent,
+			selections: []*BetSelection{slipSelection(1, 10, "over 2.5"), slipSelection(1, 10, "under 2.5")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrExclusiveTips, Related: []int{1}},
+				{Selection: 1, Code: SlipErrExclusiveTips, Related: []int{0}},
+			},
+		},
+		{
+			name:       "totals on different lines",
+			rules:      sameEvent,
+			selections: []*BetSelection{slipSelection(1, 10, "over 3.5"), slipSelection(1, 10, "under 2.5")},
+			want:       []SelectionError{},
+		},
+		{
+			name:       "handicap on the same line",
+			rules:      sameEvent,
+			selections: []*BetSelection{slipSelection(1, 10, "1 (-1.5)"), slipSelection(1, 10, "2 (-1.5)")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrExclusiveTips, Related: []int{1}},
+				{Selection: 1, Code: SlipErrExclusiveTips, Related: []int{0}},
+			},
+		},
+		{
+			name:       "correct scores",
+			rules:      sameEvent,
+			selections: []*BetSelection{slipSelection(1, 10, "2:1"), slipSelection(1, 10, "1:1"), slipSelection(1, 10, "over 2.5")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrExclusiveTips, Related: []int{1}},
+				{Selection: 1, Code: SlipErrExclusiveTips, Related: []int{0}},
+			},
+		},
+		{
+			name:       "banned league combination",
+			rules:      testSlipRules,
+			selections: []*BetSelection{slipSelection(1, 20, "1"), slipSelection(2, 40, "1"), slipSelection(3, 10, "2")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrLeagueCombination, Related: []int{2}},
+				{Selection: 2, Code: SlipErrLeagueCombination, Related: []int{0}},
+			},
+		},
+		{
+			name:       "league banned with itself",
+			rules:      testSlipRules,
+			selections: []*BetSelection{slipSelection(1, 30, "1"), slipSelection(2, 30, "1")},
+			want: []SelectionError{
+				{Selection: 0, Code: SlipErrLeagueCombination, Related: []int{1}},
+				{Selection: 1, Code: SlipErrLeagueCombination, Related: []int{0}},
+			},
+		},
+		{
+			name:       "suspended and closed",
+			rules:      testSlipRules,
+			selections: []*BetSelection{slipSelection(1, 10, "1"), suspended, closed},
+			want: []SelectionError{
+				{Selection: 1, Code: SlipErrSuspended},
+				{Selection: 2, Code: SlipErrClosed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateSlip(&tt.rules, tt.selections)
+			for i := range got {
+				if got[i].Message == "" {
+					t.Errorf("selection error %d has no message", i)
+				}
+				got[i].Message = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateSlip() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+func movement(id int, txType string, amount Money) *StatementLine {
+	return &StatementLine{TransactionID: id, Type: txType, Amount: amount, Currency: "EUR"}
+}
+
+type statementRow struct {
+	ID      int
+	Balance Money
+}
+
+func TestBuildStatement(t *testing.T) {
+	tests := []struct {
+		name      string
+		opening   Money
+		movements []*StatementLine
+		filter    StatementFilter
+		total     int
+		want      []statementRow
+	}{
+		{
+			name:      "running balance newest first",
+			movements: []*StatementLine{movement(1, TxDeposit, 10000), movement(2, TxStake, -2000), movement(3, TxWin, 5000)},
+			total:     3,
+			want:      []statementRow{{3, 13000}, {2, 8000}, {1, 10000}},
+		},
+		{
+			name:      "starts from the opening balance",
+			opening:   2500,
+			movements: []*StatementLine{movement(1, TxStake, -500), movement(2, TxRefund, 500)},
+			total:     2,
+			want:      []statementRow{{2, 2500}, {1, 2000}},
+		},
+		{
+			name:      "filtered types still count towards the balance",
+			movements: []*StatementLine{movement(1, TxDeposit, 10000), movement(2, TxStake, -2000), movement(3, TxStake, -1000), movement(4, TxWin, 5000)},
+			filter:    StatementFilter{Types: []string{TxDeposit, TxWin}},
+			total:     2,
+			want:      []statementRow{{4, 12000}, {1, 10000}},
+		},
+		{
+			name:      "page",
+			movements: []*StatementLine{movement(1, TxDeposit, 100), movement(2, TxDeposit, 200), movement(3, TxDeposit, 300), movement(4, TxDeposit, 400)},
+			filter:    StatementFilter{Limit: 2, Offset: 1},
+			total:     4,
+			want:      []statementRow{{3, 600}, {2, 300}},
+		},
+		{
+			name:      "last page is short",
+			movements: []*StatementLine{movement(1, TxDeposit, 100), movement(2, TxDeposit, 200), movement(3, TxDeposit, 300)},
+			filter:    StatementFilter{Limit: 2, Offset: 2},
+			total:     3,
+			want:      []statementRow{{1, 100}},
+		},
+		{
+			name:      "offset past the end",
+			movements: []*StatementLine{movement(1, TxDeposit, 100)},
+			filter:    StatementFilter{Limit: 2, Offset: 5},
+			total:     1,
+			want:      []statementRow{},
+		},
+		{
+			name:      "no movements",
+			opening:   700,
+			movements: []*StatementLine{},
+			total:     0,
+			want:      []statementRow{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement := BuildStatement(7, tt.opening, tt.movements, tt.filter)
+			if statement.PlayerID != 7 || statement.Total != tt.total || statement.Limit != tt.filter.Limit || statement.Offset != tt.filter.Offset {
+				t.Errorf("statement header = player %d, total %d, limit %d, offset %d, want 7, %d, %d, %d",
+					statement.PlayerID, statement.Total, statement.Limit, statement.Offset, tt.total, tt.filter.Limit, tt.filter.Offset)
+			}
+			got := []statementRow{}
+			for _, line := range statement.Lines {
+				got = append(got, statementRow{line.TransactionID, line.Balance})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package shared
+
+import (
+	"errors"
+	"time"
+)
+
+// A referred ticket is held for review because it took an outcome over its exposure threshold.
+// It is charged when placed and opens once approved. A rejected ticket is void.
+const (
+	TicketOpen     = "open"
+	TicketReferred = "referred"
+	TicketWon      = "won"
+	TicketLost     = "lost"
+	TicketVoid     = "void"
+)
+
+const (
+	BetOpen = "open"
+	BetWon  = "won"
+	BetLost = "lost"
+	BetVoid = "void"
+)
+
+// A ponuda is finished when it was played out. Bets on cancelled or abandoned ponude are void.
+const (
+	ResultFinished  = "finished"
+	ResultCancelled = "cancelled"
+	ResultAbandoned = "abandoned"
+)
+
+func IsResultStatus(status string) bool {
+	switch status {
+	case ResultFinished, ResultCancelled, ResultAbandoned:
+		return true
+	}
+	return false
+}
+
+var ErrResultExists = errors.New("result already recorded")
+
+// FiscalBreakdown splits a payment into the handling fee and the stake, and the potential
+// payout of the stake into the withheld tax and the net payout.
+type FiscalBreakdown struct {
+	Payment         Money `json:"payment"`
+	Fee             Money `json:"fee"`
+	Stake           Money `json:"stake"`
+	TotalOdds       Odds  `json:"total_odds"`
+	PotentialPayout Money `json:"potential_payout"`
+	Tax             Money `json:"tax"`
+	NetPayout       Money `json:"net_payout"`
+}
+
+type TicketBet struct {
+	ID        int    `json:"id"`
+	Ponuda    int    `json:"ponuda"`
+	NazivTipa string `json:"naziv"`
+	Tip
+	Tecaj  Odds   `json:"tecaj"`
+	Status string `json:"status"`
+}
+
+// Settlement is what a ticket actually paid out once all its bets were resolved. A void ticket
+// pays nothing out but refunds the whole payment, handling fee included.
+type Settlement struct {
+	Odds      Odds      `json:"odds"`
+	Payout    Money     `json:"payout"`
+	Tax       Money     `json:"tax"`
+	NetPayout Money     `json:"net_payout"`
+	Refund    Money     `json:"refund,omitempty"`
+	SettledAt time.Time `json:"settled_at"`
+}
+
+// Uplata is a placed ticket. The fiscal breakdown is the one the player was quoted.
+type Uplata struct {
+	ID       int    `json:"id"`
+	PlayerID int    `json:"player_id"`
+	Currency string `json:"currency"`
+	FiscalBreakdown
+	Status     string       `json:"status"`
+	Bets       []*TicketBet `json:"bets"`
+	Settlement *Settlement  `json:"settlement,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// Outcome returns the status the ticket settles to given the results of its bets, and the odds
+// it pays at. Void bets count at odds 1.00 and a ticket with only void bets is void. It returns
+// TicketOpen while any bet is unresolved.
+func (u *Uplata) Outcome() (string, Odds) {
+	status, odds := TicketVoid, OddsOne
+	for _, bet := range u.Bets {
+		switch bet.Status {
+		case BetOpen:
+			return TicketOpen, 0
+		case BetLost:
+			status = TicketLost
+		case BetWon:
+			if status == TicketVoid {
+				status = TicketWon
+			}
+			odds = odds.Mul(bet.Tecaj)
+		}
+	}
+	if status == TicketLost {
+		return TicketLost, 0
+	}
+	return status, odds
+}
+
+// PonudaResult is the result of a finished ponuda: its score, from which bets on known markets
+// are settled, and the tipovi that won. Without a score only the listed tipovi win. Cancelled
+// and abandoned ponude have neither.
+type PonudaResult struct {
+	PonudaID      int       `json:"ponuda_id"`
+	Status        string    `json:"status"`
+	Score         *Score    `json:"score,omitempty"`
+	WinningTipovi []string  `json:"winning_tipovi"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ResultRequest records a result. Status defaults to ResultFinished.
+type ResultRequest struct {
+	Status        string   `json:"status,omitempty"`
+	Score         *Score   `json:"score,omitempty"`
+	WinningTipovi []string `json:"winning_tipovi"`
+}
+
+// SettleBet resolves a bet on the ponuda: from the score if its market can be settled from one,
+// otherwise by whether its tip is among the winning tipovi.
+func (r *PonudaResult) SettleBet(naziv string, tip Tip) string {
+	if r.Status != ResultFinished {
+		return BetVoid
+	}
+	if r.Score != nil {
+		if status, ok := tip.Settle(*r.Score); ok {
+			return status
+		}
+	}
+	for _, winning := range r.WinningTipovi {
+		if winning == naziv {
+			return BetWon
+		}
+	}
+	return BetLost
+}
+
+// SettlementSummary reports the tickets settled after a result was recorded.
+type SettlementSummary struct {
+	PonudaID int       `json:"ponuda_id"`
+	Settled  []*Uplata `json:"settled"`
+}
+
+// FiscalReportLine totals the handling fees and withheld tax in one currency.
+type FiscalReportLine struct {
+	Currency string `json:"currency"`
+	Tickets  int    `json:"tickets"`
+	Fees     Money  `json:"fees"`
+	Tax      Money  `json:"tax"`
+}
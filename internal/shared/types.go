@@ -1,25 +1,106 @@
 package shared
 
+import "time"
+
 type Storage interface {
 	CreatePonuda(*Ponude) error
-	CreateTecaj(ponudaID int, tecaj float64, naziv string) error
+	CreateTecaj(ponudaID int, tecaj Tecajevi, source string) error
+	GetTecajHistory(ponudaID int, filter TecajHistoryFilter) ([]*TecajChange, error)
+	GetTecajChanges(afterID int, limit int) ([]*TecajChange, error)
+	LastTecajChangeID() (int, error)
+	UpdateTecajevi(updates []TecajUpdate, source, reason string) ([]*TecajChange, error)
+	SuspendPonuda(ponudaID int, req *SuspendRequest, suspended bool) ([]*TecajChange, error)
 	GetPonuda(id int) (*Ponude, error)
 	GetAllPonude() ([]*Ponude, error)
+	UpdatePonuda(ponuda *Ponude) error
+	WithdrawPonuda(id int) ([]int, error)
+	DeletePonuda(id int) error
+	GetMarginRules() ([]*MarginRule, error)
+	SetMarginRule(rule *MarginRule) error
+	DeleteMarginRule(ligaID int, market string) error
+	GetLiabilities(ponudaIDs []int) (*LiabilityReport, error)
+	GetExposureThresholds() ([]*ExposureThreshold, error)
+	SetExposureThreshold(t *ExposureThreshold) error
+	DeleteExposureThreshold(scope string, scopeID int) error
+	GetReferredUplate() ([]*Uplata, error)
+	ApproveUplata(id int) error
 	CreateLiga(naziv string) (int, error)
 	CreateRazrada(ligaID int, ponude []int) (int, error)
-	CreateTipovi(razradaID int, naziv string) error
+	CreateTipovi(razradaID int, position int, tip Tipovi) (int, error)
 	GetLige() ([]*Lige, error)
+	GetLiga(id int) (*Lige, error)
+	UpdateLiga(id int, naziv string) error
+	DeleteLiga(id int) error
+	GetRazrada(id int) (*Razrade, error)
+	UpdateRazrada(id int, ligaID int, ponude []int) error
+	DeleteRazrada(id int) error
+	UpdateTip(id int, tip Tipovi) error
+	ReorderLige(ids []int) error
+	ReorderTipovi(razradaID int, ids []int) error
+	DeleteTip(id int) error
+	GetRazradaMismatches() ([]RazradaMismatch, error)
 	CreatePlayer(*Player) error
 	GetPlayers() ([]*Player, error)
 	GetPlayerByID(id int) (*Player, error)
 	GetLogin(username string) (*Player, error)
-	ResetPassword(username string, newPassword string) error
+	ResetPassword(username string, passwordHash string) error
 	DeleteUser(id int) error
-	Deposit(id int, amount float64) error
-	CreateUplata(playerID int, amount float64, odigraniPar []OdigraniPar) error
-	GetAccountBalance(id int) (float64, error)
+	CreateUplata(u *Uplata) error
+	GetAccountBalance(id int) (Money, error)
 	GetPonudaByID(id int) (*Ponude, error)
 	GetTecaj(parovi []OdigraniPar) ([]*Tecajevi, error)
+	GetLoginThrottle(scope, key string) (*LoginThrottle, error)
+	RecordLoginFailure(scope, key string, at, forgetBefore time.Time) (*LoginThrottle, error)
+	LockLogin(scope, key string, until time.Time) error
+	ResetLoginFailures(scope, key string) error
+	UnlockPlayerLogins(playerID int, username string, ips []string) error
+	GetTOTP(playerID int) (*PlayerTOTP, error)
+	SaveTOTPSecret(playerID int, secret string) error
+	EnableTOTP(playerID int, step int64, recoveryCodeHashes []string) error
+	DisableTOTP(playerID int) error
+	UseTOTPStep(playerID int, step int64) (bool, error)
+	UseRecoveryCode(playerID int, codeHash string) (bool, error)
+	CreateSession(session *Session, tokenHash string) error
+	GetSessions(playerID int, idleSince, createdSince time.Time) ([]*Session, error)
+	TouchSession(tokenHash string, idleSince, createdSince time.Time) (*Session, error)
+	RevokeSession(playerID int, sessionID int) error
+	RevokeSessions(playerID int) error
+	UpdatePlayerProfile(player *Player, kycFrom string) error
+	SetKYCStatus(playerID int, from, to, reason string) error
+	PostLedgerTransaction(t *LedgerTransaction) error
+	ReconcileBalances() ([]*BalanceMismatch, error)
+	GetStatement(playerID int, filter StatementFilter) (*Statement, error)
+	CreateWithdrawal(w *Withdrawal) error
+	GetWithdrawal(id int) (*Withdrawal, error)
+	GetWithdrawals(playerID int, status string) ([]*Withdrawal, error)
+	SetWithdrawalStatus(id int, from, to, reason string) (*Withdrawal, error)
+	CreatePaymentIntent(intent *PaymentIntent) error
+	GetPaymentIntent(provider, providerRef string) (*PaymentIntent, error)
+	CompletePaymentIntent(provider string, callback *PaymentCallback) (*PaymentIntent, bool, error)
+	GetExchangeRates() ([]*ExchangeRate, error)
+	GetExchangeRate(currency string) (*ExchangeRate, error)
+	SetExchangeRate(currency string, rate Rate) (*ExchangeRate, error)
+	GetLedgerReport(from, to *time.Time) (*LedgerReport, error)
+	BeginIdempotentRequest(scope, key, fingerprint string, expireBefore, deadBefore time.Time) (*IdempotencyRecord, bool, error)
+	HeartbeatIdempotentRequest(scope, key string) error
+	CompleteIdempotentRequest(scope, key string, status int, contentType string, body []byte) error
+	ReleaseIdempotentRequest(scope, key string) error
+	GetBetSelections(parovi []OdigraniPar) ([]*BetSelection, error)
+	GetAllBetLimitRules() ([]*BetLimitRule, error)
+	GetBetLimitRules(playerID int, ligaIDs, ponudaIDs []int) ([]*BetLimitRule, error)
+	SetBetLimitRule(rule *BetLimitRule) error
+	DeleteBetLimitRule(scope string, scopeID int) error
+	GetSlipRules() (*SlipRules, error)
+	AddExclusiveTips(tips ExclusiveTips) error
+	DeleteExclusiveTips(tips ExclusiveTips) error
+	AddLeagueCombinationBan(ban LeagueCombinationBan) error
+	DeleteLeagueCombinationBan(ban LeagueCombinationBan) error
+	GetUplata(id int) (*Uplata, error)
+	GetPlayerUplate(playerID int) ([]*Uplata, error)
+	SetPonudaResult(result *PonudaResult) ([]int, error)
+	GetUnsettledUplate(ponudaID int) ([]int, error)
+	SettleUplata(id int, status string, settlement *Settlement) (bool, error)
+	GetFiscalReport(from, to *time.Time) ([]*FiscalReportLine, error)
 }
 
 type UserError struct {
@@ -38,17 +119,69 @@ func (e *InternalError) Error() string {
 	return e.Message
 }
 
+type RateLimitError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
+
+type ConflictError struct {
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// UnauthorizedError is returned when a request needs a session and has none.
+type UnauthorizedError struct {
+	Message string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Message
+}
+
+// UnavailableError is returned when the server isn't set up to serve the request.
+type UnavailableError struct {
+	Message string
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Message
+}
+
+// ForbiddenError is returned when a session may not act on the resource it asked for.
+type ForbiddenError struct {
+	Message string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
 type Lige struct {
+	ID      int       `json:"id,omitempty"`
 	Naziv   string    `json:"naziv"`
 	Razrade []Razrade `json:"razrade"`
 }
 
+// Razrade defines the tipovi offered on its ponude. Markets groups the tipovi into the columns
+// of the odds grid.
 type Razrade struct {
-	Tipovi []Tipovi `json:"tipovi"`
-	Ponude []int    `json:"ponude"`
+	ID      int            `json:"id,omitempty"`
+	LigaID  int            `json:"liga_id,omitempty"`
+	Tipovi  []Tipovi       `json:"tipovi"`
+	Ponude  []int          `json:"ponude"`
+	Markets []MarketColumn `json:"markets,omitempty"`
 }
 type Tipovi struct {
+	ID    int    `json:"id,omitempty"`
 	Naziv string `json:"naziv"`
+	Tip
 }
 
 type JsonData struct {
@@ -63,22 +196,121 @@ type Ponude struct {
 	Tecajevi      []Tecajevi `json:"tecajevi"`
 	TvKanal       string     `json:"tv_kanal,omitempty"`
 	ImaStatistiku bool       `json:"ima_statistiku,omitempty"`
+	Status        string     `json:"status,omitempty"`
 }
 
 type Tecajevi struct {
-	Tecaj float64 `json:"tecaj"`
-	Naziv string  `json:"naziv"`
+	ID    int    `json:"id,omitempty"`
+	Tecaj Odds   `json:"tecaj"`
+	Naziv string `json:"naziv"`
+	Tip
+	Suspended bool `json:"suspended,omitempty"`
 }
 type Player struct {
-	ID             int     `json:"id"`
-	Username       string  `json:"username"`
-	Password       string  `json:"password"`
-	AccountBalance float64 `json:"account_balance"`
+	ID             int    `json:"id"`
+	Username       string `json:"username"`
+	Password       string `json:"-"`
+	AccountBalance Money  `json:"account_balance"`
+	Currency       string `json:"currency"`
+	Email          string `json:"email,omitempty"`
+	FullName       string `json:"full_name,omitempty"`
+	DateOfBirth    string `json:"date_of_birth,omitempty"`
+	Address        string `json:"address,omitempty"`
+	NationalID     string `json:"national_id,omitempty"`
+	KYCStatus      string `json:"kyc_status"`
+	KYCReason      string `json:"kyc_reason,omitempty"`
+}
+
+// UpdateProfileRequest changes only the fields that are present.
+type UpdateProfileRequest struct {
+	Email       *string `json:"email"`
+	FullName    *string `json:"full_name"`
+	DateOfBirth *string `json:"date_of_birth"`
+	Address     *string `json:"address"`
+	NationalID  *string `json:"national_id"`
+}
+
+type KYCStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+const (
+	LoginScopeUsername = "username"
+	LoginScopeIP       = "ip"
+)
+
+// LoginThrottle tracks failed login attempts for a single username or client IP.
+type LoginThrottle struct {
+	Scope       string     `json:"scope"`
+	Key         string     `json:"key"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+type LoginRequest struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	TOTPCode     string `json:"totp_code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// PlayerTOTP is a player's two-factor secret. LastStep is the last time step that was
+// accepted, so a code can't be used twice.
+type PlayerTOTP struct {
+	PlayerID int
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TOTPCodeRequest carries the current password with the code, so that enrolling, confirming and
+// disabling two-factor authentication can't be done with a stolen session alone.
+type TOTPCodeRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type Session struct {
+	ID           int       `json:"id"`
+	PlayerID     int       `json:"player_id"`
+	UserAgent    string    `json:"user_agent"`
+	IP           string    `json:"ip"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// LoginResponse is the logged in player together with the token of the newly created session.
+type LoginResponse struct {
+	*Player
+	SessionToken string `json:"session_token"`
+}
+
+// PasswordResetRequest changes a password. The current password, and the second factor when
+// two-factor authentication is enabled, are checked like a login.
+type PasswordResetRequest struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	NewPassword  string `json:"new_password"`
+	TOTPCode     string `json:"totp_code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
 }
 
 type CreatePlayerRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Currency string `json:"currency,omitempty"`
 }
 
 type CreatePonudaRequest struct {
@@ -91,16 +323,20 @@ type CreatePonudaRequest struct {
 	ImaStatistiku bool       `json:"ima_statistiku,omitempty"`
 }
 
+// OdigraniPar is a selection on a ticket. The tip is picked by its name or by its market,
+// line and outcome.
 type OdigraniPar struct {
 	Ponuda    int    `json:"ponuda"`
 	NazivTipa string `json:"naziv"`
+	Tip
 }
 
 type DepositRequest struct {
-	Amount float64 `json:"amount"`
+	Amount   Money  `json:"amount"`
+	Provider string `json:"provider,omitempty"`
 }
 type CreateUplataRequest struct {
-	Amount      float64       `json:"amount"`
+	Amount      Money         `json:"amount"`
 	OdigraniPar []OdigraniPar `json:"odigrani_par"`
 }
 
@@ -114,9 +350,11 @@ func NewPonuda(broj string, ID int, naziv string, vrijeme string, tvKanal string
 		ImaStatistiku: imaStatistiku,
 	}
 }
-func NewPlayer(username, password string) *Player {
+func NewPlayer(username, password, currency string) *Player {
 	return &Player{
-		Username: username,
-		Password: password,
+		Username:  username,
+		Password:  password,
+		Currency:  currency,
+		KYCStatus: KYCUnverified,
 	}
 }
@@ -0,0 +1,110 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 30
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var ErrUsernameTaken = errors.New("username already taken")
+
+// ValidateUsername allows letters, digits, '.', '_' and '-', starting with a letter or digit.
+func ValidateUsername(username string) error {
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return &UserError{Message: fmt.Sprintf("username must be between %d and %d characters long", MinUsernameLength, MaxUsernameLength)}
+	}
+	for i, r := range username {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		case i > 0 && (r == '.' || r == '_' || r == '-'):
+		default:
+			return &UserError{Message: "username may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit"}
+		}
+	}
+	return nil
+}
+
+// ValidatePassword requires a password with both letters and digits that doesn't contain the username.
+func ValidatePassword(username, password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return &UserError{Message: fmt.Sprintf("password must be between %d and %d characters long", MinPasswordLength, MaxPasswordLength)}
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return &UserError{Message: "password must contain at least one letter and one digit"}
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return &UserError{Message: "password must not contain the username"}
+	}
+	return nil
+}
+
+const MinPlayerAge = 18
+
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return &UserError{Message: fmt.Sprintf("invalid email address: %s", email)}
+	}
+	return nil
+}
+
+// ValidateDateOfBirth expects YYYY-MM-DD and a player of at least MinPlayerAge years.
+func ValidateDateOfBirth(dateOfBirth string) error {
+	dob, err := time.Parse(time.DateOnly, dateOfBirth)
+	if err != nil {
+		return &UserError{Message: fmt.Sprintf("invalid date of birth %s, expected YYYY-MM-DD", dateOfBirth)}
+	}
+	if dob.AddDate(MinPlayerAge, 0, 0).After(time.Now()) {
+		return &UserError{Message: fmt.Sprintf("players must be at least %d years old", MinPlayerAge)}
+	}
+	return nil
+}
+
+// ValidateOIB checks the length and ISO 7064 (MOD 11,10) check digit of a Croatian OIB.
+func ValidateOIB(oib string) error {
+	if len(oib) != 11 {
+		return &UserError{Message: "OIB must have 11 digits"}
+	}
+	a := 10
+	for i, r := range oib {
+		if r < '0' || r > '9' {
+			return &UserError{Message: "OIB must have 11 digits"}
+		}
+		digit := int(r - '0')
+		if i == 10 {
+			check := 11 - a
+			if check == 10 {
+				check = 0
+			}
+			if check != digit {
+				return &UserError{Message: "invalid OIB check digit"}
+			}
+			break
+		}
+		a = (a + digit) % 10
+		if a == 0 {
+			a = 10
+		}
+		a = (a * 2) % 11
+	}
+	return nil
+}
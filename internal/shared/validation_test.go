@@ -0,0 +1,84 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		ok       bool
+	}{
+		{"ana", true},
+		{"Marko_K", true},
+		{"marko.kolega-1", true},
+		{"42", false},
+		{"ab", false},
+		{strings.Repeat("a", MaxUsernameLength), true},
+		{strings.Repeat("a", MaxUsernameLength+1), false},
+		{"_marko", false},
+		{".marko", false},
+		{"marko kolega", false},
+		{"marko@kolega", false},
+		{"šime", false},
+	}
+	for _, tt := range tests {
+		err := ValidateUsername(tt.username)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateUsername(%q) = %v, want ok %v", tt.username, err, tt.ok)
+		}
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		ok       bool
+	}{
+		{"letters and digits", "marko", "lozinka123", true},
+		{"too short", "marko", "abc123", false},
+		{"shortest allowed", "marko", "abcdef12", true},
+		{"longest allowed", "marko", strings.Repeat("a", MaxPasswordLength-1) + "1", true},
+		{"too long", "marko", strings.Repeat("a", MaxPasswordLength) + "1", false},
+		{"no digit", "marko", "lozinkalozinka", false},
+		{"no letter", "marko", "1234567890", false},
+		{"contains username", "marko", "marko12345", false},
+		{"contains username in other case", "marko", "xxMARKO123", false},
+		{"no username to compare", "", "lozinka123", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePassword(tt.username, tt.password)
+			if (err == nil) != tt.ok {
+				t.Errorf("ValidatePassword(%q, %q) = %v, want ok %v", tt.username, tt.password, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateOIB(t *testing.T) {
+	tests := []struct {
+		oib string
+		ok  bool
+	}{
+		{"69435151530", true},
+		{"12345678903", true},
+		{"00000000001", true},
+		{"99999999994", true},
+		{"69435151531", false},
+		{"12345678900", false},
+		{"6943515153", false},
+		{"694351515300", false},
+		{"6943515153a", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		err := ValidateOIB(tt.oib)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateOIB(%q) = %v, want ok %v", tt.oib, err, tt.ok)
+		}
+	}
+}
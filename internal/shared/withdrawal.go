@@ -0,0 +1,47 @@
+package shared
+
+import "time"
+
+const (
+	WithdrawalPending  = "pending"
+	WithdrawalApproved = "approved"
+	WithdrawalRejected = "rejected"
+	WithdrawalPaid     = "paid"
+)
+
+// HouseWithdrawalsAccount holds funds reserved by pending and approved withdrawals.
+const HouseWithdrawalsAccount = "house:withdrawals"
+
+var withdrawalTransitions = map[string][]string{
+	WithdrawalPending:  {WithdrawalApproved, WithdrawalRejected},
+	WithdrawalApproved: {WithdrawalPaid, WithdrawalRejected},
+}
+
+// CanTransitionWithdrawal reports whether a withdrawal may move from one state to another.
+func CanTransitionWithdrawal(from, to string) bool {
+	for _, next := range withdrawalTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+type Withdrawal struct {
+	ID        int       `json:"id"`
+	PlayerID  int       `json:"player_id"`
+	Amount    Money     `json:"amount"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WithdrawalRequest struct {
+	Amount Money `json:"amount"`
+}
+
+type WithdrawalActionRequest struct {
+	Reason string `json:"reason"`
+}
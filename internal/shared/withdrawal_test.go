@@ -0,0 +1,27 @@
+package shared
+
+import "testing"
+
+func TestCanTransitionWithdrawal(t *testing.T) {
+	tests := []struct {
+		from, to string
+		ok       bool
+	}{
+		{WithdrawalPending, WithdrawalApproved, true},
+		{WithdrawalPending, WithdrawalRejected, true},
+		{WithdrawalPending, WithdrawalPaid, false},
+		{WithdrawalApproved, WithdrawalPaid, true},
+		{WithdrawalApproved, WithdrawalRejected, true},
+		{WithdrawalApproved, WithdrawalPending, false},
+		{WithdrawalRejected, WithdrawalPending, false},
+		{WithdrawalRejected, WithdrawalApproved, false},
+		{WithdrawalPaid, WithdrawalRejected, false},
+		{WithdrawalPaid, WithdrawalPending, false},
+		{"unknown", WithdrawalApproved, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransitionWithdrawal(tt.from, tt.to); got != tt.ok {
+			t.Errorf("CanTransitionWithdrawal(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.ok)
+		}
+	}
+}
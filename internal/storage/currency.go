@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"time"
+)
+
+func (s *PostGresStore) migrateCurrencies() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS exchange_rates (
+			currency VARCHAR(3) PRIMARY KEY,
+			rate NUMERIC(18, 6) NOT NULL CHECK (rate > 0),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+
+		ALTER TABLE Player ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'EUR';
+		ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'EUR';
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'EUR';
+	`)
+	if err != nil {
+		return err
+	}
+	// Parameters can't be used with several statements in one Exec.
+	_, err = s.db.Exec(`INSERT INTO exchange_rates (currency, rate) VALUES ($1, 1) ON CONFLICT (currency) DO NOTHING`, shared.BaseCurrency)
+	return err
+}
+
+func (s *PostGresStore) GetExchangeRates() ([]*shared.ExchangeRate, error) {
+	rows, err := s.db.Query(`SELECT currency, rate, updated_at FROM exchange_rates ORDER BY currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	rates := []*shared.ExchangeRate{}
+	for rows.Next() {
+		rate := new(shared.ExchangeRate)
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+func (s *PostGresStore) GetExchangeRate(currency string) (*shared.ExchangeRate, error) {
+	rate := new(shared.ExchangeRate)
+	err := s.db.QueryRow(`SELECT currency, rate, updated_at FROM exchange_rates WHERE currency = $1`, currency).
+		Scan(&rate.Currency, &rate.Rate, &rate.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (s *PostGresStore) SetExchangeRate(currency string, rate shared.Rate) (*shared.ExchangeRate, error) {
+	updated := new(shared.ExchangeRate)
+	err := s.db.QueryRow(`
+		INSERT INTO exchange_rates (currency, rate) VALUES ($1, $2)
+		ON CONFLICT (currency) DO UPDATE SET rate = $2, updated_at = now()
+		RETURNING currency, rate, updated_at
+	`, currency, rate).Scan(&updated.Currency, &updated.Rate, &updated.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set exchange rate: %v", err)
+	}
+	return updated, nil
+}
+
+// GetLedgerReport totals player wallet movements by type and currency between from (inclusive)
+// and to (exclusive), converted to the base currency at the current exchange rates.
+func (s *PostGresStore) GetLedgerReport(from, to *time.Time) (*shared.LedgerReport, error) {
+	var fromTime, toTime sql.NullTime
+	if from != nil {
+		fromTime = sql.NullTime{Time: *from, Valid: true}
+	}
+	if to != nil {
+		toTime = sql.NullTime{Time: *to, Valid: true}
+	}
+	rows, err := s.db.Query(`
+		SELECT t.type, e.currency, SUM(e.amount), COALESCE(MAX(r.rate), 0)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		LEFT JOIN exchange_rates r ON r.currency = e.currency
+		WHERE e.account LIKE 'player:%'
+		AND ($1::TIMESTAMPTZ IS NULL OR t.created_at >= $1)
+		AND ($2::TIMESTAMPTZ IS NULL OR t.created_at < $2)
+		GROUP BY t.type, e.currency
+		ORDER BY t.type, e.currency
+	`, fromTime, toTime)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	report := &shared.LedgerReport{BaseCurrency: shared.BaseCurrency, Lines: []*shared.LedgerReportLine{}}
+	for rows.Next() {
+		line := new(shared.LedgerReportLine)
+		var rate shared.Rate
+		if err := rows.Scan(&line.Type, &line.Currency, &line.Total, &rate); err != nil {
+			return nil, err
+		}
+		if rate == 0 {
+			return nil, fmt.Errorf("no exchange rate for %s", line.Currency)
+		}
+		line.TotalBase = line.Total.ToBase(rate)
+		report.Lines = append(report.Lines, line)
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/lib/pq"
+)
+
+func (s *PostGresStore) createExposureThresholdsTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS exposure_thresholds (
+			scope VARCHAR(10) NOT NULL,
+			scope_id INT NOT NULL DEFAULT 0,
+			refer_at NUMERIC(14, 2),
+			suspend_at NUMERIC(14, 2),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (scope, scope_id)
+		)
+	`)
+	return err
+}
+
+func (s *PostGresStore) GetExposureThresholds() ([]*shared.ExposureThreshold, error) {
+	rows, err := s.db.Query(`SELECT scope, scope_id, refer_at, suspend_at, updated_at FROM exposure_thresholds ORDER BY scope, scope_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	thresholds := []*shared.ExposureThreshold{}
+	for rows.Next() {
+		t := new(shared.ExposureThreshold)
+		if err := rows.Scan(&t.Scope, &t.ScopeID, &t.ReferAt, &t.SuspendAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, rows.Err()
+}
+
+func (s *PostGresStore) SetExposureThreshold(t *shared.ExposureThreshold) error {
+	err := s.db.QueryRow(`
+		INSERT INTO exposure_thresholds (scope, scope_id, refer_at, suspend_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (scope, scope_id) DO UPDATE SET refer_at = $3, suspend_at = $4, updated_at = now()
+		RETURNING updated_at
+	`, t.Scope, t.ScopeID, t.ReferAt, t.SuspendAt).Scan(&t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save exposure thresholds: %v", err)
+	}
+	return nil
+}
+
+func (s *PostGresStore) DeleteExposureThreshold(scope string, scopeID int) error {
+	if err := deleteOne(s.db.Exec(`DELETE FROM exposure_thresholds WHERE scope = $1 AND scope_id = $2`, scope, scopeID)); err != nil {
+		return fmt.Errorf("no %s exposure thresholds for id %d: %w", scope, scopeID, err)
+	}
+	return nil
+}
+
+// liveBets are the open bets of live tickets, open ones without a lost bet, with the liga of
+// their ponuda. $1 is shared.TicketOpen, $2 shared.BetLost, $3 shared.BetOpen and $4 the ponude
+// to look at, or NULL for all of them.
+const liveBets = `
+	WITH live AS (
+		SELECT u.id, u.currency, u.stake, u.potential_payout FROM uplate u
+		WHERE u.status = $1
+		AND NOT EXISTS (SELECT 1 FROM player_bets lost WHERE lost.uplata_id = u.id AND lost.status = $2)
+	), bets AS (
+		SELECT DISTINCT b.uplata_id, b.ponuda_id, b.tip,
+			COALESCE((SELECT r.lige_id FROM razrade r WHERE b.ponuda_id = ANY(r.ponude) ORDER BY r.id LIMIT 1), 0) AS liga_id
+		FROM player_bets b
+		WHERE b.status = $3 AND ($4::INT[] IS NULL OR b.ponuda_id = ANY($4))
+	)`
+
+// GetLiabilities works out the liability on every tip with open bets, and on every liga, from
+// the live tickets. ponudaIDs narrows the report to some ponude, nil reports on all of them.
+// Amounts are converted to the base currency at the current exchange rates.
+func (s *PostGresStore) GetLiabilities(ponudaIDs []int) (*shared.LiabilityReport, error) {
+	report := &shared.LiabilityReport{BaseCurrency: shared.BaseCurrency, Outcomes: []*shared.OutcomeLiability{}, Lige: []*shared.LigaLiability{}}
+	args := []any{shared.TicketOpen, shared.BetLost, shared.BetOpen, pq.Array(ponudaIDs)}
+
+	outcomes := make(map[string]*shared.OutcomeLiability)
+	err := s.queryExposure(liveBets+`
+		SELECT b.ponuda_id, b.tip, b.liga_id, l.currency, COUNT(*), SUM(l.stake), SUM(l.potential_payout), COALESCE(MAX(r.rate), 0)
+		FROM bets b JOIN live l ON l.id = b.uplata_id
+		LEFT JOIN exchange_rates r ON r.currency = l.currency
+		GROUP BY b.ponuda_id, b.tip, b.liga_id, l.currency
+		ORDER BY b.ponuda_id, b.tip, l.currency
+	`, args, func(rows *sql.Rows) error {
+		var o shared.OutcomeLiability
+		var currency string
+		var tickets int
+		var stakes, payouts shared.Money
+		var rate shared.Rate
+		if err := rows.Scan(&o.PonudaID, &o.Naziv, &o.LigaID, &currency, &tickets, &stakes, &payouts, &rate); err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%d|%s", o.PonudaID, o.Naziv)
+		outcome, ok := outcomes[key]
+		if !ok {
+			outcome = &o
+			outcomes[key] = outcome
+			report.Outcomes = append(report.Outcomes, outcome)
+		}
+		return addInBase(&outcome.Exposure, currency, tickets, stakes, payouts, rate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lige := make(map[int]*shared.LigaLiability)
+	err = s.queryExposure(liveBets+`
+		SELECT t.liga_id, t.currency, COUNT(*), SUM(t.stake), SUM(t.potential_payout), COALESCE(MAX(r.rate), 0)
+		FROM (SELECT DISTINCT b.liga_id, l.id, l.currency, l.stake, l.potential_payout FROM bets b JOIN live l ON l.id = b.uplata_id) t
+		LEFT JOIN exchange_rates r ON r.currency = t.currency
+		GROUP BY t.liga_id, t.currency
+		ORDER BY t.liga_id, t.currency
+	`, args, func(rows *sql.Rows) error {
+		var ligaID, tickets int
+		var currency string
+		var stakes, payouts shared.Money
+		var rate shared.Rate
+		if err := rows.Scan(&ligaID, &currency, &tickets, &stakes, &payouts, &rate); err != nil {
+			return err
+		}
+		liga, ok := lige[ligaID]
+		if !ok {
+			liga = &shared.LigaLiability{LigaID: ligaID}
+			lige[ligaID] = liga
+			report.Lige = append(report.Lige, liga)
+		}
+		return addInBase(&liga.Exposure, currency, tickets, stakes, payouts, rate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *PostGresStore) queryExposure(query string, args []any, scan func(rows *sql.Rows) error) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func addInBase(e *shared.Exposure, currency string, tickets int, stakes, payouts shared.Money, rate shared.Rate) error {
+	if rate == 0 {
+		return fmt.Errorf("no exchange rate for %s", currency)
+	}
+	e.Add(tickets, stakes.ToBase(rate), payouts.ToBase(rate))
+	return nil
+}
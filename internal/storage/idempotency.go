@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"time"
+)
+
+func (s *PostGresStore) createIdempotencyTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			key VARCHAR(255) PRIMARY KEY,
+			fingerprint VARCHAR(64) NOT NULL,
+			status INT NOT NULL DEFAULT 0,
+			content_type VARCHAR(255) NOT NULL DEFAULT '',
+			body BYTEA,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			completed_at TIMESTAMPTZ DEFAULT NULL
+		);
+	`)
+	return err
+}
+
+// migrateIdempotencyKeys scopes keys to the route they were used on, so clients can't collide
+// with each other's keys, and records when the request holding a key last showed it was alive.
+func (s *PostGresStore) migrateIdempotencyKeys() error {
+	_, err := s.db.Exec(`
+		ALTER TABLE idempotency_keys
+			ADD COLUMN IF NOT EXISTS scope VARCHAR(255) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT now();
+		ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+		CREATE UNIQUE INDEX IF NOT EXISTS idempotency_keys_scope_key ON idempotency_keys (scope, key);
+	`)
+	return err
+}
+
+// BeginIdempotentRequest claims key in scope for a new request. Keys created before
+// expireBefore are reclaimed, and so are keys of the same request that never completed and whose
+// holder stopped sending heartbeats before deadBefore. If the key is already taken the existing
+// record is returned and claimed is false.
+func (s *PostGresStore) BeginIdempotentRequest(scope, key, fingerprint string, expireBefore, deadBefore time.Time) (*shared.IdempotencyRecord, bool, error) {
+	record := &shared.IdempotencyRecord{Scope: scope, Key: key, Fingerprint: fingerprint}
+	rows, err := s.db.Query(`
+		INSERT INTO idempotency_keys (scope, key, fingerprint) VALUES ($1, $2, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = 0, content_type = '',
+			body = NULL, created_at = now(), heartbeat_at = now(), completed_at = NULL
+		WHERE idempotency_keys.created_at < $4
+		OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.heartbeat_at < $5
+			AND idempotency_keys.fingerprint = EXCLUDED.fingerprint)
+		RETURNING created_at
+	`, scope, key, fingerprint, expireBefore, deadBefore)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %v", err)
+	}
+	claimed := rows.Next()
+	if claimed {
+		err = rows.Scan(&record.CreatedAt)
+	}
+	if closeErr := rows.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if claimed {
+		return record, true, nil
+	}
+
+	err = s.db.QueryRow(`
+		SELECT fingerprint, status, content_type, body, created_at, completed_at FROM idempotency_keys
+		WHERE scope = $1 AND key = $2
+	`, scope, key).Scan(&record.Fingerprint, &record.Status, &record.ContentType, &record.Body, &record.CreatedAt, &record.CompletedAt)
+	if err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+// HeartbeatIdempotentRequest records that the request holding key is still running.
+func (s *PostGresStore) HeartbeatIdempotentRequest(scope, key string) error {
+	_, err := s.db.Exec(`
+		UPDATE idempotency_keys SET heartbeat_at = now() WHERE scope = $1 AND key = $2 AND completed_at IS NULL
+	`, scope, key)
+	return err
+}
+
+func (s *PostGresStore) CompleteIdempotentRequest(scope, key string, status int, contentType string, body []byte) error {
+	_, err := s.db.Exec(`
+		UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5, completed_at = now()
+		WHERE scope = $1 AND key = $2
+	`, scope, key, status, contentType, body)
+	return err
+}
+
+// ReleaseIdempotentRequest forgets key so the request can be retried.
+func (s *PostGresStore) ReleaseIdempotentRequest(scope, key string) error {
+	_, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND completed_at IS NULL`, scope, key)
+	return err
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+const playerColumns = `id, username, password, account_balance, currency, email, full_name,
+	COALESCE(to_char(date_of_birth, 'YYYY-MM-DD'), ''), address, national_id, kyc_status, kyc_reason`
+
+func (s *PostGresStore) migratePlayerProfile() error {
+	_, err := s.db.Exec(`
+		ALTER TABLE Player
+			ADD COLUMN IF NOT EXISTS email VARCHAR(255) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS full_name VARCHAR(255) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS date_of_birth DATE DEFAULT NULL,
+			ADD COLUMN IF NOT EXISTS address VARCHAR(512) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS national_id VARCHAR(11) NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS kyc_status VARCHAR(16) NOT NULL DEFAULT 'unverified',
+			ADD COLUMN IF NOT EXISTS kyc_reason VARCHAR(255) NOT NULL DEFAULT '';
+
+		CREATE TABLE IF NOT EXISTS kyc_history (
+			id SERIAL PRIMARY KEY,
+			player_id INT NOT NULL,
+			from_status VARCHAR(16) NOT NULL,
+			to_status VARCHAR(16) NOT NULL,
+			reason VARCHAR(255) NOT NULL DEFAULT '',
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			FOREIGN KEY (player_id) REFERENCES player(id) ON DELETE CASCADE
+		);
+	`)
+	return err
+}
+
+// UpdatePlayerProfile stores the profile fields of the player. When the player's KYC status
+// differs from kycFrom, the status moves from kycFrom to it in the same transaction, so a
+// profile change can't be stored without its KYC reset. It returns sql.ErrNoRows if the player
+// is no longer in the kycFrom state.
+func (s *PostGresStore) UpdatePlayerProfile(player *shared.Player, kycFrom string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	_, err = tx.Exec(`
+		UPDATE Player SET email = $2, full_name = $3, date_of_birth = NULLIF($4, '')::DATE, address = $5,
+			national_id = $6
+		WHERE id = $1
+	`, player.ID, player.Email, player.FullName, player.DateOfBirth, player.Address, player.NationalID)
+	if err != nil {
+		return fmt.Errorf("failed to update player profile: %v", err)
+	}
+	if player.KYCStatus != kycFrom {
+		if err := setKYCStatus(tx, player.ID, kycFrom, player.KYCStatus, player.KYCReason); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetKYCStatus moves the player from one KYC state to another. It returns sql.ErrNoRows if
+// the player is no longer in the from state.
+func (s *PostGresStore) SetKYCStatus(playerID int, from, to, reason string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := setKYCStatus(tx, playerID, from, to, reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setKYCStatus(tx *sql.Tx, playerID int, from, to, reason string) error {
+	res, err := tx.Exec(`UPDATE Player SET kyc_status = $3, kyc_reason = $4 WHERE id = $1 AND kyc_status = $2`,
+		playerID, from, to, reason)
+	if err != nil {
+		return fmt.Errorf("failed to set kyc status: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("player %d is not in kyc state %s: %w", playerID, from, sql.ErrNoRows)
+	}
+	_, err = tx.Exec(`INSERT INTO kyc_history (player_id, from_status, to_status, reason) VALUES ($1, $2, $3, $4)`,
+		playerID, from, to, reason)
+	if err != nil {
+		return fmt.Errorf("failed to record kyc history: %v", err)
+	}
+	return nil
+}
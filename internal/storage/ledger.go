@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"log"
+)
+
+func (s *PostGresStore) createLedgerTables() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS ledger_transactions (
+			id SERIAL PRIMARY KEY,
+			type VARCHAR(20) NOT NULL,
+			player_id INT NOT NULL,
+			reference VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE IF NOT EXISTS ledger_entries (
+			id SERIAL PRIMARY KEY,
+			transaction_id INT NOT NULL,
+			account VARCHAR(64) NOT NULL,
+			amount NUMERIC(14, 2) NOT NULL,
+			FOREIGN KEY (transaction_id) REFERENCES ledger_transactions(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS ledger_entries_account_idx ON ledger_entries (account);
+		CREATE INDEX IF NOT EXISTS ledger_transactions_player_idx ON ledger_transactions (player_id, created_at);
+
+		CREATE OR REPLACE FUNCTION ledger_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'ledger is append-only';
+		END;
+		$$ LANGUAGE plpgsql;
+
+		CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS trigger AS $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM ledger_entries WHERE transaction_id = NEW.transaction_id
+				GROUP BY currency HAVING SUM(amount) <> 0
+			) THEN
+				RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+			END IF;
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS ledger_transactions_append_only ON ledger_transactions;
+		CREATE TRIGGER ledger_transactions_append_only BEFORE UPDATE OR DELETE ON ledger_transactions
+			FOR EACH ROW EXECUTE FUNCTION ledger_append_only();
+
+		DROP TRIGGER IF EXISTS ledger_entries_append_only ON ledger_entries;
+		CREATE TRIGGER ledger_entries_append_only BEFORE UPDATE OR DELETE ON ledger_entries
+			FOR EACH ROW EXECUTE FUNCTION ledger_append_only();
+
+		DROP TRIGGER IF EXISTS ledger_entries_balanced ON ledger_entries;
+		CREATE CONSTRAINT TRIGGER ledger_entries_balanced AFTER INSERT ON ledger_entries
+			DEFERRABLE INITIALLY DEFERRED
+			FOR EACH ROW EXECUTE FUNCTION ledger_check_balanced();
+
+		CREATE TABLE IF NOT EXISTS uplate (
+			id SERIAL PRIMARY KEY,
+			player_id INT NOT NULL,
+			iznos NUMERIC(10, 2) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			FOREIGN KEY (player_id) REFERENCES player(id) ON DELETE CASCADE
+		);
+
+		ALTER TABLE player_bets ADD COLUMN IF NOT EXISTS uplata_id INT REFERENCES uplate(id) ON DELETE CASCADE;
+	`)
+	return err
+}
+
+// migrateOpeningBalances books the balances players had before the ledger existed as
+// opening adjustments, so the ledger accounts for every cent in the balance column.
+func (s *PostGresStore) migrateOpeningBalances() error {
+	rows, err := s.db.Query(`
+		SELECT p.id, p.account_balance, p.currency FROM Player p
+		WHERE p.account_balance <> 0
+		AND NOT EXISTS (SELECT 1 FROM ledger_entries e WHERE e.account = 'player:' || p.id)
+	`)
+	if err != nil {
+		return err
+	}
+	type opening struct {
+		playerID int
+		balance  shared.Money
+		currency string
+	}
+	var openings []opening
+	for rows.Next() {
+		var o opening
+		if err := rows.Scan(&o.playerID, &o.balance, &o.currency); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		openings = append(openings, o)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, o := range openings {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		t := shared.NewWalletTransaction(shared.TxAdjustment, o.playerID, shared.HouseAdjustmentsAccount, o.balance, o.currency, "opening balance")
+		if err := insertLedgerTransaction(tx, t); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Booked opening balance %s for player %d", o.balance, o.playerID)
+	}
+	return nil
+}
+
+func (s *PostGresStore) PostLedgerTransaction(t *shared.LedgerTransaction) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := postLedgerTransaction(tx, t); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// postLedgerTransaction books t and applies it to the balance column of every player wallet it
+// touches. Debits that would take a wallet below zero fail with shared.ErrInsufficientFunds.
+func postLedgerTransaction(tx *sql.Tx, t *shared.LedgerTransaction) error {
+	if err := insertLedgerTransaction(tx, t); err != nil {
+		return err
+	}
+	for _, e := range t.Entries {
+		playerID, ok := shared.ParsePlayerAccount(e.Account)
+		if !ok {
+			continue
+		}
+		res, err := tx.Exec(`UPDATE Player SET account_balance = account_balance + $1 WHERE id = $2 AND account_balance + $1 >= 0`,
+			e.Amount, playerID)
+		if err != nil {
+			return fmt.Errorf("failed to update balance of player %d: %v", playerID, err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return fmt.Errorf("player %d: %w", playerID, shared.ErrInsufficientFunds)
+		}
+	}
+	return nil
+}
+
+func insertLedgerTransaction(tx *sql.Tx, t *shared.LedgerTransaction) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	err := tx.QueryRow(`INSERT INTO ledger_transactions (type, player_id, reference) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		t.Type, t.PlayerID, t.Reference).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert ledger transaction: %v", err)
+	}
+	for _, e := range t.Entries {
+		_, err := tx.Exec(`INSERT INTO ledger_entries (transaction_id, account, amount, currency) VALUES ($1, $2, $3, $4)`,
+			t.ID, e.Account, e.Amount, e.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to insert ledger entry: %v", err)
+		}
+	}
+	return nil
+}
+
+// ReconcileBalances returns every player whose balance column doesn't match the ledger.
+func (s *PostGresStore) ReconcileBalances() ([]*shared.BalanceMismatch, error) {
+	rows, err := s.db.Query(`
+		SELECT p.id, p.account_balance, COALESCE(SUM(e.amount), 0) AS ledger_balance
+		FROM Player p
+		LEFT JOIN ledger_entries e ON e.account = 'player:' || p.id
+		GROUP BY p.id, p.account_balance
+		HAVING p.account_balance <> COALESCE(SUM(e.amount), 0)
+		ORDER BY p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	mismatches := []*shared.BalanceMismatch{}
+	for rows.Next() {
+		m := new(shared.BalanceMismatch)
+		if err := rows.Scan(&m.PlayerID, &m.Balance, &m.LedgerBalance); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, m)
+	}
+	return mismatches, rows.Err()
+}
@@ -0,0 +1,354 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/lib/pq"
+)
+
+// migrateLige adds the position lige are listed in. Lige that were never reordered have none and
+// are listed after the others by name.
+func (s *PostGresStore) migrateLige() error {
+	_, err := s.db.Exec(`ALTER TABLE lige ADD COLUMN IF NOT EXISTS position INT`)
+	return err
+}
+
+// CreateLiga adds a liga. If a liga with the name exists, it returns the id of that liga and
+// shared.ErrAlreadyExists.
+func (s *PostGresStore) CreateLiga(naziv string) (int, error) {
+	var existingID int
+	checkQuery := `SELECT id FROM lige WHERE naziv = $1 ORDER BY id LIMIT 1`
+	err := s.db.QueryRow(checkQuery, naziv).Scan(&existingID)
+	if err == nil {
+		return existingID, fmt.Errorf("liga %s: %w", naziv, shared.ErrAlreadyExists)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to check for duplicate liga: %v", err)
+	}
+
+	query := "INSERT INTO lige (naziv) VALUES ($1) RETURNING id"
+	var ligaID int
+	err = s.db.QueryRow(query, naziv).Scan(&ligaID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert liga: %v", err)
+	}
+	return ligaID, nil
+}
+
+// CreateRazrada adds a razrada to a liga. A razrada of the liga with the same ponude is reused,
+// so importing the feed again doesn't duplicate razrade.
+func (s *PostGresStore) CreateRazrada(ligaID int, ponude []int) (int, error) {
+	var razradaID int
+	err := s.db.QueryRow(`SELECT id FROM razrade WHERE lige_id = $1 AND ponude = $2 ORDER BY id LIMIT 1`,
+		ligaID, pq.Array(ponude)).Scan(&razradaID)
+	if err == nil {
+		return razradaID, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to check for duplicate razrada: %v", err)
+	}
+
+	err = s.db.QueryRow(`INSERT INTO razrade (lige_id, ponude) VALUES ($1, $2) RETURNING id`,
+		ligaID, pq.Array(ponude)).Scan(&razradaID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return 0, fmt.Errorf("liga with id %d not found: %w", ligaID, sql.ErrNoRows)
+		}
+		return 0, fmt.Errorf("failed to create razrada: %v", err)
+	}
+	return razradaID, nil
+}
+
+// CreateTipovi defines a tip of a razrada. Position orders the tipovi within the razrada. If the
+// razrada already has a tip with the name, it returns the id of that tip and
+// shared.ErrAlreadyExists.
+func (s *PostGresStore) CreateTipovi(razradaID int, position int, tip shared.Tipovi) (int, error) {
+	var tipID int
+	err := s.db.QueryRow(`SELECT id FROM tipovi WHERE razrade_id = $1 AND naziv = $2 ORDER BY id LIMIT 1`,
+		razradaID, tip.Naziv).Scan(&tipID)
+	if err == nil {
+		return tipID, fmt.Errorf("tip %s: %w", tip.Naziv, shared.ErrAlreadyExists)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to check for duplicate tip: %v", err)
+	}
+
+	query := `INSERT INTO tipovi (razrade_id, naziv, market, line, outcome, position) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err = s.db.QueryRow(query, razradaID, tip.Naziv, tip.Market, tip.Line, tip.Outcome, position).Scan(&tipID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return 0, fmt.Errorf("razrada with id %d not found: %w", razradaID, sql.ErrNoRows)
+		}
+		return 0, fmt.Errorf("failed to insert tip: %v", err)
+	}
+
+	return tipID, nil
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// GetLige returns every liga with its razrade, the tipovi defined for each razrada in order and
+// the market columns they make up.
+func (s *PostGresStore) GetLige() ([]*shared.Lige, error) {
+	return s.queryLige("")
+}
+
+func (s *PostGresStore) GetLiga(id int) (*shared.Lige, error) {
+	lige, err := s.queryLige("WHERE l.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(lige) == 0 {
+		return nil, fmt.Errorf("liga with id %d not found: %w", id, sql.ErrNoRows)
+	}
+	return lige[0], nil
+}
+
+func (s *PostGresStore) GetRazrada(id int) (*shared.Razrade, error) {
+	lige, err := s.queryLige("WHERE r.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(lige) == 0 || len(lige[0].Razrade) == 0 {
+		return nil, fmt.Errorf("razrada with id %d not found: %w", id, sql.ErrNoRows)
+	}
+	return &lige[0].Razrade[0], nil
+}
+
+// queryLige loads the lige, razrade and tipovi matching filter, a WHERE clause over lige l,
+// razrade r and tipovi t.
+func (s *PostGresStore) queryLige(filter string, args ...any) ([]*shared.Lige, error) {
+	rows, err := s.db.Query(`
+		SELECT l.id, l.naziv, r.id, r.ponude, t.id, t.naziv, t.market, t.line, t.outcome
+		FROM lige l
+		LEFT JOIN razrade r ON l.id = r.lige_id
+		LEFT JOIN tipovi t ON r.id = t.razrade_id
+		`+filter+`
+		ORDER BY l.position NULLS LAST, l.naziv, l.id, r.id, t.position, t.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	ligas := []*shared.Lige{}
+	for rows.Next() {
+		var ligaID int
+		var ligaNaziv string
+		var razradaID, tipID *int
+		var ponude pq.Int64Array
+		var tipNaziv, market, outcome *string
+		var line *shared.Line
+
+		if err := rows.Scan(&ligaID, &ligaNaziv, &razradaID, &ponude, &tipID, &tipNaziv, &market, &line, &outcome); err != nil {
+			return nil, err
+		}
+
+		if len(ligas) == 0 || ligas[len(ligas)-1].ID != ligaID {
+			ligas = append(ligas, &shared.Lige{ID: ligaID, Naziv: ligaNaziv, Razrade: []shared.Razrade{}})
+		}
+		liga := ligas[len(ligas)-1]
+		if razradaID == nil {
+			continue
+		}
+
+		if len(liga.Razrade) == 0 || liga.Razrade[len(liga.Razrade)-1].ID != *razradaID {
+			razrada := shared.Razrade{ID: *razradaID, LigaID: ligaID, Tipovi: []shared.Tipovi{}, Ponude: []int{}}
+			for _, p := range ponude {
+				razrada.Ponude = append(razrada.Ponude, int(p))
+			}
+			liga.Razrade = append(liga.Razrade, razrada)
+		}
+		razrada := &liga.Razrade[len(liga.Razrade)-1]
+		if tipID != nil {
+			razrada.Tipovi = append(razrada.Tipovi, shared.Tipovi{
+				ID:    *tipID,
+				Naziv: *tipNaziv,
+				Tip:   shared.Tip{Market: *market, Line: line, Outcome: *outcome},
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, liga := range ligas {
+		for i := range liga.Razrade {
+			liga.Razrade[i].Markets = shared.MarketColumns(liga.Razrade[i].Tipovi)
+		}
+	}
+	return ligas, nil
+}
+
+// updateOne turns an UPDATE that matched no rows into sql.ErrNoRows.
+func updateOne(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("nothing to update: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// UpdateLiga renames a liga. It fails with shared.ErrAlreadyExists if another liga has the name.
+func (s *PostGresStore) UpdateLiga(id int, naziv string) error {
+	var existingID int
+	err := s.db.QueryRow(`SELECT id FROM lige WHERE naziv = $1 AND id <> $2 LIMIT 1`, naziv, id).Scan(&existingID)
+	if err == nil {
+		return fmt.Errorf("liga %s: %w", naziv, shared.ErrAlreadyExists)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check for duplicate liga: %v", err)
+	}
+	if err := updateOne(s.db.Exec(`UPDATE lige SET naziv = $2 WHERE id = $1`, id, naziv)); err != nil {
+		return fmt.Errorf("liga with id %d: %w", id, err)
+	}
+	return nil
+}
+
+// ReorderLige puts the lige in the order of ids.
+func (s *PostGresStore) ReorderLige(ids []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	for position, id := range ids {
+		if err := updateOne(tx.Exec(`UPDATE lige SET position = $2 WHERE id = $1`, id, position)); err != nil {
+			return fmt.Errorf("liga with id %d: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteLiga deletes a liga without razrade, together with its betting limits, exposure
+// thresholds, margin rules and league combination bans. It fails with shared.ErrInUse while the liga has razrade.
+func (s *PostGresStore) DeleteLiga(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var hasRazrade bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM razrade WHERE lige_id = $1)`, id).Scan(&hasRazrade); err != nil {
+		return err
+	}
+	if hasRazrade {
+		return fmt.Errorf("liga %d has razrade: %w", id, shared.ErrInUse)
+	}
+	for _, table := range []string{"bet_limits", "exposure_thresholds"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE scope = $1 AND scope_id = $2`, shared.LimitScopeLeague, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM margin_rules WHERE liga_id = $1`, id); err != nil {
+		return err
+	}
+	if err := deleteOne(tx.Exec(`DELETE FROM lige WHERE id = $1`, id)); err != nil {
+		return fmt.Errorf("liga with id %d: %w", id, err)
+	}
+	return tx.Commit()
+}
+
+// UpdateRazrada moves a razrada to a liga and replaces its ponude.
+func (s *PostGresStore) UpdateRazrada(id int, ligaID int, ponude []int) error {
+	err := updateOne(s.db.Exec(`UPDATE razrade SET lige_id = $2, ponude = $3 WHERE id = $1`, id, ligaID, pq.Array(ponude)))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("liga with id %d not found: %w", ligaID, sql.ErrNoRows)
+		}
+		return fmt.Errorf("razrada with id %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteRazrada deletes a razrada and its tipovi. It fails with shared.ErrInUse while any of its
+// ponude has open bets.
+func (s *PostGresStore) DeleteRazrada(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var hasOpenBets bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM razrade r JOIN player_bets b ON b.ponuda_id = ANY(r.ponude)
+			WHERE r.id = $1 AND b.status = $2
+		)
+	`, id, shared.BetOpen).Scan(&hasOpenBets)
+	if err != nil {
+		return err
+	}
+	if hasOpenBets {
+		return fmt.Errorf("razrada %d has ponude with open bets: %w", id, shared.ErrInUse)
+	}
+	if err := deleteOne(tx.Exec(`DELETE FROM razrade WHERE id = $1`, id)); err != nil {
+		return fmt.Errorf("razrada with id %d: %w", id, err)
+	}
+	return tx.Commit()
+}
+
+// UpdateTip renames a tip of a razrada or changes its market. It fails with
+// shared.ErrAlreadyExists if another tip of the razrada has the name.
+func (s *PostGresStore) UpdateTip(id int, tip shared.Tipovi) error {
+	var existingID int
+	err := s.db.QueryRow(`
+		SELECT o.id FROM tipovi t JOIN tipovi o ON o.razrade_id = t.razrade_id AND o.id <> t.id
+		WHERE t.id = $1 AND o.naziv = $2 LIMIT 1
+	`, id, tip.Naziv).Scan(&existingID)
+	if err == nil {
+		return fmt.Errorf("tip %s: %w", tip.Naziv, shared.ErrAlreadyExists)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check for duplicate tip: %v", err)
+	}
+	err = updateOne(s.db.Exec(`UPDATE tipovi SET naziv = $2, market = $3, line = $4, outcome = $5 WHERE id = $1`,
+		id, tip.Naziv, tip.Market, tip.Line, tip.Outcome))
+	if err != nil {
+		return fmt.Errorf("tip with id %d: %w", id, err)
+	}
+	return nil
+}
+
+// ReorderTipovi puts the tipovi of a razrada in the order of ids.
+func (s *PostGresStore) ReorderTipovi(razradaID int, ids []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	for position, id := range ids {
+		err := updateOne(tx.Exec(`UPDATE tipovi SET position = $3 WHERE id = $1 AND razrade_id = $2`, id, razradaID, position))
+		if err != nil {
+			return fmt.Errorf("tip with id %d of razrada %d: %w", id, razradaID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostGresStore) DeleteTip(id int) error {
+	if err := deleteOne(s.db.Exec(`DELETE FROM tipovi WHERE id = $1`, id)); err != nil {
+		return fmt.Errorf("tip with id %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/lib/pq"
+)
+
+func (s *PostGresStore) createBetLimitsTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bet_limits (
+			scope VARCHAR(10) NOT NULL,
+			scope_id INT NOT NULL DEFAULT 0,
+			min_stake NUMERIC(14, 2),
+			max_stake NUMERIC(14, 2),
+			max_payout NUMERIC(14, 2),
+			max_selections INT,
+			min_selection_odds NUMERIC(8, 2),
+			min_ticket_odds NUMERIC(12, 2),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (scope, scope_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO bet_limits (scope, scope_id, max_payout) VALUES ($1, 0, 1000)
+		ON CONFLICT (scope, scope_id) DO NOTHING
+	`, shared.LimitScopeGlobal)
+	return err
+}
+
+const betLimitColumns = `scope, scope_id, min_stake, max_stake, max_payout, max_selections,
+	min_selection_odds, min_ticket_odds, updated_at`
+
+func scanBetLimitRule(row interface{ Scan(...any) error }) (*shared.BetLimitRule, error) {
+	rule := new(shared.BetLimitRule)
+	err := row.Scan(&rule.Scope, &rule.ScopeID, &rule.MinStake, &rule.MaxStake, &rule.MaxPayout, &rule.MaxSelections,
+		&rule.MinSelectionOdds, &rule.MinTicketOdds, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *PostGresStore) queryBetLimitRules(query string, args ...any) ([]*shared.BetLimitRule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	rules := []*shared.BetLimitRule{}
+	for rows.Next() {
+		rule, err := scanBetLimitRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *PostGresStore) GetAllBetLimitRules() ([]*shared.BetLimitRule, error) {
+	return s.queryBetLimitRules(`SELECT ` + betLimitColumns + ` FROM bet_limits ORDER BY scope, scope_id`)
+}
+
+// GetBetLimitRules returns the global rules and the overrides for the player, leagues and events.
+func (s *PostGresStore) GetBetLimitRules(playerID int, ligaIDs, ponudaIDs []int) ([]*shared.BetLimitRule, error) {
+	return s.queryBetLimitRules(`
+		SELECT `+betLimitColumns+` FROM bet_limits
+		WHERE scope = $1
+		OR (scope = $2 AND scope_id = $3)
+		OR (scope = $4 AND scope_id = ANY($5))
+		OR (scope = $6 AND scope_id = ANY($7))
+	`, shared.LimitScopeGlobal, shared.LimitScopePlayer, playerID,
+		shared.LimitScopeLeague, pq.Array(ligaIDs), shared.LimitScopeEvent, pq.Array(ponudaIDs))
+}
+
+func (s *PostGresStore) SetBetLimitRule(rule *shared.BetLimitRule) error {
+	row := s.db.QueryRow(`
+		INSERT INTO bet_limits (scope, scope_id, min_stake, max_stake, max_payout, max_selections,
+			min_selection_odds, min_ticket_odds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (scope, scope_id) DO UPDATE SET min_stake = $3, max_stake = $4, max_payout = $5,
+			max_selections = $6, min_selection_odds = $7, min_ticket_odds = $8, updated_at = now()
+		RETURNING `+betLimitColumns,
+		rule.Scope, rule.ScopeID, rule.MinStake, rule.MaxStake, rule.MaxPayout, rule.MaxSelections,
+		rule.MinSelectionOdds, rule.MinTicketOdds)
+	saved, err := scanBetLimitRule(row)
+	if err != nil {
+		return fmt.Errorf("failed to save bet limits: %v", err)
+	}
+	*rule = *saved
+	return nil
+}
+
+func (s *PostGresStore) DeleteBetLimitRule(scope string, scopeID int) error {
+	if err := deleteOne(s.db.Exec(`DELETE FROM bet_limits WHERE scope = $1 AND scope_id = $2`, scope, scopeID)); err != nil {
+		return fmt.Errorf("no %s limits for id %d: %w", scope, scopeID, err)
+	}
+	return nil
+}
+
+// GetBetSelections looks up the tip, current odds, suspension and league of every selection and
+// whether its ponuda is closed for betting. Selections
+// without a naziv are looked up by their market, line and outcome.
+func (s *PostGresStore) GetBetSelections(parovi []shared.OdigraniPar) ([]*shared.BetSelection, error) {
+	selections := make([]*shared.BetSelection, 0, len(parovi))
+	for _, par := range parovi {
+		sel := &shared.BetSelection{Ponuda: par.Ponuda}
+		var ligaID sql.NullInt64
+		var status string
+		err := s.db.QueryRow(`
+			SELECT t.naziv, t.market, t.line, t.outcome, t.tecaj, t.suspended, `+ponudaStatus+`,
+				(SELECT r.lige_id FROM razrade r WHERE t.ponuda_id = ANY(r.ponude) ORDER BY r.id LIMIT 1)
+			FROM tecajevi t JOIN ponude p ON p.id = t.ponuda_id WHERE t.ponuda_id = $1 AND CASE
+				WHEN $2 <> '' THEN t.naziv = $2
+				ELSE t.market = $3 AND t.outcome = $4 AND t.line IS NOT DISTINCT FROM $5
+			END
+			ORDER BY t.id LIMIT 1
+		`, par.Ponuda, par.NazivTipa, par.Market, par.Outcome, par.Line).
+			Scan(&sel.NazivTipa, &sel.Market, &sel.Line, &sel.Outcome, &sel.Tecaj, &sel.Suspended, &status, &ligaID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				tip := par.NazivTipa
+				if tip == "" {
+					tip = par.Tip.Name()
+				}
+				return nil, fmt.Errorf("tecaj for ponuda with ID %d and tip %s does not exist: %w", par.Ponuda, tip, sql.ErrNoRows)
+			}
+			return nil, err
+		}
+		sel.LigaID = int(ligaID.Int64)
+		sel.Closed = status != shared.PonudaOpen
+		selections = append(selections, sel)
+	}
+	return selections, nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/lib/pq"
+	"time"
+)
+
+func (s *PostGresStore) createLoginThrottleTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_throttles (
+			scope VARCHAR(16) NOT NULL,
+			key VARCHAR(255) NOT NULL,
+			failures INT NOT NULL DEFAULT 0,
+			last_failure TIMESTAMPTZ NOT NULL,
+			locked_until TIMESTAMPTZ DEFAULT NULL,
+			PRIMARY KEY (scope, key)
+		);
+	`)
+	return err
+}
+
+func (s *PostGresStore) GetLoginThrottle(scope, key string) (*shared.LoginThrottle, error) {
+	throttle := &shared.LoginThrottle{Scope: scope, Key: key}
+	var lockedUntil sql.NullTime
+	err := s.db.QueryRow(`SELECT failures, last_failure, locked_until FROM login_throttles WHERE scope = $1 AND key = $2`,
+		scope, key).Scan(&throttle.Failures, &throttle.LastFailure, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return throttle, nil
+		}
+		return nil, fmt.Errorf("failed to get login throttle: %v", err)
+	}
+	if lockedUntil.Valid {
+		throttle.LockedUntil = &lockedUntil.Time
+	}
+	return throttle, nil
+}
+
+// RecordLoginFailure counts a failed attempt at time at. Failures older than forgetBefore
+// and expired lockouts are discarded, so the counter starts over.
+func (s *PostGresStore) RecordLoginFailure(scope, key string, at, forgetBefore time.Time) (*shared.LoginThrottle, error) {
+	throttle := &shared.LoginThrottle{Scope: scope, Key: key}
+	var lockedUntil sql.NullTime
+	err := s.db.QueryRow(`
+		INSERT INTO login_throttles (scope, key, failures, last_failure) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure < $4 OR login_throttles.locked_until <= $3 THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			locked_until = CASE WHEN login_throttles.locked_until <= $3 THEN NULL ELSE login_throttles.locked_until END,
+			last_failure = $3
+		RETURNING failures, last_failure, locked_until
+	`, scope, key, at, forgetBefore).Scan(&throttle.Failures, &throttle.LastFailure, &lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %v", err)
+	}
+	if lockedUntil.Valid {
+		throttle.LockedUntil = &lockedUntil.Time
+	}
+	return throttle, nil
+}
+
+func (s *PostGresStore) LockLogin(scope, key string, until time.Time) error {
+	_, err := s.db.Exec(`UPDATE login_throttles SET locked_until = $3 WHERE scope = $1 AND key = $2`, scope, key, until)
+	if err != nil {
+		return fmt.Errorf("failed to lock login: %v", err)
+	}
+	return nil
+}
+
+func (s *PostGresStore) ResetLoginFailures(scope, key string) error {
+	_, err := s.db.Exec(`DELETE FROM login_throttles WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset login failures: %v", err)
+	}
+	return nil
+}
+
+// UnlockPlayerLogins clears the login failures of the username and of the IPs the player has
+// signed in from, along with any other IPs given.
+func (s *PostGresStore) UnlockPlayerLogins(playerID int, username string, ips []string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM login_throttles
+		WHERE (scope = $1 AND key = $2)
+		OR (scope = $3 AND (key IN (SELECT ip FROM sessions WHERE player_id = $4) OR key = ANY($5)))
+	`, shared.LoginScopeUsername, username, shared.LoginScopeIP, playerID, pq.Array(ips))
+	if err != nil {
+		return fmt.Errorf("failed to unlock player logins: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+// migrateMarkets adds the market, line and outcome to tecajevi, bets and the tipovi defined for
+// razrade, and the score to results. Existing tipovi get the market their name parses to.
+func (s *PostGresStore) migrateMarkets() error {
+	_, err := s.db.Exec(`
+		ALTER TABLE tecajevi ADD COLUMN IF NOT EXISTS market VARCHAR(20) NOT NULL DEFAULT '';
+		ALTER TABLE tecajevi ADD COLUMN IF NOT EXISTS line NUMERIC(6, 2);
+		ALTER TABLE tecajevi ADD COLUMN IF NOT EXISTS outcome VARCHAR(64) NOT NULL DEFAULT '';
+
+		ALTER TABLE tipovi ADD COLUMN IF NOT EXISTS market VARCHAR(20) NOT NULL DEFAULT '';
+		ALTER TABLE tipovi ADD COLUMN IF NOT EXISTS line NUMERIC(6, 2);
+		ALTER TABLE tipovi ADD COLUMN IF NOT EXISTS outcome VARCHAR(64) NOT NULL DEFAULT '';
+		ALTER TABLE tipovi ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+
+		ALTER TABLE player_bets ALTER COLUMN tip TYPE VARCHAR(64);
+		ALTER TABLE player_bets ADD COLUMN IF NOT EXISTS market VARCHAR(20) NOT NULL DEFAULT '';
+		ALTER TABLE player_bets ADD COLUMN IF NOT EXISTS line NUMERIC(6, 2);
+		ALTER TABLE player_bets ADD COLUMN IF NOT EXISTS outcome VARCHAR(64) NOT NULL DEFAULT '';
+
+		ALTER TABLE ponuda_results ALTER COLUMN winning_tipovi TYPE VARCHAR(64)[];
+		ALTER TABLE ponuda_results ADD COLUMN IF NOT EXISTS home_score INT;
+		ALTER TABLE ponuda_results ADD COLUMN IF NOT EXISTS away_score INT;
+	`)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []struct{ name, naziv string }{{"tecajevi", "naziv"}, {"tipovi", "naziv"}, {"player_bets", "tip"}} {
+		rows, err := s.db.Query(fmt.Sprintf(`SELECT DISTINCT %s FROM %s WHERE market = ''`, table.naziv, table.name))
+		if err != nil {
+			return err
+		}
+		var names []string
+		for rows.Next() {
+			var naziv string
+			if err := rows.Scan(&naziv); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			names = append(names, naziv)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		for _, naziv := range names {
+			tip := shared.ParseTipName(naziv)
+			_, err := s.db.Exec(fmt.Sprintf(`UPDATE %s SET market = $2, line = $3, outcome = $4 WHERE %s = $1 AND market = ''`, table.name, table.naziv),
+				naziv, tip.Market, tip.Line, tip.Outcome)
+			if err != nil {
+				return fmt.Errorf("failed to set market of %s %q: %v", table.name, naziv, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetRazradaMismatches checks the tecajevi of every ponuda against the tipovi defined for its
+// razrada with shared.CheckRazrada.
+func (s *PostGresStore) GetRazradaMismatches() ([]shared.RazradaMismatch, error) {
+	lige, err := s.GetLige()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT ponuda_id, naziv, market, line, outcome FROM tecajevi
+		WHERE ponuda_id IN (SELECT unnest(ponude) FROM razrade)
+		ORDER BY ponuda_id, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	offered := make(map[int][]shared.Tecajevi)
+	for rows.Next() {
+		var ponudaID int
+		var tecaj shared.Tecajevi
+		if err := rows.Scan(&ponudaID, &tecaj.Naziv, &tecaj.Market, &tecaj.Line, &tecaj.Outcome); err != nil {
+			return nil, err
+		}
+		offered[ponudaID] = append(offered[ponudaID], tecaj)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	mismatches := []shared.RazradaMismatch{}
+	for _, liga := range lige {
+		for i := range liga.Razrade {
+			mismatches = append(mismatches, shared.CheckRazrada(liga.ID, &liga.Razrade[i], offered)...)
+		}
+	}
+	return mismatches, nil
+}
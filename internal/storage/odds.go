@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+// createTecajHistoryTable keeps every change of the odds and of their suspension. Odds set
+// before the history existed are recorded as initial.
+func (s *PostGresStore) createTecajHistoryTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS tecaj_history (
+			id SERIAL PRIMARY KEY,
+			tecaj_id INT NOT NULL,
+			ponuda_id INT NOT NULL,
+			naziv VARCHAR(255) NOT NULL,
+			tecaj NUMERIC(8, 2) NOT NULL,
+			previous NUMERIC(8, 2),
+			source VARCHAR(20) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS tecaj_history_ponuda_idx ON tecaj_history (ponuda_id, created_at);
+		ALTER TABLE tecaj_history ADD COLUMN IF NOT EXISTS suspended BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE tecaj_history ADD COLUMN IF NOT EXISTS reason VARCHAR(255) NOT NULL DEFAULT '';
+
+		ALTER TABLE tecajevi ADD COLUMN IF NOT EXISTS suspended BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE tecajevi ADD COLUMN IF NOT EXISTS odds_source VARCHAR(20) NOT NULL DEFAULT 'initial';
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO tecaj_history (tecaj_id, ponuda_id, naziv, tecaj, source)
+		SELECT t.id, t.ponuda_id, t.naziv, t.tecaj, $1 FROM tecajevi t
+		WHERE NOT EXISTS (SELECT 1 FROM tecaj_history h WHERE h.tecaj_id = t.id)
+	`, shared.OddsSourceInitial)
+	return err
+}
+
+// CreateTecaj sets the odds of a tip on a ponuda, adding the tip if the ponuda doesn't offer it
+// yet, and records the change in the odds history. Odds that didn't change aren't recorded. A
+// suspended tip stays suspended. The feed doesn't overwrite odds a trader set; it fails with
+// shared.ErrTraderOdds instead.
+func (s *PostGresStore) CreateTecaj(ponudaID int, tecaj shared.Tecajevi, source string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	change := &shared.TecajChange{PonudaID: ponudaID, Naziv: tecaj.Naziv, Source: source}
+	var oddsSource string
+	err = tx.QueryRow(`
+		SELECT id, tecaj, suspended, odds_source FROM tecajevi WHERE ponuda_id = $1 AND naziv = $2
+		ORDER BY id LIMIT 1 FOR UPDATE
+	`, ponudaID, tecaj.Naziv).Scan(&change.TecajID, &change.Tecaj, &change.Suspended, &oddsSource)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		change.Tecaj = tecaj.Tecaj
+		err = tx.QueryRow(`
+			INSERT INTO tecajevi (ponuda_id, tecaj, naziv, market, line, outcome, odds_source) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, ponudaID, tecaj.Tecaj, tecaj.Naziv, tecaj.Market, tecaj.Line, tecaj.Outcome, source).Scan(&change.TecajID)
+		if err != nil {
+			return fmt.Errorf("failed to insert tecaj: %v", err)
+		}
+	case err != nil:
+		return err
+	case change.Tecaj == tecaj.Tecaj:
+		return nil
+	case source == shared.OddsSourceFeed && oddsSource == shared.OddsSourceTrader:
+		return fmt.Errorf("tecaj %d is %s, feed odds %s: %w", change.TecajID, change.Tecaj, tecaj.Tecaj, shared.ErrTraderOdds)
+	default:
+		change.Set(tecaj.Tecaj)
+		_, err = tx.Exec(`UPDATE tecajevi SET tecaj = $2, odds_source = $3 WHERE id = $1`, change.TecajID, change.Tecaj, source)
+		if err != nil {
+			return fmt.Errorf("failed to update tecaj: %v", err)
+		}
+	}
+
+	if err := insertTecajChange(tx, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// tecajHistoryLock is the advisory lock that serializes the writers of the odds history.
+const tecajHistoryLock = 4604
+
+// insertTecajChange records change in the odds history. The history is read by id as a cursor,
+// so a change must not become visible after one with a higher id. Writers hold a lock until
+// they commit to keep the ids in commit order.
+func insertTecajChange(tx *sql.Tx, change *shared.TecajChange) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, tecajHistoryLock); err != nil {
+		return fmt.Errorf("failed to lock odds history: %v", err)
+	}
+	err := tx.QueryRow(`
+		INSERT INTO tecaj_history (tecaj_id, ponuda_id, naziv, tecaj, previous, suspended, source, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, change.TecajID, change.PonudaID, change.Naziv, change.Tecaj, change.Previous, change.Suspended, change.Source, change.Reason).
+		Scan(&change.ID, &change.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record odds change: %v", err)
+	}
+	return nil
+}
+
+const tecajChangeColumns = `id, tecaj_id, ponuda_id, naziv, tecaj, previous, suspended, source, reason, created_at`
+
+func scanTecajChanges(rows *sql.Rows) ([]*shared.TecajChange, error) {
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	changes := []*shared.TecajChange{}
+	for rows.Next() {
+		change := new(shared.TecajChange)
+		err := rows.Scan(&change.ID, &change.TecajID, &change.PonudaID, &change.Naziv, &change.Tecaj, &change.Previous,
+			&change.Suspended, &change.Source, &change.Reason, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// GetTecajHistory returns the odds changes of a ponuda, oldest first.
+func (s *PostGresStore) GetTecajHistory(ponudaID int, filter shared.TecajHistoryFilter) ([]*shared.TecajChange, error) {
+	var from, to sql.NullTime
+	if filter.From != nil {
+		from = sql.NullTime{Time: *filter.From, Valid: true}
+	}
+	if filter.To != nil {
+		to = sql.NullTime{Time: *filter.To, Valid: true}
+	}
+	rows, err := s.db.Query(`
+		SELECT `+tecajChangeColumns+` FROM tecaj_history
+		WHERE ponuda_id = $1 AND ($2 = '' OR naziv = $2)
+		AND ($3::TIMESTAMPTZ IS NULL OR created_at >= $3) AND ($4::TIMESTAMPTZ IS NULL OR created_at < $4)
+		ORDER BY created_at, id
+	`, ponudaID, filter.Naziv, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanTecajChanges(rows)
+}
+
+// GetTecajChanges returns up to limit odds changes recorded after the change afterID, oldest
+// first. Ids are handed out in commit order, see insertTecajChange.
+func (s *PostGresStore) GetTecajChanges(afterID int, limit int) ([]*shared.TecajChange, error) {
+	rows, err := s.db.Query(`SELECT `+tecajChangeColumns+` FROM tecaj_history WHERE id > $1 ORDER BY id LIMIT $2`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTecajChanges(rows)
+}
+
+func (s *PostGresStore) LastTecajChangeID() (int, error) {
+	var id int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM tecaj_history`).Scan(&id)
+	return id, err
+}
+
+// UpdateTecajevi applies updates in one transaction and records them in the odds history as
+// coming from source. Updates that don't change anything aren't recorded. It fails with a
+// wrapped sql.ErrNoRows if any of the tipovi doesn't exist.
+func (s *PostGresStore) UpdateTecajevi(updates []shared.TecajUpdate, source, reason string) ([]*shared.TecajChange, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	changes, err := updateTecajevi(tx, updates, source, reason)
+	if err != nil {
+		return nil, err
+	}
+	return changes, tx.Commit()
+}
+
+func updateTecajevi(tx *sql.Tx, updates []shared.TecajUpdate, source, reason string) ([]*shared.TecajChange, error) {
+	changes := []*shared.TecajChange{}
+	for _, u := range updates {
+		change := &shared.TecajChange{Source: source, Reason: reason}
+		var oddsSource string
+		err := tx.QueryRow(`
+			SELECT id, ponuda_id, naziv, tecaj, suspended, odds_source FROM tecajevi
+			WHERE CASE WHEN $1 <> 0 THEN id = $1 ELSE ponuda_id = $2 AND naziv = $3 END
+			ORDER BY id LIMIT 1 FOR UPDATE
+		`, u.ID, u.Ponuda, u.Naziv).Scan(&change.TecajID, &change.PonudaID, &change.Naziv, &change.Tecaj, &change.Suspended, &oddsSource)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				if u.ID != 0 {
+					return nil, fmt.Errorf("tecaj with id %d not found: %w", u.ID, sql.ErrNoRows)
+				}
+				return nil, fmt.Errorf("tecaj for ponuda with ID %d and tip %s does not exist: %w", u.Ponuda, u.Naziv, sql.ErrNoRows)
+			}
+			return nil, err
+		}
+
+		oddsChanged := u.Tecaj != nil && change.Set(*u.Tecaj)
+		suspensionChanged := u.Suspended != nil && *u.Suspended != change.Suspended
+		if !oddsChanged && !suspensionChanged {
+			continue
+		}
+		if oddsChanged {
+			oddsSource = source
+		}
+		if suspensionChanged {
+			change.Suspended = *u.Suspended
+		}
+
+		_, err = tx.Exec(`UPDATE tecajevi SET tecaj = $2, suspended = $3, odds_source = $4 WHERE id = $1`,
+			change.TecajID, change.Tecaj, change.Suspended, oddsSource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update tecaj %d: %v", change.TecajID, err)
+		}
+		if err := insertTecajChange(tx, change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// SuspendPonuda suspends or unsuspends every tip of a ponuda, or only the tipovi of one market
+// (and line) if req names one.
+func (s *PostGresStore) SuspendPonuda(ponudaID int, req *shared.SuspendRequest, suspended bool) ([]*shared.TecajChange, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	rows, err := tx.Query(`
+		SELECT id FROM tecajevi
+		WHERE ponuda_id = $1 AND ($2 = '' OR market = $2 AND ($3::NUMERIC IS NULL OR line = $3))
+		ORDER BY id
+	`, ponudaID, req.Market, req.Line)
+	if err != nil {
+		return nil, err
+	}
+	var updates []shared.TecajUpdate
+	for rows.Next() {
+		u := shared.TecajUpdate{Suspended: &suspended}
+		if err := rows.Scan(&u.ID); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		updates = append(updates, u)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if len(updates) == 0 {
+		if req.Market != "" {
+			return nil, fmt.Errorf("ponuda %d has no %s tipovi: %w", ponudaID, req.Market, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("ponuda %d has no tipovi: %w", ponudaID, sql.ErrNoRows)
+	}
+
+	changes, err := updateTecajevi(tx, updates, shared.OddsSourceTrader, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return changes, tx.Commit()
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+func (s *PostGresStore) createPaymentIntentTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS payment_intents (
+			id SERIAL PRIMARY KEY,
+			provider VARCHAR(32) NOT NULL,
+			provider_ref VARCHAR(255) NOT NULL,
+			player_id INT NOT NULL,
+			amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+			currency VARCHAR(3) NOT NULL DEFAULT 'EUR',
+			status VARCHAR(16) NOT NULL DEFAULT 'created',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			UNIQUE (provider, provider_ref),
+			FOREIGN KEY (player_id) REFERENCES player(id) ON DELETE CASCADE
+		);
+
+		ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'EUR';
+	`)
+	return err
+}
+
+const paymentIntentColumns = `id, provider, provider_ref, player_id, amount, currency, status, created_at, updated_at`
+
+func (s *PostGresStore) CreatePaymentIntent(intent *shared.PaymentIntent) error {
+	err := s.db.QueryRow(`
+		INSERT INTO payment_intents (provider, provider_ref, player_id, amount, currency) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at, updated_at
+	`, intent.Provider, intent.ProviderRef, intent.PlayerID, intent.Amount, intent.Currency).
+		Scan(&intent.ID, &intent.Status, &intent.CreatedAt, &intent.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create payment intent: %v", err)
+	}
+	return nil
+}
+
+func (s *PostGresStore) GetPaymentIntent(provider, providerRef string) (*shared.PaymentIntent, error) {
+	intent := new(shared.PaymentIntent)
+	err := s.db.QueryRow(`SELECT `+paymentIntentColumns+` FROM payment_intents WHERE provider = $1 AND provider_ref = $2`,
+		provider, providerRef).Scan(&intent.ID, &intent.Provider, &intent.ProviderRef, &intent.PlayerID, &intent.Amount,
+		&intent.Currency, &intent.Status, &intent.CreatedAt, &intent.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// CompletePaymentIntent applies a verified provider callback. A successful payment credits the
+// player's wallet. Callbacks for an intent that is already finished change nothing, so the
+// second return value reports whether this call applied the callback.
+func (s *PostGresStore) CompletePaymentIntent(provider string, callback *shared.PaymentCallback) (*shared.PaymentIntent, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	intent := new(shared.PaymentIntent)
+	err = tx.QueryRow(`SELECT `+paymentIntentColumns+` FROM payment_intents WHERE provider = $1 AND provider_ref = $2 FOR UPDATE`,
+		provider, callback.ProviderRef).Scan(&intent.ID, &intent.Provider, &intent.ProviderRef, &intent.PlayerID, &intent.Amount,
+		&intent.Currency, &intent.Status, &intent.CreatedAt, &intent.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("payment intent %s not found: %w", callback.ProviderRef, sql.ErrNoRows)
+		}
+		return nil, false, err
+	}
+	if intent.Status != shared.PaymentCreated {
+		return intent, false, nil
+	}
+	if callback.Status == shared.PaymentSucceeded && callback.Amount != intent.Amount {
+		return nil, false, fmt.Errorf("payment %s confirmed %s but intent was for %s", callback.ProviderRef, callback.Amount, intent.Amount)
+	}
+
+	err = tx.QueryRow(`UPDATE payment_intents SET status = $2, updated_at = now() WHERE id = $1 RETURNING status, updated_at`,
+		intent.ID, callback.Status).Scan(&intent.Status, &intent.UpdatedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update payment intent: %v", err)
+	}
+	if intent.Status == shared.PaymentSucceeded {
+		currency, err := lockPlayer(tx, intent.PlayerID)
+		if err != nil {
+			return nil, false, err
+		}
+		if currency != intent.Currency {
+			return nil, false, fmt.Errorf("payment %s is in %s but the wallet is in %s", intent.ProviderRef, intent.Currency, currency)
+		}
+		deposit := shared.NewWalletTransaction(shared.TxDeposit, intent.PlayerID, shared.HouseCashAccount, intent.Amount,
+			intent.Currency, fmt.Sprintf("payment:%s:%s", intent.Provider, intent.ProviderRef))
+		if err := postLedgerTransaction(tx, deposit); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return intent, true, nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/lib/pq"
+)
+
+// ponudaStatus works out the status of the ponuda p. Vrijeme is local time, so it is compared
+// with the local time of the database.
+const ponudaStatus = `CASE
+	WHEN p.withdrawn_at IS NOT NULL THEN '` + shared.PonudaWithdrawn + `'
+	WHEN EXISTS (SELECT 1 FROM ponuda_results pr WHERE pr.ponuda_id = p.id) THEN '` + shared.PonudaResulted + `'
+	WHEN p.vrijeme <= LOCALTIMESTAMP THEN '` + shared.PonudaStarted + `'
+	ELSE '` + shared.PonudaOpen + `'
+END`
+
+// migratePonude records when a ponuda was withdrawn from the offer.
+func (s *PostGresStore) migratePonude() error {
+	_, err := s.db.Exec(`ALTER TABLE ponude ADD COLUMN IF NOT EXISTS withdrawn_at TIMESTAMPTZ`)
+	return err
+}
+
+// UpdatePonuda changes the naziv, vrijeme, tv kanal and statistics flag of a ponuda.
+func (s *PostGresStore) UpdatePonuda(ponuda *shared.Ponude) error {
+	err := updateOne(s.db.Exec(`UPDATE ponude SET naziv = $2, vrijeme = $3, tv_kanal = $4, ima_statistiku = $5 WHERE id = $1`,
+		ponuda.ID, ponuda.Naziv, ponuda.Vrijeme, ponuda.TvKanal, ponuda.ImaStatistiku))
+	if err != nil {
+		return fmt.Errorf("ponuda with id %d: %w", ponuda.ID, err)
+	}
+	return nil
+}
+
+// WithdrawPonuda takes a ponuda off the offer, records it as cancelled and voids the open bets on
+// it. It returns the ids of the open tickets with bets on the ponuda, and shared.ErrResultExists
+// if the ponuda already has a result or was withdrawn before.
+func (s *PostGresStore) WithdrawPonuda(id int) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := updateOne(tx.Exec(`UPDATE ponude SET withdrawn_at = now() WHERE id = $1`, id)); err != nil {
+		return nil, fmt.Errorf("ponuda with id %d: %w", id, err)
+	}
+	result := &shared.PonudaResult{PonudaID: id, Status: shared.ResultCancelled, WinningTipovi: []string{}}
+	err = tx.QueryRow(`
+		INSERT INTO ponuda_results (ponuda_id, status, winning_tipovi) VALUES ($1, $2, $3)
+		ON CONFLICT (ponuda_id) DO NOTHING
+		RETURNING created_at
+	`, id, result.Status, pq.Array(result.WinningTipovi)).Scan(&result.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("ponuda %d: %w", id, shared.ErrResultExists)
+		}
+		return nil, err
+	}
+
+	ids, err := resolveOpenBets(tx, result)
+	if err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+// DeletePonuda deletes a ponuda with its tecajevi, odds history, limits and exposure thresholds,
+// and takes it out of its razrade. It fails with shared.ErrInUse if any bet was placed on the ponuda.
+func (s *PostGresStore) DeletePonuda(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var hasBets bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM player_bets WHERE ponuda_id = $1)`, id).Scan(&hasBets); err != nil {
+		return err
+	}
+	if hasBets {
+		return fmt.Errorf("ponuda %d has bets, withdraw it instead: %w", id, shared.ErrInUse)
+	}
+	for _, query := range []string{
+		`UPDATE razrade SET ponude = array_remove(ponude, $1) WHERE $1 = ANY(ponude)`,
+		`DELETE FROM tecaj_history WHERE ponuda_id = $1`,
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"bet_limits", "exposure_thresholds"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE scope = $1 AND scope_id = $2`, shared.LimitScopeEvent, id); err != nil {
+			return err
+		}
+	}
+	if err := deleteOne(tx.Exec(`DELETE FROM ponude WHERE id = $1`, id)); err != nil {
+		return fmt.Errorf("ponuda with id %d: %w", id, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+// createMarginRulesTable keeps the margins applied to feed odds. Liga 0 and the empty market
+// stand for every liga and every market.
+func (s *PostGresStore) createMarginRulesTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS margin_rules (
+			liga_id INT NOT NULL DEFAULT 0,
+			market VARCHAR(20) NOT NULL DEFAULT '',
+			margin NUMERIC(8, 6) NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (liga_id, market)
+		)
+	`)
+	return err
+}
+
+func (s *PostGresStore) GetMarginRules() ([]*shared.MarginRule, error) {
+	rows, err := s.db.Query(`SELECT liga_id, market, margin, updated_at FROM margin_rules ORDER BY liga_id, market`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	rules := []*shared.MarginRule{}
+	for rows.Next() {
+		rule := new(shared.MarginRule)
+		if err := rows.Scan(&rule.LigaID, &rule.Market, &rule.Margin, &rule.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *PostGresStore) SetMarginRule(rule *shared.MarginRule) error {
+	err := s.db.QueryRow(`
+		INSERT INTO margin_rules (liga_id, market, margin) VALUES ($1, $2, $3)
+		ON CONFLICT (liga_id, market) DO UPDATE SET margin = $3, updated_at = now()
+		RETURNING updated_at
+	`, rule.LigaID, rule.Market, rule.Margin).Scan(&rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save margin rule: %v", err)
+	}
+	return nil
+}
+
+func (s *PostGresStore) DeleteMarginRule(ligaID int, market string) error {
+	if err := deleteOne(s.db.Exec(`DELETE FROM margin_rules WHERE liga_id = $1 AND market = $2`, ligaID, market)); err != nil {
+		return fmt.Errorf("no margin rule for liga %d and market %q: %w", ligaID, market, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"time"
+)
+
+func (s *PostGresStore) createSessionTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS sessions (
+			id SERIAL PRIMARY KEY,
+			player_id INT NOT NULL,
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			user_agent VARCHAR(512) NOT NULL DEFAULT '',
+			ip VARCHAR(64) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			last_activity TIMESTAMPTZ NOT NULL DEFAULT now(),
+			revoked_at TIMESTAMPTZ DEFAULT NULL,
+			FOREIGN KEY (player_id) REFERENCES player(id) ON DELETE CASCADE
+		);
+	`)
+	return err
+}
+
+func (s *PostGresStore) CreateSession(session *shared.Session, tokenHash string) error {
+	err := s.db.QueryRow(`
+		INSERT INTO sessions (player_id, token_hash, user_agent, ip) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_activity
+	`, session.PlayerID, tokenHash, session.UserAgent, session.IP).Scan(&session.ID, &session.CreatedAt, &session.LastActivity)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+	return nil
+}
+
+// GetSessions returns the live sessions of a player: not revoked, active after idleSince and
+// created after createdSince.
+func (s *PostGresStore) GetSessions(playerID int, idleSince, createdSince time.Time) ([]*shared.Session, error) {
+	rows, err := s.db.Query(`
+		SELECT id, player_id, user_agent, ip, created_at, last_activity FROM sessions
+		WHERE player_id = $1 AND revoked_at IS NULL AND last_activity > $2 AND created_at > $3
+		ORDER BY last_activity DESC
+	`, playerID, idleSince, createdSince)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	sessions := []*shared.Session{}
+	for rows.Next() {
+		session, err := scanIntoSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records activity on the session with the given token. It returns sql.ErrNoRows
+// if the token is unknown, the session was revoked, or it expired: it was last active before
+// idleSince or created before createdSince.
+func (s *PostGresStore) TouchSession(tokenHash string, idleSince, createdSince time.Time) (*shared.Session, error) {
+	rows, err := s.db.Query(`
+		UPDATE sessions SET last_activity = now()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND last_activity > $2 AND created_at > $3
+		RETURNING id, player_id, user_agent, ip, created_at, last_activity
+	`, tokenHash, idleSince, createdSince)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	for rows.Next() {
+		return scanIntoSession(rows)
+	}
+	return nil, fmt.Errorf("session not found: %w", sql.ErrNoRows)
+}
+
+func (s *PostGresStore) RevokeSession(playerID int, sessionID int) error {
+	res, err := s.db.Exec(`UPDATE sessions SET revoked_at = now() WHERE id = $1 AND player_id = $2 AND revoked_at IS NULL`,
+		sessionID, playerID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("session %d not found: %w", sessionID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func (s *PostGresStore) RevokeSessions(playerID int) error {
+	_, err := s.db.Exec(`UPDATE sessions SET revoked_at = now() WHERE player_id = $1 AND revoked_at IS NULL`, playerID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	return nil
+}
+
+func scanIntoSession(rows *sql.Rows) (*shared.Session, error) {
+	session := new(shared.Session)
+	err := rows.Scan(
+		&session.ID,
+		&session.PlayerID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastActivity)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/lib/pq"
+)
+
+func (s *PostGresStore) createSlipRuleTables() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS exclusive_tips (
+			tip_a VARCHAR(255) NOT NULL,
+			tip_b VARCHAR(255) NOT NULL,
+			PRIMARY KEY (tip_a, tip_b),
+			CHECK (tip_a < tip_b)
+		);
+
+		CREATE TABLE IF NOT EXISTS league_combination_bans (
+			liga_a INT NOT NULL,
+			liga_b INT NOT NULL,
+			PRIMARY KEY (liga_a, liga_b),
+			CHECK (liga_a <= liga_b),
+			FOREIGN KEY (liga_a) REFERENCES lige(id) ON DELETE CASCADE,
+			FOREIGN KEY (liga_b) REFERENCES lige(id) ON DELETE CASCADE
+		);
+
+		ALTER TABLE exclusive_tips
+			ALTER COLUMN tip_a TYPE VARCHAR(255),
+			ALTER COLUMN tip_b TYPE VARCHAR(255);
+
+		INSERT INTO exclusive_tips (tip_a, tip_b) VALUES
+			('1', 'X'), ('1', '2'), ('2', 'X'), ('1', 'X2'), ('1X', '2'), ('12', 'X')
+		ON CONFLICT DO NOTHING;
+	`)
+	return err
+}
+
+// GetSlipRules returns the exclusive tipovi and league combination bans.
+func (s *PostGresStore) GetSlipRules() (*shared.SlipRules, error) {
+	rules := &shared.SlipRules{ExclusiveTips: []shared.ExclusiveTips{}, LeagueBans: []shared.LeagueCombinationBan{}}
+
+	rows, err := s.db.Query(`SELECT tip_a, tip_b FROM exclusive_tips ORDER BY tip_a, tip_b`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t shared.ExclusiveTips
+		if err := rows.Scan(&t.TipA, &t.TipB); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		rules.ExclusiveTips = append(rules.ExclusiveTips, t)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT liga_a, liga_b FROM league_combination_bans ORDER BY liga_a, liga_b`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var ban shared.LeagueCombinationBan
+		if err := rows.Scan(&ban.LigaA, &ban.LigaB); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		rules.LeagueBans = append(rules.LeagueBans, ban)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *PostGresStore) AddExclusiveTips(tips shared.ExclusiveTips) error {
+	a, b := tips.TipA, tips.TipB
+	if a > b {
+		a, b = b, a
+	}
+	_, err := s.db.Exec(`INSERT INTO exclusive_tips (tip_a, tip_b) VALUES ($1, $2) ON CONFLICT DO NOTHING`, a, b)
+	return err
+}
+
+func (s *PostGresStore) DeleteExclusiveTips(tips shared.ExclusiveTips) error {
+	a, b := tips.TipA, tips.TipB
+	if a > b {
+		a, b = b, a
+	}
+	return deleteOne(s.db.Exec(`DELETE FROM exclusive_tips WHERE tip_a = $1 AND tip_b = $2`, a, b))
+}
+
+func (s *PostGresStore) AddLeagueCombinationBan(ban shared.LeagueCombinationBan) error {
+	a, b := ban.LigaA, ban.LigaB
+	if a > b {
+		a, b = b, a
+	}
+	_, err := s.db.Exec(`INSERT INTO league_combination_bans (liga_a, liga_b) VALUES ($1, $2) ON CONFLICT DO NOTHING`, a, b)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return fmt.Errorf("liga %d or %d does not exist: %w", a, b, sql.ErrNoRows)
+	}
+	return err
+}
+
+func (s *PostGresStore) DeleteLeagueCombinationBan(ban shared.LeagueCombinationBan) error {
+	a, b := ban.LigaA, ban.LigaB
+	if a > b {
+		a, b = b, a
+	}
+	return deleteOne(s.db.Exec(`DELETE FROM league_combination_bans WHERE liga_a = $1 AND liga_b = $2`, a, b))
+}
+
+// deleteOne turns a DELETE that matched no rows into sql.ErrNoRows.
+func deleteOne(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("nothing to delete: %w", sql.ErrNoRows)
+	}
+	return nil
+}
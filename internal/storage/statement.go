@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+// GetStatement returns the player's wallet movements, newest first. The running balance starts
+// from the balance before the range, so it stays correct when the range is filtered.
+func (s *PostGresStore) GetStatement(playerID int, filter shared.StatementFilter) (*shared.Statement, error) {
+	var from, to sql.NullTime
+	if filter.From != nil {
+		from = sql.NullTime{Time: *filter.From, Valid: true}
+	}
+	if filter.To != nil {
+		to = sql.NullTime{Time: *filter.To, Valid: true}
+	}
+
+	// Both queries have to see the same ledger for the balances to add up.
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	account := shared.PlayerAccount(playerID)
+	var opening shared.Money
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(e.amount), 0)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE e.account = $1 AND $2::TIMESTAMPTZ IS NOT NULL AND t.created_at < $2
+	`, account, from).Scan(&opening)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get opening balance: %v", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT t.id, t.type, t.reference, t.created_at, e.amount, e.currency
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE e.account = $1
+		AND ($2::TIMESTAMPTZ IS NULL OR t.created_at >= $2)
+		AND ($3::TIMESTAMPTZ IS NULL OR t.created_at < $3)
+		ORDER BY t.created_at, t.id
+	`, account, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement: %v", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	movements := []*shared.StatementLine{}
+	for rows.Next() {
+		line := new(shared.StatementLine)
+		if err := rows.Scan(&line.TransactionID, &line.Type, &line.Reference, &line.CreatedAt, &line.Amount, &line.Currency); err != nil {
+			return nil, err
+		}
+		movements = append(movements, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return shared.BuildStatement(playerID, opening, movements, filter), nil
+}
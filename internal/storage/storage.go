@@ -4,9 +4,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/MKolega/Praksa/internal/password"
 	"github.com/MKolega/Praksa/internal/shared"
 	"github.com/lib/pq"
-	"log"
 	"strconv"
 )
 
@@ -29,7 +29,37 @@ func NewPostGresStore() (*PostGresStore, error) {
 	}, nil
 }
 func (s *PostGresStore) Init() error {
-	return s.createPlayerTable()
+	for _, create := range []func() error{
+		s.createPlayerTable,
+		s.createUsernameIndex,
+		s.migratePlayerProfile,
+		s.createLedgerTables,
+		s.migrateCurrencies,
+		s.migrateOpeningBalances,
+		s.createWithdrawalTable,
+		s.createPaymentIntentTable,
+		s.widenMoneyColumns,
+		s.createIdempotencyTable,
+		s.createBetLimitsTable,
+		s.createSlipRuleTables,
+		s.migrateTickets,
+		s.migrateMarkets,
+		s.createTecajHistoryTable,
+		s.createLoginThrottleTable,
+		s.createTOTPTables,
+		s.createSessionTable,
+		s.migrateLige,
+		s.migratePonude,
+		s.createMarginRulesTable,
+		s.createExposureThresholdsTable,
+		s.hashPlaintextPasswords,
+		s.migrateIdempotencyKeys,
+	} {
+		if err := create(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 func (s *PostGresStore) createPlayerTable() error {
 	_, err := s.db.Exec(`
@@ -92,132 +122,79 @@ func (s *PostGresStore) createPlayerTable() error {
 	return err
 }
 
-func (s *PostGresStore) CreatePlayer(player *shared.Player) error {
-	query := "INSERT INTO Player (username, password, account_balance) VALUES ($1, $2, $3)"
-	resp, err := s.db.Query(query,
-		player.Username, player.Password, player.AccountBalance)
+func (s *PostGresStore) createUsernameIndex() error {
+	_, err := s.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS player_username_lower_key ON Player (LOWER(username))`)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to create unique username index, duplicate usernames must be removed first: %v", err)
 	}
-
-	fmt.Printf("%+v\n", resp)
 	return nil
 }
 
-func (s *PostGresStore) CreateLiga(naziv string) (int, error) {
-	var existingID int
-	checkQuery := `SELECT id FROM lige WHERE naziv = $1`
-	err := s.db.QueryRow(checkQuery, naziv).Scan(&existingID)
-	if err == nil {
-		log.Printf("Duplicate liga found: %s (ID: %d), skipping insertion.", naziv, existingID)
-		return 0, nil
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return 0, fmt.Errorf("failed to check for duplicate liga: %v", err)
-	}
-
-	query := "INSERT INTO ligetest (naziv) VALUES ($1) RETURNING id"
-	var ligaID int
-	err = s.db.QueryRow(query, naziv).Scan(&ligaID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to insert liga: %v", err)
-	}
-	return ligaID, nil
-}
-
-func (s *PostGresStore) CreateRazrada(ligaID int, ponude []int) (int, error) {
-	var razradaID int
-	err := s.db.QueryRow(`INSERT INTO razrade (lige_id, ponude) VALUES ($1, $2) RETURNING id`,
-		ligaID, pq.Array(ponude)).Scan(&razradaID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create razrada: %v", err)
-	}
-	return razradaID, nil
+// widenMoneyColumns makes room for balances and stakes above the original NUMERIC(10, 2) and
+// NUMERIC(5, 2) limits. Odds keep two decimals.
+func (s *PostGresStore) widenMoneyColumns() error {
+	_, err := s.db.Exec(`
+		ALTER TABLE Player ALTER COLUMN account_balance TYPE NUMERIC(14, 2);
+		ALTER TABLE player_bets ALTER COLUMN iznos_uloga TYPE NUMERIC(14, 2);
+		ALTER TABLE player_bets ALTER COLUMN tecaj TYPE NUMERIC(8, 2);
+		ALTER TABLE tecajevi ALTER COLUMN tecaj TYPE NUMERIC(8, 2);
+		ALTER TABLE uplate ALTER COLUMN iznos TYPE NUMERIC(14, 2);
+	`)
+	return err
 }
 
-func (s *PostGresStore) CreateTipovi(razradaID int, naziv string) error {
-	query := `INSERT INTO tipovi (razrade_id, naziv) VALUES ($1, $2)`
-	_, err := s.db.Exec(query, razradaID, naziv)
-	if err != nil {
-		return fmt.Errorf("failed to insert tip: %v", err)
-	}
-
-	return nil
-}
-func (s *PostGresStore) GetLige() ([]*shared.Lige, error) {
-	rows, err := s.db.Query(`
-		SELECT l.naziv AS liga_naziv,
-		       r.ponude AS ponude,
-		       t.naziv AS tip_naziv
-		FROM lige l
-		LEFT JOIN razrade r ON l.id = r.lige_id
-		LEFT JOIN tipovi t ON r.id = t.razrade_id
-		ORDER BY l.naziv, r.id, t.naziv
-	`)
+// hashPlaintextPasswords replaces the passwords stored before they were hashed with their hashes.
+func (s *PostGresStore) hashPlaintextPasswords() error {
+	rows, err := s.db.Query(`SELECT id, password FROM Player WHERE password NOT LIKE 'pbkdf2-sha256$%'`)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			fmt.Printf("Failed to close rows")
-		}
-	}(rows)
-
-	ligaMap := make(map[string]*shared.Lige)
-
+	plaintext := map[int]string{}
 	for rows.Next() {
-		var ligaNaziv string
-		var ponude pq.Int64Array
-		var tipNaziv sql.NullString
-
-		if err := rows.Scan(&ligaNaziv, &ponude, &tipNaziv); err != nil {
-			return nil, err
-		}
-
-		// Initialize liga
-		if _, exists := ligaMap[ligaNaziv]; !exists {
-			ligaMap[ligaNaziv] = &shared.Lige{
-				Naziv:   ligaNaziv,
-				Razrade: []shared.Razrade{{Tipovi: []shared.Tipovi{}, Ponude: []int{}}},
-			}
-		}
-
-		liga := ligaMap[ligaNaziv]
-
-		// Initialize razrade
-		if len(liga.Razrade) == 0 {
-			liga.Razrade = append(liga.Razrade, shared.Razrade{Tipovi: []shared.Tipovi{}, Ponude: []int{}})
-		}
-
-		// Add tipovi
-		if tipNaziv.Valid {
-			tip := shared.Tipovi{Naziv: tipNaziv.String}
-			liga.Razrade[0].Tipovi = append(liga.Razrade[0].Tipovi, tip) // Add tip to the first Razrada
-		}
-
-		// Add ponude
-		if len(liga.Razrade[0].Ponude) == 0 {
-			for _, p := range ponude {
-				liga.Razrade[0].Ponude = append(liga.Razrade[0].Ponude, int(p)) // Add ponude to the first Razrada
-			}
+		var id int
+		var pass string
+		if err := rows.Scan(&id, &pass); err != nil {
+			_ = rows.Close()
+			return err
 		}
+		plaintext[id] = pass
+	}
+	if err := rows.Close(); err != nil {
+		return err
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return err
 	}
 
-	var ligas []*shared.Lige
-	for _, liga := range ligaMap {
-		ligas = append(ligas, liga) // Add Liga to the final slice
+	for id, pass := range plaintext {
+		hash, err := password.Hash(pass)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`UPDATE Player SET password = $1 WHERE id = $2 AND password = $3`, hash, id, pass); err != nil {
+			return fmt.Errorf("failed to hash password of player %d: %v", id, err)
+		}
 	}
+	return nil
+}
 
-	return ligas, nil
+func (s *PostGresStore) CreatePlayer(player *shared.Player) error {
+	query := "INSERT INTO Player (username, password, account_balance, currency) VALUES ($1, $2, $3, $4) RETURNING id"
+	err := s.db.QueryRow(query,
+		player.Username, player.Password, player.AccountBalance, player.Currency).Scan(&player.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return shared.ErrUsernameTaken
+		}
+		return err
+	}
+	return nil
 }
 
 func (s *PostGresStore) CreatePonuda(ponude *shared.Ponude) error {
 	query := "INSERT INTO ponude (broj,id ,naziv,tv_kanal,vrijeme,ima_statistiku) VALUES ($1, $2, $3, $4, $5, $6)"
-	resp, err := s.db.Query(query,
+	_, err := s.db.Exec(query,
 		ponude.Broj,
 		ponude.ID,
 		ponude.Naziv,
@@ -228,26 +205,11 @@ func (s *PostGresStore) CreatePonuda(ponude *shared.Ponude) error {
 	if err != nil {
 		return fmt.Errorf("failed to insert ponude: %v", err)
 	}
-
-	fmt.Printf("%+v\n", resp)
-	return nil
-}
-
-func (s *PostGresStore) CreateTecaj(ponudaID int, tecaj float64, naziv string) error {
-	query := " INSERT INTO tecajevi (ponuda_id, tecaj, naziv) VALUES ($1, $2, $3)"
-	resp, err := s.db.Query(query,
-		ponudaID,
-		tecaj,
-		naziv)
-	if err != nil {
-		return fmt.Errorf("failed to insert tecaj: %v", err)
-	}
-	fmt.Printf("%+v\n", resp)
 	return nil
 }
 
 func (s *PostGresStore) GetPonuda(id int) (*shared.Ponude, error) {
-	rows, err := s.db.Query(`SELECT p.id, p.broj, p.naziv, p.vrijeme, p.tv_kanal, p.ima_statistiku, t.tecaj, t.naziv FROM ponude p LEFT JOIN tecajevi t ON p.id = t.ponuda_id WHERE p.id = $1`, id)
+	rows, err := s.db.Query(`SELECT p.id, p.broj, p.naziv, p.vrijeme, p.tv_kanal, p.ima_statistiku, `+ponudaStatus+`, COALESCE(t.id, 0), t.tecaj, t.naziv, t.market, t.line, t.outcome, COALESCE(t.suspended, FALSE) FROM ponude p LEFT JOIN tecajevi t ON p.id = t.ponuda_id WHERE p.id = $1`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -262,8 +224,14 @@ func (s *PostGresStore) GetPonuda(id int) (*shared.Ponude, error) {
 			&ponuda.Vrijeme,
 			&ponuda.TvKanal,
 			&ponuda.ImaStatistiku,
+			&ponuda.Status,
+			&tecaj.ID,
 			&tecaj.Tecaj,
 			&tecaj.Naziv,
+			&tecaj.Market,
+			&tecaj.Line,
+			&tecaj.Outcome,
+			&tecaj.Suspended,
 		)
 		if err != nil {
 			return nil, err
@@ -272,14 +240,15 @@ func (s *PostGresStore) GetPonuda(id int) (*shared.Ponude, error) {
 
 	}
 	if ponuda.ID == 0 {
-		return nil, fmt.Errorf("ponuda with id %d not found", id)
+		return nil, fmt.Errorf("ponuda with id %d not found: %w", id, sql.ErrNoRows)
 	}
 	return ponuda, nil
 }
 
 func (s *PostGresStore) GetAllPonude() ([]*shared.Ponude, error) {
 	rows, err := s.db.Query(`
-		SELECT p.id, p.broj, p.naziv, p.vrijeme, p.tv_kanal, p.ima_statistiku, t.tecaj, t.naziv 
+		SELECT p.id, p.broj, p.naziv, p.vrijeme, p.tv_kanal, p.ima_statistiku, ` + ponudaStatus + `, COALESCE(t.id, 0), t.tecaj, t.naziv, t.market,
+			t.line, t.outcome, COALESCE(t.suspended, FALSE)
 		FROM ponude p 
 		LEFT JOIN tecajevi t ON p.id = t.ponuda_id
 		ORDER BY p.vrijeme DESC
@@ -308,8 +277,14 @@ func (s *PostGresStore) GetAllPonude() ([]*shared.Ponude, error) {
 			&ponuda.Vrijeme,
 			&ponuda.TvKanal,
 			&ponuda.ImaStatistiku,
+			&ponuda.Status,
+			&tecaj.ID,
 			&tecaj.Tecaj,
 			&tecaj.Naziv,
+			&tecaj.Market,
+			&tecaj.Line,
+			&tecaj.Outcome,
+			&tecaj.Suspended,
 		)
 		if err != nil {
 			return nil, err
@@ -336,7 +311,7 @@ func (s *PostGresStore) GetAllPonude() ([]*shared.Ponude, error) {
 
 func (s *PostGresStore) GetPlayers() ([]*shared.Player, error) {
 
-	rows, err := s.db.Query(`SELECT * FROM Player`)
+	rows, err := s.db.Query(`SELECT ` + playerColumns + ` FROM Player`)
 	if err != nil {
 		return nil, err
 
@@ -353,8 +328,9 @@ func (s *PostGresStore) GetPlayers() ([]*shared.Player, error) {
 
 }
 
-func (s *PostGresStore) ResetPassword(username string, newPassword string) error {
-	_, err := s.db.Exec(`UPDATE Player SET password = $1 WHERE username = $2`, newPassword, username)
+// ResetPassword replaces the password hash of a player.
+func (s *PostGresStore) ResetPassword(username string, passwordHash string) error {
+	_, err := s.db.Exec(`UPDATE Player SET password = $1 WHERE LOWER(username) = LOWER($2)`, passwordHash, username)
 	if err != nil {
 		return err
 	}
@@ -373,7 +349,7 @@ func (s *PostGresStore) DeleteUser(id int) error {
 	return nil
 }
 func (s *PostGresStore) GetLogin(username string) (*shared.Player, error) {
-	rows, err := s.db.Query(`SELECT * FROM Player WHERE username = $1`, username)
+	rows, err := s.db.Query(`SELECT `+playerColumns+` FROM Player WHERE LOWER(username) = LOWER($1)`, username)
 	if err != nil {
 		return nil, err
 	}
@@ -381,12 +357,12 @@ func (s *PostGresStore) GetLogin(username string) (*shared.Player, error) {
 	for rows.Next() {
 		return scanIntoPlayer(rows)
 	}
-	return nil, fmt.Errorf("player with username %s not found", username)
+	return nil, fmt.Errorf("player with username %s not found: %w", username, sql.ErrNoRows)
 
 }
 
 func (s *PostGresStore) GetPlayerByID(id int) (*shared.Player, error) {
-	rows, err := s.db.Query(`SELECT * FROM Player WHERE id = $1`, id)
+	rows, err := s.db.Query(`SELECT `+playerColumns+` FROM Player WHERE id = $1`, id)
 	if err != nil {
 		return nil, err
 
@@ -395,7 +371,7 @@ func (s *PostGresStore) GetPlayerByID(id int) (*shared.Player, error) {
 	for rows.Next() {
 		return scanIntoPlayer(rows)
 	}
-	return nil, fmt.Errorf("player with id %d not found", id)
+	return nil, fmt.Errorf("player with id %d not found: %w", id, sql.ErrNoRows)
 
 }
 
@@ -405,60 +381,42 @@ func scanIntoPlayer(rows *sql.Rows) (*shared.Player, error) {
 		&player.ID,
 		&player.Username,
 		&player.Password,
-		&player.AccountBalance)
+		&player.AccountBalance,
+		&player.Currency,
+		&player.Email,
+		&player.FullName,
+		&player.DateOfBirth,
+		&player.Address,
+		&player.NationalID,
+		&player.KYCStatus,
+		&player.KYCReason)
 	if err != nil {
 		return nil, err
 	}
 	return player, nil
 }
 
-func (s *PostGresStore) Deposit(id int, amount float64) error {
-	_, err := s.db.Exec(`UPDATE player SET account_balance = account_balance + $1 WHERE id = $2`, amount, id)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *PostGresStore) CreateUplata(playerID int, amount float64, parovi []shared.OdigraniPar) error {
-	tx, err := s.db.Begin()
+// lockPlayer locks the player's row until tx ends, serializing wallet changes. It returns the
+// currency of the player's wallet.
+func lockPlayer(tx *sql.Tx, id int) (string, error) {
+	var currency string
+	err := tx.QueryRow(`SELECT currency FROM Player WHERE id = $1 FOR UPDATE`, id).Scan(&currency)
 	if err != nil {
-		return err
-	}
-	defer func(tx *sql.Tx) {
-		err := tx.Rollback()
-		if err != nil {
-			fmt.Println("Failed to rollback transaction")
-		}
-	}(tx) // Rollback in case of error
-
-	for _, par := range parovi {
-		var tecaj float64
-		err = tx.QueryRow(`SELECT tecaj FROM tecajevi WHERE ponuda_id = $1 AND naziv = $2`, par.Ponuda, par.NazivTipa).Scan(&tecaj)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return fmt.Errorf("tecaj for ponuda with ID %d and tip %s does not exist", par.Ponuda, par.NazivTipa)
-			}
-		}
-		if amount*tecaj > 1000 {
-			return fmt.Errorf("winning amount is over 1000€")
-		}
-		_, err = tx.Exec(`INSERT INTO player_bets (player_id, ponuda_id, tip,tecaj,iznos_uloga) VALUES ($1, $2, $3, $4, $5)`, playerID, par.Ponuda, par.NazivTipa, tecaj, amount)
-		if err != nil {
-			return err
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("player with id %d not found: %w", id, sql.ErrNoRows)
 		}
+		return "", err
 	}
-
-	if err = tx.Commit(); err != nil {
-		return err
-	}
-
-	return nil
+	return currency, nil
 }
 
-func (s *PostGresStore) GetAccountBalance(id int) (float64, error) {
-	var balance float64
-	err := s.db.QueryRow(`SELECT account_balance FROM player WHERE id = $1`, id).Scan(&balance)
+// GetAccountBalance derives the player's balance from the ledger.
+func (s *PostGresStore) GetAccountBalance(id int) (shared.Money, error) {
+	var balance shared.Money
+	err := s.db.QueryRow(`
+		SELECT COALESCE((SELECT SUM(amount) FROM ledger_entries WHERE account = $2), 0)
+		FROM player WHERE id = $1
+	`, id, shared.PlayerAccount(id)).Scan(&balance)
 	if err != nil {
 		return 0, err
 	}
@@ -467,7 +425,7 @@ func (s *PostGresStore) GetAccountBalance(id int) (float64, error) {
 }
 
 func (s *PostGresStore) GetPonudaByID(id int) (*shared.Ponude, error) {
-	rows, err := s.db.Query(`SELECT * FROM ponude WHERE id = $1`, id)
+	rows, err := s.db.Query(`SELECT p.id, p.broj, p.naziv, p.vrijeme, p.tv_kanal, p.ima_statistiku, `+ponudaStatus+` FROM ponude p WHERE p.id = $1`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -480,13 +438,14 @@ func (s *PostGresStore) GetPonudaByID(id int) (*shared.Ponude, error) {
 			&ponuda.Vrijeme,
 			&ponuda.TvKanal,
 			&ponuda.ImaStatistiku,
+			&ponuda.Status,
 		)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if ponuda.ID == 0 {
-		return nil, fmt.Errorf("ponuda with id %d not found", id)
+		return nil, fmt.Errorf("ponuda with id %d not found: %w", id, sql.ErrNoRows)
 	}
 	return ponuda, nil
 
@@ -497,7 +456,7 @@ func (s *PostGresStore) GetTecaj(parovi []shared.OdigraniPar) ([]*shared.Tecajev
 
 	for _, par := range parovi {
 		rows, err := s.db.Query(
-			`SELECT tecaj, naziv FROM tecajevi WHERE ponuda_id = $1 AND naziv = $2`,
+			`SELECT tecaj, naziv, market, line, outcome FROM tecajevi WHERE ponuda_id = $1 AND naziv = $2`,
 			par.Ponuda, par.NazivTipa,
 		)
 		if err != nil {
@@ -507,7 +466,7 @@ func (s *PostGresStore) GetTecaj(parovi []shared.OdigraniPar) ([]*shared.Tecajev
 
 		for rows.Next() {
 			tecaj := new(shared.Tecajevi)
-			err := rows.Scan(&tecaj.Tecaj, &tecaj.Naziv)
+			err := rows.Scan(&tecaj.Tecaj, &tecaj.Naziv, &tecaj.Market, &tecaj.Line, &tecaj.Outcome)
 			if err != nil {
 				return nil, fmt.Errorf("failed to scan tecajevi row: %v", err)
 			}
@@ -0,0 +1,539 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+	"github.com/lib/pq"
+	"time"
+)
+
+// migrateTickets adds the fiscal breakdown and settlement to tickets, the status of every bet
+// and the results of finished ponude.
+func (s *PostGresStore) migrateTickets() error {
+	_, err := s.db.Exec(`
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS fee NUMERIC(14, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS stake NUMERIC(14, 2);
+		UPDATE uplate SET stake = iznos WHERE stake IS NULL;
+		ALTER TABLE uplate ALTER COLUMN stake SET NOT NULL;
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS total_odds NUMERIC(12, 2) NOT NULL DEFAULT 1;
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS potential_payout NUMERIC(14, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS tax NUMERIC(14, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS net_payout NUMERIC(14, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'open';
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS settled_odds NUMERIC(12, 2);
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS settled_payout NUMERIC(14, 2);
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS settled_tax NUMERIC(14, 2);
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS settled_net_payout NUMERIC(14, 2);
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS settled_refund NUMERIC(14, 2);
+		ALTER TABLE uplate ADD COLUMN IF NOT EXISTS settled_at TIMESTAMPTZ;
+		CREATE INDEX IF NOT EXISTS uplate_player_idx ON uplate (player_id, created_at);
+
+		ALTER TABLE player_bets ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'open';
+		CREATE INDEX IF NOT EXISTS player_bets_ponuda_idx ON player_bets (ponuda_id);
+
+		CREATE TABLE IF NOT EXISTS ponuda_results (
+			ponuda_id INT PRIMARY KEY,
+			winning_tipovi VARCHAR(10)[] NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			FOREIGN KEY (ponuda_id) REFERENCES ponude(id) ON DELETE CASCADE
+		);
+		ALTER TABLE ponuda_results ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'finished';
+	`)
+	return err
+}
+
+// walletEntries sets the currency of entries and leaves out the ones without an amount.
+func walletEntries(currency string, entries ...shared.LedgerEntry) []shared.LedgerEntry {
+	var nonZero []shared.LedgerEntry
+	for _, e := range entries {
+		if e.Amount != 0 {
+			e.Currency = currency
+			nonZero = append(nonZero, e)
+		}
+	}
+	return nonZero
+}
+
+// CreateUplata places u at the odds of its bets and charges the payment to the player's wallet,
+// splitting it between the stake and the handling fee. u is placed open unless its status says
+// it's referred. It fails with shared.ErrOddsChanged if
+// any of the odds moved since they were quoted, with shared.ErrSelectionSuspended if any of the
+// tipovi was suspended and with shared.ErrPonudaClosed if any of the ponude closed for betting.
+func (s *PostGresStore) CreateUplata(u *shared.Uplata) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fmt.Println("Failed to rollback transaction")
+		}
+	}(tx) // Rollback in case of error
+
+	currency, err := lockPlayer(tx, u.PlayerID)
+	if err != nil {
+		return err
+	}
+	if currency != u.Currency {
+		return fmt.Errorf("ticket is in %s but the wallet is in %s", u.Currency, currency)
+	}
+	if u.Status == "" {
+		u.Status = shared.TicketOpen
+	}
+	err = tx.QueryRow(`
+		INSERT INTO uplate (player_id, iznos, currency, fee, stake, total_odds, potential_payout, tax, net_payout, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, status, created_at
+	`, u.PlayerID, u.Payment, u.Currency, u.Fee, u.Stake, u.TotalOdds, u.PotentialPayout, u.Tax, u.NetPayout, u.Status).
+		Scan(&u.ID, &u.Status, &u.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert uplata: %v", err)
+	}
+
+	for _, bet := range u.Bets {
+		var tecaj shared.Odds
+		var suspended bool
+		var status string
+		err = tx.QueryRow(`
+			SELECT t.tecaj, t.suspended, `+ponudaStatus+` FROM tecajevi t JOIN ponude p ON p.id = t.ponuda_id
+			WHERE t.ponuda_id = $1 AND t.naziv = $2 FOR SHARE
+		`, bet.Ponuda, bet.NazivTipa).Scan(&tecaj, &suspended, &status)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("tecaj for ponuda with ID %d and tip %s does not exist", bet.Ponuda, bet.NazivTipa)
+			}
+			return err
+		}
+		if status != shared.PonudaOpen {
+			return fmt.Errorf("ponuda %d is %s: %w", bet.Ponuda, status, shared.ErrPonudaClosed)
+		}
+		if suspended {
+			return fmt.Errorf("ponuda %d tip %s: %w", bet.Ponuda, bet.NazivTipa, shared.ErrSelectionSuspended)
+		}
+		if tecaj != bet.Tecaj {
+			return fmt.Errorf("ponuda %d tip %s: %w", bet.Ponuda, bet.NazivTipa, shared.ErrOddsChanged)
+		}
+		err = tx.QueryRow(`
+			INSERT INTO player_bets (player_id, ponuda_id, tip, market, line, outcome, tecaj, iznos_uloga, uplata_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id, status
+		`, u.PlayerID, bet.Ponuda, bet.NazivTipa, bet.Market, bet.Line, bet.Outcome, tecaj, u.Stake, u.ID).Scan(&bet.ID, &bet.Status)
+		if err != nil {
+			return err
+		}
+	}
+
+	stake := &shared.LedgerTransaction{
+		Type:      shared.TxStake,
+		PlayerID:  u.PlayerID,
+		Reference: fmt.Sprintf("uplata:%d", u.ID),
+		Entries: walletEntries(u.Currency,
+			shared.LedgerEntry{Account: shared.PlayerAccount(u.PlayerID), Amount: -u.Payment},
+			shared.LedgerEntry{Account: shared.HouseBetsAccount, Amount: u.Stake},
+			shared.LedgerEntry{Account: shared.HouseFeesAccount, Amount: u.Fee},
+		),
+	}
+	if err := postLedgerTransaction(tx, stake); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const uplataColumns = `id, player_id, currency, iznos, fee, stake, total_odds, potential_payout, tax, net_payout,
+	status, settled_odds, settled_payout, settled_tax, settled_net_payout, settled_refund, settled_at, created_at`
+
+func scanIntoUplata(row interface{ Scan(...any) error }) (*shared.Uplata, error) {
+	u := new(shared.Uplata)
+	var settledOdds *shared.Odds
+	var settledPayout, settledTax, settledNet, settledRefund *shared.Money
+	var settledAt *time.Time
+	err := row.Scan(&u.ID, &u.PlayerID, &u.Currency, &u.Payment, &u.Fee, &u.Stake, &u.TotalOdds, &u.PotentialPayout,
+		&u.Tax, &u.NetPayout, &u.Status, &settledOdds, &settledPayout, &settledTax, &settledNet, &settledRefund, &settledAt, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if settledAt != nil {
+		u.Settlement = &shared.Settlement{SettledAt: *settledAt}
+		if settledOdds != nil {
+			u.Settlement.Odds = *settledOdds
+		}
+		if settledPayout != nil {
+			u.Settlement.Payout = *settledPayout
+		}
+		if settledTax != nil {
+			u.Settlement.Tax = *settledTax
+		}
+		if settledNet != nil {
+			u.Settlement.NetPayout = *settledNet
+		}
+		if settledRefund != nil {
+			u.Settlement.Refund = *settledRefund
+		}
+	}
+	return u, nil
+}
+
+// loadBets fills in the bets of every ticket.
+func (s *PostGresStore) loadBets(uplate ...*shared.Uplata) error {
+	if len(uplate) == 0 {
+		return nil
+	}
+	byID := make(map[int]*shared.Uplata, len(uplate))
+	ids := make([]int, 0, len(uplate))
+	for _, u := range uplate {
+		u.Bets = []*shared.TicketBet{}
+		byID[u.ID] = u
+		ids = append(ids, u.ID)
+	}
+	rows, err := s.db.Query(`
+		SELECT uplata_id, id, ponuda_id, tip, market, line, outcome, tecaj, status FROM player_bets
+		WHERE uplata_id = ANY($1) ORDER BY id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	for rows.Next() {
+		var uplataID int
+		bet := new(shared.TicketBet)
+		if err := rows.Scan(&uplataID, &bet.ID, &bet.Ponuda, &bet.NazivTipa, &bet.Market, &bet.Line, &bet.Outcome, &bet.Tecaj, &bet.Status); err != nil {
+			return err
+		}
+		byID[uplataID].Bets = append(byID[uplataID].Bets, bet)
+	}
+	return rows.Err()
+}
+
+func (s *PostGresStore) GetUplata(id int) (*shared.Uplata, error) {
+	u, err := scanIntoUplata(s.db.QueryRow(`SELECT `+uplataColumns+` FROM uplate WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("uplata with id %d not found: %w", id, sql.ErrNoRows)
+		}
+		return nil, err
+	}
+	if err := s.loadBets(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *PostGresStore) GetPlayerUplate(playerID int) ([]*shared.Uplata, error) {
+	rows, err := s.db.Query(`SELECT `+uplataColumns+` FROM uplate WHERE player_id = $1 ORDER BY created_at DESC, id DESC`, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	uplate := []*shared.Uplata{}
+	for rows.Next() {
+		u, err := scanIntoUplata(rows)
+		if err != nil {
+			return nil, err
+		}
+		uplate = append(uplate, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadBets(uplate...); err != nil {
+		return nil, err
+	}
+	return uplate, nil
+}
+
+// GetReferredUplate lists the tickets held for review, oldest first.
+func (s *PostGresStore) GetReferredUplate() ([]*shared.Uplata, error) {
+	rows, err := s.db.Query(`SELECT `+uplataColumns+` FROM uplate WHERE status = $1 ORDER BY created_at, id`, shared.TicketReferred)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	uplate := []*shared.Uplata{}
+	for rows.Next() {
+		u, err := scanIntoUplata(rows)
+		if err != nil {
+			return nil, err
+		}
+		uplate = append(uplate, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadBets(uplate...); err != nil {
+		return nil, err
+	}
+	return uplate, nil
+}
+
+// ApproveUplata opens a referred ticket. It fails with a wrapped sql.ErrNoRows if the ticket
+// isn't referred.
+func (s *PostGresStore) ApproveUplata(id int) error {
+	err := updateOne(s.db.Exec(`UPDATE uplate SET status = $2 WHERE id = $1 AND status = $3`, id, shared.TicketOpen, shared.TicketReferred))
+	if err != nil {
+		return fmt.Errorf("referred uplata with id %d: %w", id, err)
+	}
+	return nil
+}
+
+// SetPonudaResult records the result of a ponuda and resolves the open bets on it with
+// shared.PonudaResult.SettleBet. It returns the ids of the open tickets with bets on the ponuda,
+// and shared.ErrResultExists if the ponuda already has a result.
+func (s *PostGresStore) SetPonudaResult(result *shared.PonudaResult) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var homeScore, awayScore *int
+	if result.Score != nil {
+		homeScore, awayScore = &result.Score.Home, &result.Score.Away
+	}
+	err = tx.QueryRow(`
+		INSERT INTO ponuda_results (ponuda_id, status, winning_tipovi, home_score, away_score) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (ponuda_id) DO NOTHING
+		RETURNING created_at
+	`, result.PonudaID, result.Status, pq.Array(result.WinningTipovi), homeScore, awayScore).Scan(&result.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("ponuda %d: %w", result.PonudaID, shared.ErrResultExists)
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("ponuda with id %d not found: %w", result.PonudaID, sql.ErrNoRows)
+		}
+		return nil, err
+	}
+
+	ids, err := resolveOpenBets(tx, result)
+	if err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+// resolveOpenBets resolves the open bets on the ponuda of result and returns the ids of the open
+// tickets with bets on it.
+func resolveOpenBets(tx *sql.Tx, result *shared.PonudaResult) ([]int, error) {
+	type openBet struct {
+		id    int
+		naziv string
+		tip   shared.Tip
+	}
+	rows, err := tx.Query(`SELECT id, tip, market, line, outcome FROM player_bets WHERE ponuda_id = $1 AND status = $2 FOR UPDATE`,
+		result.PonudaID, shared.BetOpen)
+	if err != nil {
+		return nil, err
+	}
+	var bets []openBet
+	for rows.Next() {
+		var bet openBet
+		if err := rows.Scan(&bet.id, &bet.naziv, &bet.tip.Market, &bet.tip.Line, &bet.tip.Outcome); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		bets = append(bets, bet)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	for _, bet := range bets {
+		if _, err := tx.Exec(`UPDATE player_bets SET status = $2 WHERE id = $1`, bet.id, result.SettleBet(bet.naziv, bet.tip)); err != nil {
+			return nil, fmt.Errorf("failed to resolve bet %d: %v", bet.id, err)
+		}
+	}
+
+	return openUplataIDs(tx, result.PonudaID)
+}
+
+// GetUnsettledUplate returns the ids of the open tickets with bets on a ponuda whose result is
+// recorded. Settling them again is safe, so a settlement that failed half way can be rerun. It
+// returns sql.ErrNoRows if the ponuda has no result yet.
+func (s *PostGresStore) GetUnsettledUplate(ponudaID int) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var resulted bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM ponuda_results WHERE ponuda_id = $1)`, ponudaID).Scan(&resulted); err != nil {
+		return nil, err
+	}
+	if !resulted {
+		return nil, fmt.Errorf("ponuda %d has no result: %w", ponudaID, sql.ErrNoRows)
+	}
+	ids, err := openUplataIDs(tx, ponudaID)
+	if err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+func openUplataIDs(tx *sql.Tx, ponudaID int) ([]int, error) {
+	var ids []int
+	rows, err := tx.Query(`
+		SELECT DISTINCT u.id FROM uplate u JOIN player_bets b ON b.uplata_id = u.id
+		WHERE b.ponuda_id = $1 AND u.status = $2 ORDER BY u.id
+	`, ponudaID, shared.TicketOpen)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// SettleUplata closes an open or referred ticket with status and pays out the settlement,
+// withholding the tax. A refund returns the stake and the handling fee to the player. Voiding a
+// ticket also voids the bets on it that are still open, as when a referred ticket is rejected.
+// It returns false without changing anything if the ticket was already settled.
+func (s *PostGresStore) SettleUplata(id int, status string, settlement *shared.Settlement) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var playerID int
+	var currency string
+	var stake, fee shared.Money
+	err = tx.QueryRow(`
+		UPDATE uplate SET status = $2, settled_odds = $3, settled_payout = $4, settled_tax = $5,
+			settled_net_payout = $6, settled_refund = $7, settled_at = now()
+		WHERE id = $1 AND status IN ($8, $9)
+		RETURNING player_id, currency, stake, fee, settled_at
+	`, id, status, settlement.Odds, settlement.Payout, settlement.Tax, settlement.NetPayout, settlement.Refund, shared.TicketOpen, shared.TicketReferred).
+		Scan(&playerID, &currency, &stake, &fee, &settlement.SettledAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to settle uplata %d: %v", id, err)
+	}
+	if status == shared.TicketVoid {
+		_, err = tx.Exec(`UPDATE player_bets SET status = $3 WHERE uplata_id = $1 AND status = $2`, id, shared.BetOpen, shared.BetVoid)
+		if err != nil {
+			return false, fmt.Errorf("failed to void bets of uplata %d: %v", id, err)
+		}
+	}
+
+	if settlement.Payout > 0 {
+		win := &shared.LedgerTransaction{
+			Type:      shared.TxWin,
+			PlayerID:  playerID,
+			Reference: fmt.Sprintf("uplata:%d", id),
+			Entries: walletEntries(currency,
+				shared.LedgerEntry{Account: shared.HouseBetsAccount, Amount: -settlement.Payout},
+				shared.LedgerEntry{Account: shared.PlayerAccount(playerID), Amount: settlement.NetPayout},
+				shared.LedgerEntry{Account: shared.HouseTaxAccount, Amount: settlement.Tax},
+			),
+		}
+		if err := postLedgerTransaction(tx, win); err != nil {
+			return false, err
+		}
+	}
+	if settlement.Refund > 0 {
+		if settlement.Refund != stake+fee {
+			return false, fmt.Errorf("refund of %s doesn't match the payment of uplata %d", settlement.Refund, id)
+		}
+		refund := &shared.LedgerTransaction{
+			Type:      shared.TxRefund,
+			PlayerID:  playerID,
+			Reference: fmt.Sprintf("uplata:%d", id),
+			Entries: walletEntries(currency,
+				shared.LedgerEntry{Account: shared.HouseBetsAccount, Amount: -stake},
+				shared.LedgerEntry{Account: shared.HouseFeesAccount, Amount: -fee},
+				shared.LedgerEntry{Account: shared.PlayerAccount(playerID), Amount: settlement.Refund},
+			),
+		}
+		if err := postLedgerTransaction(tx, refund); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// GetFiscalReport totals the handling fees of tickets placed and the tax withheld from tickets
+// settled between from (inclusive) and to (exclusive), per currency. Fees refunded on void
+// tickets aren't counted.
+func (s *PostGresStore) GetFiscalReport(from, to *time.Time) ([]*shared.FiscalReportLine, error) {
+	var fromTime, toTime sql.NullTime
+	if from != nil {
+		fromTime = sql.NullTime{Time: *from, Valid: true}
+	}
+	if to != nil {
+		toTime = sql.NullTime{Time: *to, Valid: true}
+	}
+	rows, err := s.db.Query(`
+		WITH placed AS (
+			SELECT currency, COUNT(*) AS tickets, COALESCE(SUM(fee) FILTER (WHERE status <> $3), 0) AS fees FROM uplate
+			WHERE ($1::TIMESTAMPTZ IS NULL OR created_at >= $1) AND ($2::TIMESTAMPTZ IS NULL OR created_at < $2)
+			GROUP BY currency
+		), settled AS (
+			SELECT currency, SUM(settled_tax) AS tax FROM uplate
+			WHERE settled_at IS NOT NULL
+			AND ($1::TIMESTAMPTZ IS NULL OR settled_at >= $1) AND ($2::TIMESTAMPTZ IS NULL OR settled_at < $2)
+			GROUP BY currency
+		)
+		SELECT COALESCE(p.currency, t.currency), COALESCE(p.tickets, 0), COALESCE(p.fees, 0), COALESCE(t.tax, 0)
+		FROM placed p FULL JOIN settled t ON t.currency = p.currency
+		ORDER BY 1
+	`, fromTime, toTime, shared.TicketVoid)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	lines := []*shared.FiscalReportLine{}
+	for rows.Next() {
+		line := new(shared.FiscalReportLine)
+		if err := rows.Scan(&line.Currency, &line.Tickets, &line.Fees, &line.Tax); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+func (s *PostGresStore) createTOTPTables() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS player_totp (
+			player_id INT PRIMARY KEY,
+			secret VARCHAR(64) NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			last_step BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			FOREIGN KEY (player_id) REFERENCES player(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS player_recovery_codes (
+			id SERIAL PRIMARY KEY,
+			player_id INT NOT NULL,
+			code_hash VARCHAR(64) NOT NULL,
+			used_at TIMESTAMPTZ DEFAULT NULL,
+			FOREIGN KEY (player_id) REFERENCES player(id) ON DELETE CASCADE
+		);
+	`)
+	return err
+}
+
+func (s *PostGresStore) GetTOTP(playerID int) (*shared.PlayerTOTP, error) {
+	t := &shared.PlayerTOTP{PlayerID: playerID}
+	err := s.db.QueryRow(`SELECT secret, enabled, last_step FROM player_totp WHERE player_id = $1`, playerID).
+		Scan(&t.Secret, &t.Enabled, &t.LastStep)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SaveTOTPSecret stores a new, not yet confirmed secret. An already enabled secret is left alone.
+func (s *PostGresStore) SaveTOTPSecret(playerID int, secret string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO player_totp (player_id, secret) VALUES ($1, $2)
+		ON CONFLICT (player_id) DO UPDATE SET secret = $2, last_step = 0, created_at = now()
+		WHERE player_totp.enabled = FALSE
+	`, playerID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save totp secret: %v", err)
+	}
+	return nil
+}
+
+func (s *PostGresStore) EnableTOTP(playerID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	res, err := tx.Exec(`UPDATE player_totp SET enabled = TRUE, last_step = $2 WHERE player_id = $1 AND enabled = FALSE`, playerID, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable totp: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no pending totp enrollment for player %d: %w", playerID, sql.ErrNoRows)
+	}
+	if _, err := tx.Exec(`DELETE FROM player_recovery_codes WHERE player_id = $1`, playerID); err != nil {
+		return fmt.Errorf("failed to clear recovery codes: %v", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`INSERT INTO player_recovery_codes (player_id, code_hash) VALUES ($1, $2)`, playerID, hash); err != nil {
+			return fmt.Errorf("failed to insert recovery code: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostGresStore) DisableTOTP(playerID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if _, err := tx.Exec(`DELETE FROM player_recovery_codes WHERE player_id = $1`, playerID); err != nil {
+		return fmt.Errorf("failed to clear recovery codes: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM player_totp WHERE player_id = $1`, playerID); err != nil {
+		return fmt.Errorf("failed to disable totp: %v", err)
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep marks step as used. It reports false if that step or a later one was already used.
+func (s *PostGresStore) UseTOTPStep(playerID int, step int64) (bool, error) {
+	res, err := s.db.Exec(`UPDATE player_totp SET last_step = $2 WHERE player_id = $1 AND enabled = TRUE AND last_step < $2`, playerID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp step: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UseRecoveryCode consumes an unused recovery code. It reports false if no such code is left.
+func (s *PostGresStore) UseRecoveryCode(playerID int, codeHash string) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE player_recovery_codes SET used_at = now()
+		WHERE id = (
+			SELECT id FROM player_recovery_codes
+			WHERE player_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL
+	`, playerID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
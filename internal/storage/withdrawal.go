@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/MKolega/Praksa/internal/shared"
+)
+
+func (s *PostGresStore) createWithdrawalTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS withdrawals (
+			id SERIAL PRIMARY KEY,
+			player_id INT NOT NULL,
+			amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+			currency VARCHAR(3) NOT NULL DEFAULT 'EUR',
+			status VARCHAR(16) NOT NULL DEFAULT 'pending',
+			reason VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			FOREIGN KEY (player_id) REFERENCES player(id) ON DELETE CASCADE
+		);
+
+		ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'EUR';
+
+		CREATE INDEX IF NOT EXISTS withdrawals_status_idx ON withdrawals (status, created_at);
+	`)
+	return err
+}
+
+const withdrawalColumns = `id, player_id, amount, currency, status, reason, created_at, updated_at`
+
+// CreateWithdrawal stores a pending withdrawal and reserves its amount from the player's wallet.
+func (s *PostGresStore) CreateWithdrawal(w *shared.Withdrawal) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	currency, err := lockPlayer(tx, w.PlayerID)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO withdrawals (player_id, amount, currency) VALUES ($1, $2, $3) RETURNING `+withdrawalColumns,
+		w.PlayerID, w.Amount, currency).Scan(&w.ID, &w.PlayerID, &w.Amount, &w.Currency, &w.Status, &w.Reason, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert withdrawal: %v", err)
+	}
+	reserve := shared.NewWalletTransaction(shared.TxWithdrawal, w.PlayerID, shared.HouseWithdrawalsAccount, -w.Amount,
+		w.Currency, fmt.Sprintf("withdrawal:%d", w.ID))
+	if err := postLedgerTransaction(tx, reserve); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostGresStore) GetWithdrawal(id int) (*shared.Withdrawal, error) {
+	rows, err := s.db.Query(`SELECT `+withdrawalColumns+` FROM withdrawals WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	for rows.Next() {
+		return scanIntoWithdrawal(rows)
+	}
+	return nil, fmt.Errorf("withdrawal with id %d not found: %w", id, sql.ErrNoRows)
+}
+
+// GetWithdrawals lists withdrawals, optionally only those of one player (playerID > 0) or in one status.
+func (s *PostGresStore) GetWithdrawals(playerID int, status string) ([]*shared.Withdrawal, error) {
+	rows, err := s.db.Query(`
+		SELECT `+withdrawalColumns+` FROM withdrawals
+		WHERE ($1 = 0 OR player_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+	`, playerID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Printf("Failed to close rows")
+		}
+	}(rows)
+
+	withdrawals := []*shared.Withdrawal{}
+	for rows.Next() {
+		w, err := scanIntoWithdrawal(rows)
+		if err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	return withdrawals, rows.Err()
+}
+
+// SetWithdrawalStatus moves a withdrawal from one state to another. Rejecting releases the
+// reserved funds back to the player, paying moves them out to the cash account. It returns
+// sql.ErrNoRows if the withdrawal is no longer in the from state.
+func (s *PostGresStore) SetWithdrawalStatus(id int, from, to, reason string) (*shared.Withdrawal, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	w := new(shared.Withdrawal)
+	err = tx.QueryRow(`
+		UPDATE withdrawals SET status = $3, reason = $4, updated_at = now()
+		WHERE id = $1 AND status = $2
+		RETURNING `+withdrawalColumns, id, from, to, reason).
+		Scan(&w.ID, &w.PlayerID, &w.Amount, &w.Currency, &w.Status, &w.Reason, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("withdrawal %d is not %s: %w", id, from, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("failed to update withdrawal: %v", err)
+	}
+
+	reference := fmt.Sprintf("withdrawal:%d", w.ID)
+	switch to {
+	case shared.WithdrawalRejected:
+		release := shared.NewWalletTransaction(shared.TxRefund, w.PlayerID, shared.HouseWithdrawalsAccount, w.Amount, w.Currency, reference)
+		if err := postLedgerTransaction(tx, release); err != nil {
+			return nil, err
+		}
+	case shared.WithdrawalPaid:
+		payout := &shared.LedgerTransaction{
+			Type:      shared.TxWithdrawal,
+			PlayerID:  w.PlayerID,
+			Reference: reference,
+			Entries: []shared.LedgerEntry{
+				{Account: shared.HouseWithdrawalsAccount, Amount: -w.Amount, Currency: w.Currency},
+				{Account: shared.HouseCashAccount, Amount: w.Amount, Currency: w.Currency},
+			},
+		}
+		if err := postLedgerTransaction(tx, payout); err != nil {
+			return nil, err
+		}
+	}
+	return w, tx.Commit()
+}
+
+func scanIntoWithdrawal(rows *sql.Rows) (*shared.Withdrawal, error) {
+	w := new(shared.Withdrawal)
+	err := rows.Scan(&w.ID, &w.PlayerID, &w.Amount, &w.Currency, &w.Status, &w.Reason, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
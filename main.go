@@ -1,12 +1,43 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"github.com/MKolega/Praksa/internal/API"
+	"github.com/MKolega/Praksa/internal/fiscal"
+	"github.com/MKolega/Praksa/internal/shared"
 	"github.com/MKolega/Praksa/internal/storage"
 	"log"
 )
 
+// moneyFlag parses a non-negative amount flag into m.
+func moneyFlag(m *shared.Money) func(string) error {
+	return func(s string) error {
+		v, err := shared.ParseMoney(s)
+		if err != nil {
+			return err
+		}
+		if v < 0 {
+			return fmt.Errorf("amount can't be negative: %s", s)
+		}
+		*m = v
+		return nil
+	}
+}
+
+// main serves the API. This binary registers no real payment provider: deposits are refused
+// unless it runs with -fake-payments, which confirms them without charging. Production builds
+// register their provider with RegisterPaymentProvider on the server before Run.
 func main() {
+	fakePayments := flag.Bool("fake-payments", false, "enable the fake payment provider, which confirms deposits without charging (development only)")
+	fiscalRules := flag.String("fiscal-rules", "", "JSON file with the handling fee and winnings tax brackets, Croatian rules by default")
+	kyc := API.DefaultKYCThresholds
+	flag.Func("kyc-deposit-limit", fmt.Sprintf("largest deposit of an unverified player in %s (default %s)", shared.BaseCurrency, kyc.Deposit), moneyFlag(&kyc.Deposit))
+	flag.Func("kyc-withdrawal-limit", fmt.Sprintf("largest withdrawal of an unverified player in %s (default %s)", shared.BaseCurrency, kyc.Withdrawal), moneyFlag(&kyc.Withdrawal))
+	flag.Func("kyc-stake-limit", fmt.Sprintf("largest stake of an unverified player in %s (default %s)", shared.BaseCurrency, kyc.Stake), moneyFlag(&kyc.Stake))
+	allowSameEvent := flag.Bool("allow-same-event", API.DefaultSlipPolicy.AllowSameEvent, "allow more than one selection per event on a slip when the tipovi aren't exclusive")
+	flag.Parse()
+
 	store, err := storage.NewPostGresStore()
 	if err != nil {
 		log.Fatal(err)
@@ -15,7 +46,21 @@ func main() {
 	if err := store.Init(); err != nil {
 		log.Fatal(err)
 	}
-	server := API.NewApiServer(":8080", store)
+	opts := []API.Option{
+		API.WithKYCThresholds(kyc),
+		API.WithSlipPolicy(API.SlipPolicy{AllowSameEvent: *allowSameEvent}),
+	}
+	if *fakePayments {
+		opts = append(opts, API.WithFakePayments())
+	}
+	if *fiscalRules != "" {
+		rules, err := fiscal.LoadRules(*fiscalRules)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, API.WithFiscalRules(rules))
+	}
+	server := API.NewApiServer(":8080", store, opts...)
 	server.Run()
 
 }